@@ -42,6 +42,7 @@ func main() {
 	flags.StringP(flagConfigFile, "c", "/etc/rig-operator/config.yaml", "path to rig-operator config file")
 
 	c.AddCommand(build.VersionCommand())
+	c.AddCommand(validateConfigCommand())
 
 	ctx := context.Background()
 	if err := c.ExecuteContext(ctx); err != nil {
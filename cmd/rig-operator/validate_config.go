@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/rigdev/rig/pkg/api/config/v1alpha1"
+	"github.com/rigdev/rig/pkg/jsonschema"
+	"github.com/rigdev/rig/pkg/manager"
+	"github.com/rigdev/rig/pkg/service/config"
+	platform "github.com/rigdev/rig/pkg/service/platform_config"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	flagSecretFile = "secret-file"
+	flagSchema     = "schema"
+)
+
+func validateConfigCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "validate-config [file | kind]",
+		Short: "validate an OperatorConfig or PlatformConfig file",
+		Long: `Validate an OperatorConfig or PlatformConfig file. The kind is read from the
+file, which defaults to the config file of the operator. Unknown fields are
+reported as errors.
+
+With --schema, the JSON Schema of the kind given as argument is printed
+instead, for use by editors. The kind defaults to OperatorConfig.`,
+		Args:          cobra.MaximumNArgs(1),
+		RunE:          validateConfig,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	flags := cmd.Flags()
+	flags.String(flagSecretFile, "", "path to the secret file merged into a PlatformConfig")
+	flags.Bool(flagSchema, false, "print the JSON Schema of the kind, OperatorConfig or PlatformConfig")
+
+	return cmd
+}
+
+func validateConfig(cmd *cobra.Command, args []string) error {
+	schema, err := cmd.Flags().GetBool(flagSchema)
+	if err != nil {
+		return err
+	}
+	if schema {
+		kind := "OperatorConfig"
+		if len(args) > 0 {
+			kind = args[0]
+		}
+		return printSchema(cmd, kind)
+	}
+
+	path, err := cmd.Flags().GetString(flagConfigFile)
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read config file: %w", err)
+	}
+	var meta metav1.TypeMeta
+	if err := yaml.Unmarshal(bs, &meta); err != nil {
+		return fmt.Errorf("could not decode config: %w", err)
+	}
+
+	scheme := manager.NewScheme()
+	switch meta.Kind {
+	case "OperatorConfig":
+		if _, err := config.NewService(path, scheme); err != nil {
+			return err
+		}
+	case "PlatformConfig":
+		secretPath, err := cmd.Flags().GetString(flagSecretFile)
+		if err != nil {
+			return err
+		}
+		// Secrets are read from the environment if no secret file is given.
+		if _, err := platform.NewService(path, secretPath, scheme); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported api kind '%s'", meta.Kind)
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "%s is a valid %s\n", path, meta.Kind)
+	return nil
+}
+
+func printSchema(cmd *cobra.Command, kind string) error {
+	var s *jsonschema.Schema
+	switch kind {
+	case "OperatorConfig":
+		s = jsonschema.For(&v1alpha1.OperatorConfig{})
+	case "PlatformConfig":
+		s = jsonschema.For(&v1alpha1.PlatformConfig{})
+	default:
+		return fmt.Errorf("unsupported api kind '%s'", kind)
+	}
+
+	enc := json.NewEncoder(cmd.OutOrStdout())
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
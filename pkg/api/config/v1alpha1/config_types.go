@@ -1,10 +1,16 @@
 package v1alpha1
 
 import (
+	"strings"
+	"time"
+
 	"github.com/rigdev/rig/pkg/ptr"
 	"go.uber.org/zap/zapcore"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // OperatorConfig is the Schema for the configs API
@@ -36,6 +42,180 @@ type OperatorConfig struct {
 	// PrometheusServiceMonitor defines if Rig should spawn a Prometheus ServiceMonitor per capsule
 	// for use with a Prometheus Operator stack.
 	PrometheusServiceMonitor *PrometheusServiceMonitor `json:"prometheusServiceMonitor,omitempty"`
+
+	// Scheduling holds default scheduling configuration applied to all
+	// capsules.
+	Scheduling SchedulingConfig `json:"scheduling,omitempty"`
+
+	// PodDisruptionBudget holds the default PodDisruptionBudget policy for
+	// capsules with more than one instance. If omitted, budgets are only
+	// created for capsules which specify their own.
+	PodDisruptionBudget *PodDisruptionBudgetConfig `json:"podDisruptionBudget,omitempty"`
+
+	// Telemetry holds the OpenTelemetry configuration injected into all
+	// capsules as OTEL_* environment variables. If omitted, no variables are
+	// injected.
+	Telemetry *TelemetryConfig `json:"telemetry,omitempty"`
+
+	// Defaults holds defaults applied to capsules by the mutating webhook.
+	// Values set on a capsule are never overwritten, and the fields which
+	// were defaulted are recorded in an annotation on the capsule.
+	Defaults CapsuleDefaults `json:"defaults,omitempty"`
+
+	// Overrides holds the fields of the resources created for capsules which
+	// capsules may patch with overrides. If omitted, capsules can't have
+	// overrides.
+	Overrides *OverridesConfig `json:"overrides,omitempty"`
+
+	// Cleanup holds the configuration of the cleanup of capsules with a TTL.
+	Cleanup CleanupConfig `json:"cleanup,omitempty"`
+}
+
+type CleanupConfig struct {
+	// PrometheusURL is the URL of the Prometheus API which is queried for
+	// the ingress traffic of capsules with an idle timeout. Idle timeouts are
+	// not enforced if omitted.
+	PrometheusURL string `json:"prometheusURL,omitempty"`
+
+	// TrafficQuery is a Go template of the PromQL query returning the number
+	// of ingress requests to a capsule. The template is executed with the
+	// .Namespace and .Name of the capsule, and the .Window to count requests
+	// in as a Prometheus duration. Defaults to a query of the metrics of
+	// ingress-nginx.
+	TrafficQuery string `json:"trafficQuery,omitempty"`
+
+	// WarningPeriod is how long before a capsule expires it is reported as
+	// expiring. Defaults to 1 hour.
+	WarningPeriod *metav1.Duration `json:"warningPeriod,omitempty"`
+}
+
+// DefaultTrafficQuery is the default query of the ingress traffic of a
+// capsule, using the metrics of ingress-nginx. It counts the requests to the
+// Service of the capsule from any Ingress, including the primary Ingress of a
+// TrafficSplit. Requests routed to the canary backend of a TrafficSplit are
+// reported by ingress-nginx with the Service of the primary backend, and
+// traffic through a Gateway is not counted.
+const DefaultTrafficQuery = `sum(increase(nginx_ingress_controller_requests{` +
+	`exported_namespace="{{ .Namespace }}",exported_service="{{ .Name }}"}[{{ .Window }}]))`
+
+type OverridesConfig struct {
+	// Allowed lists the fields which may be patched, per kind of resource.
+	Allowed []AllowedOverride `json:"allowed,omitempty"`
+}
+
+type AllowedOverride struct {
+	// Kind is the kind of resource. One of Deployment, Service, Ingress,
+	// HorizontalPodAutoscaler and ServiceAccount.
+	Kind string `json:"kind"`
+
+	// Paths are JSON pointers to the fields which may be patched, including
+	// the fields below them, e.g. /spec/template/spec/hostAliases. The path /
+	// allows the whole resource to be patched.
+	Paths []string `json:"paths"`
+}
+
+// OverrideKinds are the kinds of resources which capsules may patch with
+// overrides.
+var OverrideKinds = []string{"Deployment", "Service", "Ingress", "HorizontalPodAutoscaler", "ServiceAccount"}
+
+// IsAllowed returns true if the field of the kind at the given JSON pointer
+// may be patched.
+func (c *OverridesConfig) IsAllowed(kind, path string) bool {
+	if c == nil {
+		return false
+	}
+	for _, a := range c.Allowed {
+		if a.Kind != kind {
+			continue
+		}
+		for _, p := range a.Paths {
+			p = strings.TrimSuffix(p, "/")
+			if path == p || strings.HasPrefix(path, p+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type CapsuleDefaults struct {
+	// CPU is the default CPU request and limit of capsules.
+	CPU *ResourceDefaults `json:"cpu,omitempty"`
+
+	// Memory is the default memory request and limit of capsules.
+	Memory *ResourceDefaults `json:"memory,omitempty"`
+
+	// Readiness is the default readiness probe of the first interface of
+	// capsules which have no readiness probe on any interface.
+	Readiness *ProbeDefaults `json:"readiness,omitempty"`
+
+	// NodeSelector holds default node selector labels of capsules. Labels
+	// are added to the node selector of a capsule, unless the capsule
+	// selects a value for the label itself.
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Annotations holds default annotations of capsules. Annotations are
+	// added to a capsule, unless the capsule has a value for the annotation
+	// itself.
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type ResourceDefaults struct {
+	// Request is the default resource request.
+	Request *resource.Quantity `json:"request,omitempty"`
+
+	// Limit is the default resource limit.
+	Limit *resource.Quantity `json:"limit,omitempty"`
+}
+
+type ProbeDefaults struct {
+	// Path is the HTTP path of the probe. Path is mutually exclusive with
+	// TCP.
+	Path string `json:"path,omitempty"`
+
+	// TCP specifies that this is a simple TCP listen probe.
+	TCP bool `json:"tcp,omitempty"`
+}
+
+type TelemetryConfig struct {
+	// Endpoint is the URL of the OpenTelemetry collector, e.g.
+	// http://otel-collector.observability:4317.
+	Endpoint string `json:"endpoint"`
+
+	// Protocol is the protocol used to export telemetry to the collector. One
+	// of grpc, http/protobuf and http/json. Defaults to grpc.
+	Protocol string `json:"protocol,omitempty"`
+
+	// SamplingRatio is the ratio of traces to sample, between 0 and 1. Parent
+	// based sampling is used, so the decision of the caller is respected.
+	// Defaults to sampling all traces.
+	SamplingRatio *float64 `json:"samplingRatio,omitempty"`
+
+	// ResourceAttributes are added to the resource attributes of all
+	// capsules.
+	ResourceAttributes map[string]string `json:"resourceAttributes,omitempty"`
+}
+
+type PodDisruptionBudgetConfig struct {
+	// MinAvailable is the number or percentage of instances which must still
+	// be available after an eviction.
+	MinAvailable *intstr.IntOrString `json:"minAvailable,omitempty"`
+
+	// MaxUnavailable is the number or percentage of instances which can be
+	// unavailable after an eviction. Defaults to 1 if neither MinAvailable
+	// nor MaxUnavailable is set.
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+}
+
+type SchedulingConfig struct {
+	// Tolerations are added to the instances of all capsules, in addition to
+	// the tolerations specified on the capsule.
+	Tolerations []v1.Toleration `json:"tolerations,omitempty"`
+
+	// TopologySpreadConstraints are used for capsules which doesn't specify
+	// their own constraints. If no label selector is given, the instances of
+	// the capsule are selected.
+	TopologySpreadConstraints []v1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
 }
 
 type PrometheusServiceMonitor struct {
@@ -53,6 +233,24 @@ type CertManagerConfig struct {
 	// resources. If this is not enabled we will use ingress annotations. This
 	// is handy in environments where the ingress-shim isen't enabled.
 	CreateCertificateResources bool `json:"createCertificateResources,omitempty"`
+
+	// Wildcards are domains with a shared wildcard certificate. Interfaces
+	// whose host is a direct subdomain of a wildcard domain use a wildcard
+	// certificate in the namespace of the capsule, shared by all capsules of
+	// the namespace, instead of a certificate of their own, unless the
+	// capsule has an issuer of its own. Requires CreateCertificateResources.
+	Wildcards []WildcardCertificate `json:"wildcards,omitempty"`
+}
+
+type WildcardCertificate struct {
+	// Domain is the domain of the wildcard certificate, e.g. example.com
+	// for a certificate of *.example.com.
+	Domain string `json:"domain"`
+
+	// ClusterIssuer issues the wildcard certificates, which usually requires
+	// a DNS01 solver. Defaults to the ClusterIssuer of the certificates of
+	// the capsules.
+	ClusterIssuer string `json:"clusterIssuer,omitempty"`
 }
 
 type IngressConfig struct {
@@ -62,8 +260,48 @@ type IngressConfig struct {
 	// ClassName specifies the default ingress class to use for all ingress
 	// resources created.
 	ClassName string `json:"className"`
+
+	// ProtocolAnnotations maps ingress class names to the annotations which
+	// make the ingress controller of the class connect to interfaces using
+	// their protocol. Ingress resources without a class use the annotations
+	// of the empty class name. Defaults to the annotations of ingress-nginx
+	// for the nginx class.
+	ProtocolAnnotations map[string]ProtocolAnnotations `json:"protocolAnnotations,omitempty"`
+
+	// Profiles maps ingress class names to the profiles which translate the
+	// ingress features of interfaces, such as CORS and rate limits, to the
+	// ingress controller of the class. The built-in nginx and traefik
+	// profiles are used for the nginx and traefik classes, unless
+	// configured here.
+	Profiles map[string]IngressProfile `json:"profiles,omitempty"`
 }
 
+// IngressProfile translates the ingress features of interfaces to the
+// configuration of an ingress controller.
+type IngressProfile struct {
+	// Annotations maps each ingress feature to the annotations of the ingress
+	// resources configuring it. The features are cors, rateLimit,
+	// sourceRanges, basicAuth, maxBodySize and timeout. The values of the
+	// annotations are Go templates, executed with .Origins, .RequestsPerSecond,
+	// .SourceRanges, .SecretName, .MaxBodySizeBytes and .TimeoutSeconds.
+	Annotations map[string]map[string]string `json:"annotations,omitempty"`
+
+	// TraefikMiddlewares renders the features as Traefik Middlewares,
+	// referenced by the router.middlewares annotation of the ingress
+	// resources. The timeout feature is not supported by Traefik
+	// Middlewares, and the sourceRanges feature requires Traefik 2.11 or
+	// later for the ipAllowList Middleware.
+	TraefikMiddlewares bool `json:"traefikMiddlewares,omitempty"`
+}
+
+// ProtocolAnnotations are the annotations of ingress resources for each
+// protocol of the interfaces, which is one of HTTP, HTTP2 or GRPC.
+type ProtocolAnnotations map[string]map[string]string
+
+// IngressProtocols are the protocols of interfaces which can be published
+// through an ingress.
+var IngressProtocols = []string{"HTTP", "HTTP2", "GRPC"}
+
 func (c *OperatorConfig) Default() {
 	if c.WebhooksEnabled == nil {
 		c.WebhooksEnabled = ptr.New(true)
@@ -74,6 +312,25 @@ func (c *OperatorConfig) Default() {
 	if c.Ingress.Annotations == nil {
 		c.Ingress.Annotations = map[string]string{}
 	}
+	if c.Ingress.ProtocolAnnotations == nil {
+		c.Ingress.ProtocolAnnotations = map[string]ProtocolAnnotations{
+			"nginx": {
+				"GRPC": {"nginx.ingress.kubernetes.io/backend-protocol": "GRPC"},
+			},
+		}
+	}
+	if pdb := c.PodDisruptionBudget; pdb != nil && pdb.MinAvailable == nil && pdb.MaxUnavailable == nil {
+		pdb.MaxUnavailable = ptr.New(intstr.FromInt32(1))
+	}
+	if c.Telemetry != nil && c.Telemetry.Protocol == "" {
+		c.Telemetry.Protocol = "grpc"
+	}
+	if c.Cleanup.TrafficQuery == "" {
+		c.Cleanup.TrafficQuery = DefaultTrafficQuery
+	}
+	if c.Cleanup.WarningPeriod == nil {
+		c.Cleanup.WarningPeriod = &metav1.Duration{Duration: time.Hour}
+	}
 }
 
 func init() {
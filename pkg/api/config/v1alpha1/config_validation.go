@@ -0,0 +1,257 @@
+package v1alpha1
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+	"text/template"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// Validate returns an error if the config is semantically invalid. It
+// expects defaults to be applied.
+func (c *OperatorConfig) Validate() error {
+	var errs field.ErrorList
+	errs = append(errs, c.Certmanager.validate(field.NewPath("certManager"))...)
+	errs = append(errs, c.PodDisruptionBudget.validate(field.NewPath("podDisruptionBudget"))...)
+	errs = append(errs, c.Telemetry.validate(field.NewPath("telemetry"))...)
+	errs = append(errs, c.Defaults.validate(field.NewPath("defaults"))...)
+	errs = append(errs, c.Overrides.validate(field.NewPath("overrides"))...)
+	errs = append(errs, c.Cleanup.validate(field.NewPath("cleanup"))...)
+	errs = append(errs, c.Ingress.validate(field.NewPath("ingress"))...)
+	return errs.ToAggregate()
+}
+
+func (c *IngressConfig) validate(iPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for class, pas := range c.ProtocolAnnotations {
+		for protocol := range pas {
+			if !slices.Contains(IngressProtocols, protocol) {
+				errs = append(errs, field.NotSupported(
+					iPath.Child("protocolAnnotations").Key(class).Key(protocol), protocol, IngressProtocols,
+				))
+			}
+		}
+	}
+	for class, p := range c.Profiles {
+		pPath := iPath.Child("profiles").Key(class)
+		for feature, annotations := range p.Annotations {
+			fPath := pPath.Child("annotations").Key(feature)
+			if !slices.Contains(IngressFeatures, feature) {
+				errs = append(errs, field.NotSupported(fPath, feature, IngressFeatures))
+			}
+			for k, v := range annotations {
+				if _, err := template.New("annotation").Parse(v); err != nil {
+					errs = append(errs, field.Invalid(fPath.Key(k), v, err.Error()))
+				}
+			}
+		}
+	}
+	return errs
+}
+
+func (c *CleanupConfig) validate(cPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if c.PrometheusURL != "" {
+		if u, err := url.Parse(c.PrometheusURL); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, field.Invalid(cPath.Child("prometheusURL"), c.PrometheusURL, "must be an absolute URL"))
+		}
+	}
+	if _, err := template.New("query").Parse(c.TrafficQuery); err != nil {
+		errs = append(errs, field.Invalid(cPath.Child("trafficQuery"), c.TrafficQuery, err.Error()))
+	}
+	if p := c.WarningPeriod; p != nil && p.Duration < 0 {
+		errs = append(errs, field.Invalid(cPath.Child("warningPeriod"), p.Duration.String(), "must not be negative"))
+	}
+	return errs
+}
+
+func (c *OverridesConfig) validate(oPath *field.Path) field.ErrorList {
+	if c == nil {
+		return nil
+	}
+
+	var errs field.ErrorList
+	for i, a := range c.Allowed {
+		aPath := oPath.Child("allowed").Index(i)
+		if !slices.Contains(OverrideKinds, a.Kind) {
+			errs = append(errs, field.NotSupported(aPath.Child("kind"), a.Kind, OverrideKinds))
+		}
+		for j, p := range a.Paths {
+			if !strings.HasPrefix(p, "/") {
+				errs = append(errs, field.Invalid(aPath.Child("paths").Index(j), p, "must be a JSON pointer starting with /"))
+			}
+		}
+	}
+	return errs
+}
+
+func (d *CapsuleDefaults) validate(dPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	errs = append(errs, d.CPU.validate(dPath.Child("cpu"))...)
+	errs = append(errs, d.Memory.validate(dPath.Child("memory"))...)
+	if p := d.Readiness; p != nil {
+		if p.Path != "" && p.TCP {
+			errs = append(errs, field.Forbidden(dPath.Child("readiness").Child("tcp"), "cannot be set together with path"))
+		} else if p.Path == "" && !p.TCP {
+			errs = append(errs, field.Required(dPath.Child("readiness"), "one of path and tcp must be set"))
+		}
+	}
+	return errs
+}
+
+func (r *ResourceDefaults) validate(rPath *field.Path) field.ErrorList {
+	if r == nil {
+		return nil
+	}
+
+	var errs field.ErrorList
+	if r.Request != nil && r.Limit != nil && r.Request.Cmp(*r.Limit) > 0 {
+		errs = append(errs, field.Invalid(rPath.Child("request"), r.Request.String(), "cannot be greater than limit"))
+	}
+	return errs
+}
+
+func (c *CertManagerConfig) validate(cPath *field.Path) field.ErrorList {
+	if c == nil {
+		return nil
+	}
+
+	var errs field.ErrorList
+	if c.CreateCertificateResources && c.ClusterIssuer == "" {
+		errs = append(errs, field.Required(
+			cPath.Child("clusterIssuer"), "required when createCertificateResources is enabled",
+		))
+	}
+	for i, w := range c.Wildcards {
+		wPath := cPath.Child("wildcards").Index(i)
+		if !c.CreateCertificateResources {
+			errs = append(errs, field.Forbidden(wPath, "requires createCertificateResources"))
+		}
+		for _, msg := range validation.IsDNS1123Subdomain(w.Domain) {
+			errs = append(errs, field.Invalid(wPath.Child("domain"), w.Domain, msg))
+		}
+	}
+	return errs
+}
+
+func (p *PodDisruptionBudgetConfig) validate(pPath *field.Path) field.ErrorList {
+	if p == nil {
+		return nil
+	}
+
+	var errs field.ErrorList
+	if p.MinAvailable != nil && p.MaxUnavailable != nil {
+		errs = append(errs, field.Forbidden(
+			pPath.Child("maxUnavailable"), "cannot be set together with minAvailable",
+		))
+	}
+	return errs
+}
+
+func (t *TelemetryConfig) validate(tPath *field.Path) field.ErrorList {
+	if t == nil {
+		return nil
+	}
+
+	var errs field.ErrorList
+	if t.Endpoint == "" {
+		errs = append(errs, field.Required(tPath.Child("endpoint"), ""))
+	} else if u, err := url.Parse(t.Endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+		errs = append(errs, field.Invalid(tPath.Child("endpoint"), t.Endpoint, "must be an absolute URL"))
+	}
+
+	switch t.Protocol {
+	case "grpc", "http/protobuf", "http/json":
+	default:
+		errs = append(errs, field.NotSupported(
+			tPath.Child("protocol"), t.Protocol, []string{"grpc", "http/protobuf", "http/json"},
+		))
+	}
+
+	if r := t.SamplingRatio; r != nil && (*r < 0 || *r > 1) {
+		errs = append(errs, field.Invalid(tPath.Child("samplingRatio"), *r, "must be between 0 and 1"))
+	}
+	return errs
+}
+
+// Validate returns an error if the config is semantically invalid. It
+// expects the config to be merged with the defaults and secrets.
+func (c *PlatformConfig) Validate() error {
+	var errs field.ErrorList
+
+	if c.Port <= 0 || c.Port > 65535 {
+		errs = append(errs, field.Invalid(field.NewPath("port"), c.Port, "must be between 1 and 65535"))
+	}
+
+	if c.PublicURL != "" {
+		if u, err := url.Parse(c.PublicURL); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, field.Invalid(field.NewPath("publicUrl"), c.PublicURL, "must be an absolute URL"))
+		}
+	}
+
+	errs = append(errs, c.Repository.validate(field.NewPath("repository"))...)
+	errs = append(errs, c.Cluster.validate(field.NewPath("cluster"))...)
+	errs = append(errs, c.Email.validate(field.NewPath("email"), &c.Client, field.NewPath("client"))...)
+
+	return errs.ToAggregate()
+}
+
+func (r *Repository) validate(rPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	switch r.Store {
+	case "postgres", "mongodb":
+	default:
+		errs = append(errs, field.NotSupported(rPath.Child("store"), r.Store, []string{"postgres", "mongodb"}))
+	}
+	return errs
+}
+
+func (c *Cluster) validate(cPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	switch c.Type {
+	case ClusterTypeDocker, ClusterTypeKubernetes:
+	default:
+		errs = append(errs, field.NotSupported(
+			cPath.Child("type"), c.Type, []string{string(ClusterTypeDocker), string(ClusterTypeKubernetes)},
+		))
+	}
+	return errs
+}
+
+func (e *Email) validate(ePath *field.Path, client *Client, cPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	switch e.Type {
+	case EmailTypeNoEmail:
+		return nil
+	case EmailTypeMailjet:
+		mPath := cPath.Child("mailjet")
+		if client.Mailjet.APIKey == "" {
+			errs = append(errs, field.Required(mPath.Child("apiKey"), "required when email type is mailjet"))
+		}
+		if client.Mailjet.SecretKey == "" {
+			errs = append(errs, field.Required(mPath.Child("secretKey"), "required when email type is mailjet"))
+		}
+	case EmailTypeSMTP:
+		sPath := cPath.Child("smtp")
+		if client.SMTP.Host == "" {
+			errs = append(errs, field.Required(sPath.Child("host"), "required when email type is smtp"))
+		}
+		if client.SMTP.Port <= 0 || client.SMTP.Port > 65535 {
+			errs = append(errs, field.Invalid(
+				sPath.Child("port"), client.SMTP.Port, "must be between 1 and 65535 when email type is smtp",
+			))
+		}
+	default:
+		errs = append(errs, field.NotSupported(
+			ePath.Child("type"), e.Type, []string{EmailTypeMailjet, EmailTypeSMTP},
+		))
+	}
+
+	if e.From == "" {
+		errs = append(errs, field.Required(ePath.Child("from"), "required when sending emails"))
+	}
+	return errs
+}
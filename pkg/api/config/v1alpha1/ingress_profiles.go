@@ -0,0 +1,64 @@
+package v1alpha1
+
+const (
+	IngressFeatureCORS         = "cors"
+	IngressFeatureRateLimit    = "rateLimit"
+	IngressFeatureSourceRanges = "sourceRanges"
+	IngressFeatureBasicAuth    = "basicAuth"
+	IngressFeatureMaxBodySize  = "maxBodySize"
+	IngressFeatureTimeout      = "timeout"
+)
+
+// IngressFeatures are the ingress features of interfaces, in the order they
+// are applied.
+var IngressFeatures = []string{
+	IngressFeatureSourceRanges,
+	IngressFeatureBasicAuth,
+	IngressFeatureRateLimit,
+	IngressFeatureCORS,
+	IngressFeatureMaxBodySize,
+	IngressFeatureTimeout,
+}
+
+// BuiltinIngressProfiles are the profiles used for the ingress classes
+// without a configured profile.
+var BuiltinIngressProfiles = map[string]IngressProfile{
+	"nginx": {
+		Annotations: map[string]map[string]string{
+			IngressFeatureCORS: {
+				"nginx.ingress.kubernetes.io/enable-cors":       "true",
+				"nginx.ingress.kubernetes.io/cors-allow-origin": "{{ .Origins }}",
+			},
+			IngressFeatureRateLimit: {
+				"nginx.ingress.kubernetes.io/limit-rps": "{{ .RequestsPerSecond }}",
+			},
+			IngressFeatureSourceRanges: {
+				"nginx.ingress.kubernetes.io/whitelist-source-range": "{{ .SourceRanges }}",
+			},
+			IngressFeatureBasicAuth: {
+				"nginx.ingress.kubernetes.io/auth-type":   "basic",
+				"nginx.ingress.kubernetes.io/auth-secret": "{{ .SecretName }}",
+			},
+			IngressFeatureMaxBodySize: {
+				"nginx.ingress.kubernetes.io/proxy-body-size": "{{ .MaxBodySizeBytes }}",
+			},
+			IngressFeatureTimeout: {
+				"nginx.ingress.kubernetes.io/proxy-read-timeout": "{{ .TimeoutSeconds }}",
+				"nginx.ingress.kubernetes.io/proxy-send-timeout": "{{ .TimeoutSeconds }}",
+			},
+		},
+	},
+	"traefik": {
+		TraefikMiddlewares: true,
+	},
+}
+
+// GetProfile returns the profile of the ingress class of the config, and
+// false if the class has no profile.
+func (c *IngressConfig) GetProfile() (IngressProfile, bool) {
+	if p, ok := c.Profiles[c.ClassName]; ok {
+		return p, true
+	}
+	p, ok := BuiltinIngressProfiles[c.ClassName]
+	return p, ok
+}
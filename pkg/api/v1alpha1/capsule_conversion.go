@@ -59,7 +59,8 @@ func (c *Capsule) ConvertTo(dstRaw conversion.Hub) error {
 			}
 			if i.Public.LoadBalancer != nil {
 				ni.Public.LoadBalancer = &v1alpha2.CapsuleInterfaceLoadBalancer{
-					Port: i.Public.LoadBalancer.Port,
+					Port:     i.Public.LoadBalancer.Port,
+					NodePort: i.Public.LoadBalancer.NodePort,
 				}
 			}
 		}
@@ -100,9 +101,56 @@ func (c *Capsule) ConvertTo(dstRaw conversion.Hub) error {
 		}
 	}
 
+	if l := srcSpec.Lifecycle; l != nil {
+		dst.Spec.Lifecycle = &v1alpha2.Lifecycle{
+			TerminationGracePeriod: l.TerminationGracePeriod,
+			DrainDelay:             l.DrainDelay,
+			PreStop:                convertLifecycleHookTo(l.PreStop),
+			PostStart:              convertLifecycleHookTo(l.PostStart),
+		}
+	}
+
 	return nil
 }
 
+func convertLifecycleHookTo(h *LifecycleHook) *v1alpha2.LifecycleHook {
+	if h == nil {
+		return nil
+	}
+
+	hook := &v1alpha2.LifecycleHook{}
+	if h.Command != nil {
+		hook.Exec = &v1alpha2.ExecHook{
+			Command: h.Command,
+		}
+	}
+	if h.HTTPGet != nil {
+		hook.HTTP = &v1alpha2.HTTPHook{
+			Path: h.HTTPGet.Path,
+			Port: h.HTTPGet.Port,
+		}
+	}
+	return hook
+}
+
+func convertLifecycleHookFrom(h *v1alpha2.LifecycleHook) *LifecycleHook {
+	if h == nil {
+		return nil
+	}
+
+	hook := &LifecycleHook{}
+	if h.Exec != nil {
+		hook.Command = h.Exec.Command
+	}
+	if h.HTTP != nil {
+		hook.HTTPGet = &HTTPGetHook{
+			Path: h.HTTP.Path,
+			Port: h.HTTP.Port,
+		}
+	}
+	return hook
+}
+
 // ConvertFrom converts from the Hub version (v1) to this version.
 func (c *Capsule) ConvertFrom(srcRaw conversion.Hub) error {
 	src := srcRaw.(*v1alpha2.Capsule)
@@ -155,7 +203,8 @@ func (c *Capsule) ConvertFrom(srcRaw conversion.Hub) error {
 			}
 			if i.Public.LoadBalancer != nil {
 				ni.Public.LoadBalancer = &CapsuleInterfaceLoadBalancer{
-					Port: i.Public.LoadBalancer.Port,
+					Port:     i.Public.LoadBalancer.Port,
+					NodePort: i.Public.LoadBalancer.NodePort,
 				}
 			}
 		}
@@ -200,5 +249,14 @@ func (c *Capsule) ConvertFrom(srcRaw conversion.Hub) error {
 		}
 	}
 
+	if l := srcSpec.Lifecycle; l != nil {
+		dst.Spec.Lifecycle = &Lifecycle{
+			TerminationGracePeriod: l.TerminationGracePeriod,
+			DrainDelay:             l.DrainDelay,
+			PreStop:                convertLifecycleHookFrom(l.PreStop),
+			PostStart:              convertLifecycleHookFrom(l.PostStart),
+		}
+	}
+
 	return nil
 }
@@ -0,0 +1,58 @@
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rigdev/rig/pkg/api/v1alpha2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestConvertLifecycle(t *testing.T) {
+	t.Parallel()
+
+	lifecycle := &v1alpha2.Lifecycle{
+		TerminationGracePeriod: &metav1.Duration{Duration: time.Minute},
+		DrainDelay:             &metav1.Duration{Duration: 5 * time.Second},
+		PreStop: &v1alpha2.LifecycleHook{
+			Exec: &v1alpha2.ExecHook{
+				Command: []string{"/bin/shutdown", "--graceful"},
+			},
+		},
+		PostStart: &v1alpha2.LifecycleHook{
+			HTTP: &v1alpha2.HTTPHook{
+				Path: "/started",
+				Port: 8080,
+			},
+		},
+	}
+
+	hub := &v1alpha2.Capsule{
+		Spec: v1alpha2.CapsuleSpec{
+			Image:     "nginx",
+			Lifecycle: lifecycle,
+		},
+	}
+
+	c := &Capsule{}
+	require.NoError(t, c.ConvertFrom(hub))
+	assert.Equal(t, &Lifecycle{
+		TerminationGracePeriod: &metav1.Duration{Duration: time.Minute},
+		DrainDelay:             &metav1.Duration{Duration: 5 * time.Second},
+		PreStop: &LifecycleHook{
+			Command: []string{"/bin/shutdown", "--graceful"},
+		},
+		PostStart: &LifecycleHook{
+			HTTPGet: &HTTPGetHook{
+				Path: "/started",
+				Port: 8080,
+			},
+		},
+	}, c.Spec.Lifecycle)
+
+	res := &v1alpha2.Capsule{}
+	require.NoError(t, c.ConvertTo(res))
+	assert.Equal(t, lifecycle, res.Spec.Lifecycle)
+}
@@ -52,6 +52,51 @@ type CapsuleSpec struct {
 
 	// NodeSelector is a selector for what nodes the Capsule should live on.
 	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Lifecycle specifies how the Capsule instances should be started and
+	// gracefully shut down.
+	Lifecycle *Lifecycle `json:"lifecycle,omitempty"`
+}
+
+// Lifecycle specifies termination behaviour and lifecycle hooks of the
+// Capsule instances.
+type Lifecycle struct {
+	// TerminationGracePeriod is how long an instance is given to shut down
+	// after receiving SIGTERM, before it is killed.
+	TerminationGracePeriod *metav1.Duration `json:"terminationGracePeriod,omitempty"`
+
+	// DrainDelay delays SIGTERM by the given duration when an instance is
+	// stopped.
+	DrainDelay *metav1.Duration `json:"drainDelay,omitempty"`
+
+	// PreStop is called before an instance receives SIGTERM.
+	PreStop *LifecycleHook `json:"preStop,omitempty"`
+
+	// PostStart is called right after an instance is started.
+	PostStart *LifecycleHook `json:"postStart,omitempty"`
+}
+
+// LifecycleHook specifies an action to run at a point in the lifecycle of a
+// Capsule instance.
+type LifecycleHook struct {
+	// Command is a command line to execute in the container. The Command
+	// field is mutually exclusive with HTTPGet.
+	Command []string `json:"command,omitempty"`
+
+	// HTTPGet specifies an HTTP GET request to send to the container. The
+	// HTTPGet field is mutually exclusive with Command.
+	HTTPGet *HTTPGetHook `json:"httpGet,omitempty"`
+}
+
+// HTTPGetHook specifies an HTTP GET request to send to the container.
+type HTTPGetHook struct {
+	// Path is the HTTP path to request.
+	Path string `json:"path"`
+
+	// Port is the container port to send the request to.
+	//+kubebuilder:validation:Minimum=1
+	//+kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port"`
 }
 
 // CapsuleInterface defines an interface for a capsule
@@ -0,0 +1,159 @@
+package v1alpha2
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	configv1alpha1 "github.com/rigdev/rig/pkg/api/config/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	v1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"sigs.k8s.io/yaml"
+)
+
+// Apply applies the patch of the override to the object, which must be a
+// pointer to a struct.
+func (o Override) Apply(obj runtime.Object) error {
+	patch, err := yaml.YAMLToJSON([]byte(o.Patch))
+	if err != nil {
+		return fmt.Errorf("could not decode patch: %w", err)
+	}
+
+	original, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+
+	var patched []byte
+	switch o.Type {
+	case OverrideTypeJSON:
+		p, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return fmt.Errorf("could not decode patch: %w", err)
+		}
+		if patched, err = p.Apply(original); err != nil {
+			return fmt.Errorf("could not apply patch: %w", err)
+		}
+	default:
+		if patched, err = strategicpatch.StrategicMergePatch(original, patch, obj); err != nil {
+			return fmt.Errorf("could not apply patch: %w", err)
+		}
+	}
+
+	// Reset the object, so fields removed by the patch are removed.
+	v := reflect.ValueOf(obj).Elem()
+	v.Set(reflect.Zero(v.Type()))
+	if err := json.Unmarshal(patched, obj); err != nil {
+		return fmt.Errorf("could not decode patched %s: %w", o.Kind, err)
+	}
+	return nil
+}
+
+// Paths returns the JSON pointers of the fields changed by the patch of the
+// override, sorted. A list is changed as a whole by a StrategicMerge patch.
+func (o Override) Paths() ([]string, error) {
+	patch, err := yaml.YAMLToJSON([]byte(o.Patch))
+	if err != nil {
+		return nil, fmt.Errorf("could not decode patch: %w", err)
+	}
+
+	var paths []string
+	switch o.Type {
+	case OverrideTypeJSON:
+		p, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return nil, fmt.Errorf("could not decode patch: %w", err)
+		}
+		if len(p) == 0 {
+			return nil, errors.New("patch has no operations")
+		}
+		for _, op := range p {
+			path, err := op.Path()
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, path)
+			if from, err := op.From(); err == nil {
+				paths = append(paths, from)
+			}
+		}
+	default:
+		var m map[string]any
+		if err := json.Unmarshal(patch, &m); err != nil {
+			return nil, errors.New("patch must be an object")
+		}
+		paths = mergePatchPaths("", m, paths)
+	}
+
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// mergePatchPaths appends the JSON pointers of the leaves of the merge patch.
+// Directives, such as $patch, apply to the object they are part of.
+func mergePatchPaths(prefix string, m map[string]any, paths []string) []string {
+	for k, v := range m {
+		if strings.HasPrefix(k, "$") {
+			if prefix == "" {
+				paths = append(paths, "/")
+			} else {
+				paths = append(paths, prefix)
+			}
+			continue
+		}
+
+		path := prefix + "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(k)
+		if child, ok := v.(map[string]any); ok && len(child) > 0 {
+			paths = mergePatchPaths(path, child, paths)
+			continue
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// CheckAllowed returns an error if the override changes fields which are not
+// allowed by the config.
+func (o Override) CheckAllowed(cfg *configv1alpha1.OverridesConfig) error {
+	paths, err := o.Paths()
+	if err != nil {
+		return err
+	}
+
+	var disallowed []string
+	for _, p := range paths {
+		if !cfg.IsAllowed(o.Kind, p) {
+			disallowed = append(disallowed, p)
+		}
+	}
+	if len(disallowed) > 0 {
+		return fmt.Errorf("patching %s %s is not allowed by the operator config", o.Kind, strings.Join(disallowed, ", "))
+	}
+	return nil
+}
+
+// NewOverrideObject returns an empty object of the kind of the override.
+func NewOverrideObject(kind string) (runtime.Object, error) {
+	switch kind {
+	case "Deployment":
+		return &appsv1.Deployment{}, nil
+	case "Service":
+		return &v1.Service{}, nil
+	case "Ingress":
+		return &netv1.Ingress{}, nil
+	case "HorizontalPodAutoscaler":
+		return &autoscalingv2.HorizontalPodAutoscaler{}, nil
+	case "ServiceAccount":
+		return &v1.ServiceAccount{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported override kind '%s'", kind)
+	}
+}
@@ -0,0 +1,209 @@
+package v1alpha2
+
+import (
+	"testing"
+
+	configv1alpha1 "github.com/rigdev/rig/pkg/api/config/v1alpha1"
+	"github.com/rigdev/rig/pkg/ptr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestOverrideApply(t *testing.T) {
+	t.Parallel()
+	deployment := func() *appsv1.Deployment {
+		return &appsv1.Deployment{
+			Spec: appsv1.DeploymentSpec{
+				Replicas: ptr.New(int32(2)),
+				Template: v1.PodTemplateSpec{
+					Spec: v1.PodSpec{
+						Containers: []v1.Container{{Name: "api", Image: "api:1"}},
+					},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		override Override
+		expected func(d *appsv1.Deployment)
+	}{
+		{
+			name: "strategic merge",
+			override: Override{
+				Kind: "Deployment",
+				Patch: `spec:
+  template:
+    spec:
+      hostAliases:
+      - ip: 10.0.0.1
+        hostnames: [db.local]
+      containers:
+      - name: api
+        stdin: true`,
+			},
+			expected: func(d *appsv1.Deployment) {
+				d.Spec.Template.Spec.HostAliases = []v1.HostAlias{{IP: "10.0.0.1", Hostnames: []string{"db.local"}}}
+				d.Spec.Template.Spec.Containers[0].Stdin = true
+			},
+		},
+		{
+			name: "json",
+			override: Override{
+				Kind: "Deployment",
+				Type: OverrideTypeJSON,
+				Patch: `[
+  {"op": "remove", "path": "/spec/replicas"},
+  {"op": "add", "path": "/spec/template/spec/containers/0/args", "value": ["serve"]}
+]`,
+			},
+			expected: func(d *appsv1.Deployment) {
+				d.Spec.Replicas = nil
+				d.Spec.Template.Spec.Containers[0].Args = []string{"serve"}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := deployment()
+			require.NoError(t, tt.override.Apply(d))
+
+			expected := deployment()
+			tt.expected(expected)
+			assert.Equal(t, expected, d)
+		})
+	}
+}
+
+func TestOverridePaths(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		override Override
+		expected []string
+		err      string
+	}{
+		{
+			name: "strategic merge",
+			override: Override{Patch: `metadata:
+  annotations:
+    example.com/a~b: "1"
+spec:
+  $patch: replace
+  template:
+    spec:
+      hostAliases: []`},
+			expected: []string{
+				"/metadata/annotations/example.com~1a~0b",
+				"/spec",
+				"/spec/template/spec/hostAliases",
+			},
+		},
+		{
+			name: "json",
+			override: Override{Type: OverrideTypeJSON, Patch: `[
+  {"op": "move", "from": "/spec/a", "path": "/spec/b"}
+]`},
+			expected: []string{"/spec/a", "/spec/b"},
+		},
+		{
+			name:     "strategic merge must be an object",
+			override: Override{Patch: `[1, 2]`},
+			err:      "patch must be an object",
+		},
+		{
+			name:     "json must have operations",
+			override: Override{Type: OverrideTypeJSON, Patch: `[]`},
+			err:      "patch has no operations",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths, err := tt.override.Paths()
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, paths)
+		})
+	}
+}
+
+func Test_OverridesValidate(t *testing.T) {
+	t.Parallel()
+	path := field.NewPath("spec").Child("overrides")
+	cfg := &configv1alpha1.OverridesConfig{
+		Allowed: []configv1alpha1.AllowedOverride{{
+			Kind:  "Deployment",
+			Paths: []string{"/spec/template/spec/hostAliases", "/metadata/annotations"},
+		}},
+	}
+	tests := []struct {
+		name         string
+		cfg          *configv1alpha1.OverridesConfig
+		overrides    []Override
+		expectedErrs field.ErrorList
+	}{
+		{
+			name: "no overrides",
+		},
+		{
+			name: "overrides are not allowed without config",
+			overrides: []Override{
+				{Kind: "Deployment", Patch: `{"metadata": {"annotations": {"a": "b"}}}`},
+			},
+			expectedErrs: field.ErrorList{
+				field.Forbidden(path.Index(0).Child("patch"), ""),
+			},
+		},
+		{
+			name: "invalid overrides",
+			cfg:  cfg,
+			overrides: []Override{
+				{Kind: "Pod", Patch: `{}`},
+				{Kind: "Deployment", Patch: `{"spec": {"replicas": 3}}`},
+				{Kind: "Deployment", Patch: `{"metadata": {"annotations": "a"}}`},
+				{Kind: "Deployment", Type: OverrideTypeJSON, Patch: `{"op": "add"}`},
+			},
+			expectedErrs: field.ErrorList{
+				field.NotSupported(path.Index(0).Child("kind"), "Pod", configv1alpha1.OverrideKinds),
+				field.Forbidden(path.Index(1).Child("patch"), ""),
+				field.Invalid(path.Index(2).Child("patch"), "", ""),
+				field.Invalid(path.Index(3).Child("patch"), "", ""),
+			},
+		},
+		{
+			name: "good",
+			cfg:  cfg,
+			overrides: []Override{
+				{Kind: "Deployment", Patch: `{"spec": {"template": {"spec": {"hostAliases": []}}}}`},
+				{
+					Kind:  "Deployment",
+					Type:  OverrideTypeJSON,
+					Patch: `[{"op": "add", "path": "/metadata/annotations/a", "value": "b"}]`,
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Capsule{Spec: CapsuleSpec{Overrides: tt.overrides}}
+			errs := c.validateOverrides(tt.cfg)
+			// The details of the errors come from the patch libraries, so
+			// only the fields and types are compared.
+			require.Len(t, errs, len(tt.expectedErrs))
+			for i, err := range errs {
+				assert.Equal(t, tt.expectedErrs[i].Type, err.Type)
+				assert.Equal(t, tt.expectedErrs[i].Field, err.Field)
+			}
+		})
+	}
+}
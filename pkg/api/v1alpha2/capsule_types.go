@@ -1,10 +1,14 @@
 package v1alpha2
 
 import (
+	"strings"
+
+	monitorv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // CapsuleSpec defines the desired state of Capsule
@@ -12,6 +16,16 @@ type CapsuleSpec struct {
 	// Image specifies what image the Capsule should run.
 	Image string `json:"image"`
 
+	// Template references a CapsuleTemplate or ClusterCapsuleTemplate which
+	// the spec of the Capsule is merged onto. Fields set in the Capsule take
+	// precedence over the template: Interfaces are merged by name, with an
+	// interface of the Capsule replacing the template interface of the same
+	// name, NodeSelector is merged by key, and the CPU, Memory and GPU of
+	// Scale.Vertical are merged by resource. Any other field set in the
+	// Capsule replaces the field of the template as a whole. The capsule
+	// defaults of the operator config are applied after the merge.
+	Template *TemplateReference `json:"template,omitempty"`
+
 	// Command is run as a command in the shell. If left unspecified, the
 	// container will run using what is specified as ENTRYPOINT in the
 	// Dockerfile.
@@ -37,9 +51,335 @@ type CapsuleSpec struct {
 	// NodeSelector is a selector for what nodes the Capsule should live on.
 	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
 
+	// Scheduling specifies how the instances of the Capsule should be placed
+	// on the nodes of the cluster, beyond what is possible with NodeSelector.
+	Scheduling *Scheduling `json:"scheduling,omitempty"`
+
 	// Env specifies configuration for how the container should obtain
 	// environment variables.
 	Env *Env `json:"env,omitempty"`
+
+	// Lifecycle specifies how the Capsule instances should be started and
+	// gracefully shut down.
+	Lifecycle *Lifecycle `json:"lifecycle,omitempty"`
+
+	// Rollout specifies how new versions of the Capsule are rolled out.
+	Rollout *Rollout `json:"rollout,omitempty"`
+
+	// Monitoring specifies how the Capsule is monitored by a Prometheus
+	// Operator stack. Overrides the prometheusServiceMonitor operator config.
+	Monitoring *Monitoring `json:"monitoring,omitempty"`
+
+	// DependsOn lists the capsules, in the namespace of the Capsule, which
+	// must be ready before the Capsule is started. Until then the Deployment
+	// of the Capsule is held at zero instances. Dependencies are only awaited
+	// when the Capsule is first deployed, so a running Capsule is not scaled
+	// down if a dependency later becomes unavailable.
+	DependsOn []CapsuleDependency `json:"dependsOn,omitempty"`
+
+	// Connections are interfaces of other capsules, in the namespace of the
+	// Capsule, which the Capsule connects to. The address of each interface
+	// is set as environment variables of the Capsule.
+	Connections []Connection `json:"connections,omitempty"`
+
+	// GeneratedSecrets are random values and key pairs which are generated
+	// once and stored in the Secret <capsule name>-generated, owned by the
+	// Capsule. The Secret can be used by Env and Files like any other Secret.
+	// Values are not rotated, unless their keys are listed in the
+	// rig.dev/rotate-generated-secrets annotation of the Capsule.
+	GeneratedSecrets []GeneratedSecret `json:"generatedSecrets,omitempty"`
+
+	// Overrides are patches of the resources created for the Capsule, for
+	// fields which are not modelled by the Capsule. The fields which may be
+	// patched are restricted by the overrides operator config.
+	Overrides []Override `json:"overrides,omitempty"`
+
+	// Issuer is the cert-manager issuer of the certificates of the
+	// interfaces with an ingress, overriding the clusterIssuer and the
+	// wildcard certificates of the operator config.
+	Issuer *IssuerReference `json:"issuer,omitempty"`
+
+	// TTL specifies when the Capsule expires. An expired Capsule is deleted
+	// by the operator, along with the resources created for it. The Capsule
+	// does not expire before the time in its rig.dev/lease-extended-until
+	// annotation, if any.
+	TTL *CapsuleTTL `json:"ttl,omitempty"`
+}
+
+// AnnotationLeaseExtendedUntil is the capsule annotation extending the lease
+// of a Capsule with a TTL. It holds an RFC 3339 time before which the Capsule
+// does not expire.
+const AnnotationLeaseExtendedUntil = "rig.dev/lease-extended-until"
+
+// CapsuleTTL specifies when a Capsule expires. At least one of ExpiresAt and
+// IdleTimeout must be set. If both are set, the Capsule expires at whichever
+// comes first.
+type CapsuleTTL struct {
+	// ExpiresAt is when the Capsule expires.
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// IdleTimeout is how long the Capsule can receive no ingress traffic
+	// before it expires. The traffic is queried from the Prometheus of the
+	// cleanup operator config, and idle timeouts are not enforced without it.
+	// The default traffic query counts requests through ingress-nginx only,
+	// so traffic to the canary backend of a TrafficSplit or through a
+	// Gateway does not keep the Capsule alive.
+	IdleTimeout *metav1.Duration `json:"idleTimeout,omitempty"`
+}
+
+// Override is a patch of a resource created for the Capsule. The patch is
+// applied after the resource is built from the Capsule.
+type Override struct {
+	// Kind is the kind of the resource to patch. A Service override patches
+	// the Service of the interfaces, and not the LoadBalancer Service. An
+	// Ingress override patches all Ingresses of the Capsule.
+	//+kubebuilder:validation:Enum=Deployment;Service;Ingress;HorizontalPodAutoscaler;ServiceAccount
+	Kind string `json:"kind"`
+
+	// Type is the type of the patch. Defaults to StrategicMerge.
+	//+kubebuilder:validation:Enum=StrategicMerge;JSON
+	Type OverrideType `json:"type,omitempty"`
+
+	// Patch is the patch, as YAML or JSON. A StrategicMerge patch is a
+	// partial resource, and a JSON patch is a list of RFC 6902 operations.
+	Patch string `json:"patch"`
+}
+
+// OverrideType is the type of the patch of an override.
+type OverrideType string
+
+const (
+	OverrideTypeStrategicMerge OverrideType = "StrategicMerge"
+	OverrideTypeJSON           OverrideType = "JSON"
+)
+
+// GeneratedSecret is a value generated by the operator.
+type GeneratedSecret struct {
+	// Key is the key of the value in the Secret. For key pairs, Key holds the
+	// PEM encoded private key and <Key>_PUB holds the PEM encoded public key.
+	Key string `json:"key"`
+
+	// Type is the type of value to generate. Random generates a random
+	// string, and RSA and Ed25519 generate a key pair. Defaults to Random.
+	//+kubebuilder:validation:Enum=Random;RSA;Ed25519
+	Type GeneratedSecretType `json:"type,omitempty"`
+
+	// Length is the length of a Random value, at most 4096. Defaults to 32.
+	//+kubebuilder:validation:Maximum=4096
+	Length int32 `json:"length,omitempty"`
+
+	// Charset holds the distinct characters of a Random value. Defaults to
+	// letters and digits.
+	Charset string `json:"charset,omitempty"`
+
+	// Bits is the size of an RSA key. Defaults to 2048.
+	Bits int32 `json:"bits,omitempty"`
+}
+
+// GeneratedSecretType is the type of a generated secret.
+type GeneratedSecretType string
+
+const (
+	GeneratedSecretTypeRandom  GeneratedSecretType = "Random"
+	GeneratedSecretTypeRSA     GeneratedSecretType = "RSA"
+	GeneratedSecretTypeEd25519 GeneratedSecretType = "Ed25519"
+)
+
+// Keys returns the keys of the Secret holding the generated value.
+func (g GeneratedSecret) Keys() []string {
+	switch g.Type {
+	case GeneratedSecretTypeRSA, GeneratedSecretTypeEd25519:
+		return []string{g.Key, g.Key + "_PUB"}
+	default:
+		return []string{g.Key}
+	}
+}
+
+// Connection is an interface of another capsule. The address of the
+// interface is set as the environment variables <NAME>_HOST, <NAME>_PORT and
+// <NAME>_URL, where <NAME> is the upper-cased name of the connection with
+// dashes replaced by underscores. Variables provided by the env sources of
+// the Capsule are not overwritten.
+type Connection struct {
+	// Name is the name of the connection, used as prefix of the environment
+	// variables. Defaults to the name of the capsule.
+	Name string `json:"name,omitempty"`
+
+	// Capsule is the name of the capsule to connect to.
+	Capsule string `json:"capsule"`
+
+	// Interface is the name of the interface of the capsule to connect to.
+	Interface string `json:"interface"`
+
+	// Scheme is the scheme of <NAME>_URL. Defaults to http.
+	Scheme string `json:"scheme,omitempty"`
+}
+
+// EnvPrefix returns the prefix of the environment variables of the
+// connection.
+func (c Connection) EnvPrefix() string {
+	name := c.Name
+	if name == "" {
+		name = c.Capsule
+	}
+	return strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+}
+
+// CapsuleDependency is a capsule which must be ready before the Capsule is
+// started.
+type CapsuleDependency struct {
+	// Capsule is the name of the capsule.
+	Capsule string `json:"capsule"`
+
+	// Interface is the name of an interface of the capsule. If set, the
+	// capsule must have the interface, and it is ready when an instance
+	// passes the readiness probe of the interface.
+	Interface string `json:"interface,omitempty"`
+}
+
+// Monitoring specifies how the metrics of the Capsule are scraped and which
+// alert rules should be created for it.
+type Monitoring struct {
+	// Disabled disables scraping of the metrics of the Capsule.
+	Disabled bool `json:"disabled,omitempty"`
+
+	// Kind is the kind of monitor to create. A ServiceMonitor requires the
+	// Capsule to have interfaces, while a PodMonitor scrapes the instances
+	// directly. Defaults to ServiceMonitor.
+	//+kubebuilder:validation:Enum=ServiceMonitor;PodMonitor
+	Kind string `json:"kind,omitempty"`
+
+	// Port is the name of the interface to scrape metrics on, or, for a
+	// PodMonitor, a container port number. Defaults to the portName of the
+	// prometheusServiceMonitor operator config.
+	Port *intstr.IntOrString `json:"port,omitempty"`
+
+	// Path is the HTTP path to scrape metrics on. Defaults to the path of the
+	// prometheusServiceMonitor operator config, or /metrics.
+	Path string `json:"path,omitempty"`
+
+	// Interval is the interval at which metrics are scraped. Defaults to the
+	// scrape interval of Prometheus.
+	Interval *metav1.Duration `json:"interval,omitempty"`
+
+	// RelabelConfigs are applied to the labels of the target before
+	// scraping.
+	RelabelConfigs []monitorv1.RelabelConfig `json:"relabelConfigs,omitempty"`
+
+	// MetricRelabelConfigs are applied to the scraped samples before
+	// ingestion.
+	MetricRelabelConfigs []monitorv1.RelabelConfig `json:"metricRelabelConfigs,omitempty"`
+
+	// AlertRules are rendered into a PrometheusRule owned by the Capsule.
+	AlertRules []AlertRule `json:"alertRules,omitempty"`
+}
+
+// AlertRule is a Prometheus alerting rule.
+type AlertRule struct {
+	// Name is the name of the alert.
+	Name string `json:"name"`
+
+	// Expr is the PromQL expression to evaluate.
+	Expr string `json:"expr"`
+
+	// For is how long the expression must be true before the alert fires.
+	For *metav1.Duration `json:"for,omitempty"`
+
+	// Labels are added to the alert.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations are added to the alert.
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Rollout specifies the rolling update parameters used when the instances of
+// the Capsule are replaced.
+type Rollout struct {
+	// MaxSurge is the maximum number of instances that can be created above
+	// the desired number of instances during a rollout. Can be an absolute
+	// number or a percentage. Defaults to 25%.
+	MaxSurge *intstr.IntOrString `json:"maxSurge,omitempty"`
+
+	// MaxUnavailable is the maximum number of instances that can be
+	// unavailable during a rollout. Can be an absolute number or a
+	// percentage. Defaults to 25%.
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+
+	// ProgressDeadlineSeconds is the number of seconds a rollout can make no
+	// progress before it is considered stalled. Defaults to 600 seconds.
+	//+kubebuilder:validation:Minimum=1
+	ProgressDeadlineSeconds *int32 `json:"progressDeadlineSeconds,omitempty"`
+
+	// MinReadySeconds is the number of seconds a new instance must be ready,
+	// without any of its containers crashing, before it is considered
+	// available.
+	//+kubebuilder:validation:Minimum=0
+	MinReadySeconds int32 `json:"minReadySeconds,omitempty"`
+
+	// AutoRollback enables automatic rollback of rollouts that fail.
+	AutoRollback *AutoRollback `json:"autoRollback,omitempty"`
+}
+
+// AutoRollback specifies when a rollout should be rolled back to the previous
+// version of the Capsule. A rollout is rolled back when an instance of the new
+// version restarts MaxRestarts times or when the rollout exceeds its progress
+// deadline. The rollback is kept until the instances would change again, e.g.
+// by a change to the Capsule or to the config and secrets it uses.
+type AutoRollback struct {
+	// MaxRestarts is the number of container restarts an instance of the new
+	// version can have before the rollout is rolled back. Defaults to 3.
+	//+kubebuilder:validation:Minimum=1
+	MaxRestarts *int32 `json:"maxRestarts,omitempty"`
+}
+
+// Lifecycle specifies termination behaviour and lifecycle hooks of the
+// Capsule instances.
+type Lifecycle struct {
+	// TerminationGracePeriod is how long an instance is given to shut down
+	// after receiving SIGTERM, before it is killed. Defaults to 30 seconds
+	// plus the DrainDelay.
+	TerminationGracePeriod *metav1.Duration `json:"terminationGracePeriod,omitempty"`
+
+	// DrainDelay delays SIGTERM by the given duration when an instance is
+	// stopped, giving load balancers time to stop sending traffic to it. The
+	// delay runs before the PreStop hook. DrainDelay cannot be used together
+	// with an HTTP PreStop hook. The delay runs `sh -c "sleep <seconds>"` in
+	// the container, so the image must contain a shell and a sleep command,
+	// and the delay must be a whole number of seconds.
+	DrainDelay *metav1.Duration `json:"drainDelay,omitempty"`
+
+	// PreStop is called before an instance receives SIGTERM.
+	PreStop *LifecycleHook `json:"preStop,omitempty"`
+
+	// PostStart is called right after an instance is started.
+	PostStart *LifecycleHook `json:"postStart,omitempty"`
+}
+
+// LifecycleHook specifies an action to run at a point in the lifecycle of a
+// Capsule instance. Exactly one of Exec and HTTP must be set.
+type LifecycleHook struct {
+	// Exec runs a command in the container.
+	Exec *ExecHook `json:"exec,omitempty"`
+
+	// HTTP sends an HTTP GET request to the container.
+	HTTP *HTTPHook `json:"http,omitempty"`
+}
+
+// ExecHook specifies a command to run in the container.
+type ExecHook struct {
+	// Command is the command line to execute. It is not run in a shell.
+	Command []string `json:"command"`
+}
+
+// HTTPHook specifies an HTTP GET request to send to the container.
+type HTTPHook struct {
+	// Path is the HTTP path to request.
+	Path string `json:"path"`
+
+	// Port is the container port to send the request to.
+	//+kubebuilder:validation:Minimum=1
+	//+kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port"`
 }
 
 // Env defines what secrets and configmaps should be used for environment
@@ -53,6 +393,10 @@ type Env struct {
 	// From holds a list of references to secrets and configmaps which should
 	// be mounted as environment variables.
 	From []EnvReference `json:"from,omitempty"`
+
+	// DisableTelemetry disables the OTEL_* environment variables injected
+	// when telemetry is configured for the operator.
+	DisableTelemetry bool `json:"disableTelemetry,omitempty"`
 }
 
 // EnvSource holds a reference to either a ConfigMap or a Secret
@@ -63,6 +407,48 @@ type EnvReference struct {
 	Name string `json:"name"`
 }
 
+// Scheduling specifies tolerations, affinity, topology spread and priority of
+// the Capsule instances.
+type Scheduling struct {
+	// Tolerations allows the Capsule instances to be scheduled on nodes with
+	// matching taints. These are added to the default tolerations of the
+	// operator.
+	Tolerations []v1.Toleration `json:"tolerations,omitempty"`
+
+	// Affinity specifies node and pod affinity of the Capsule instances.
+	Affinity *v1.Affinity `json:"affinity,omitempty"`
+
+	// Spread is a shorthand for spreading the Capsule instances across nodes
+	// and zones. It is rendered as topology spread constraints with a max
+	// skew of one, in addition to TopologySpreadConstraints.
+	Spread *Spread `json:"spread,omitempty"`
+
+	// TopologySpreadConstraints specifies how the Capsule instances should be
+	// spread across topology domains. If omitted, the default constraints of
+	// the operator are used. If no label selector is given, the instances of
+	// the Capsule are selected.
+	TopologySpreadConstraints []v1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+
+	// PriorityClassName is the name of the PriorityClass to assign to the
+	// Capsule instances.
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+}
+
+// Spread specifies that the Capsule instances should be spread across nodes
+// and/or zones.
+type Spread struct {
+	// Nodes spreads the instances across nodes.
+	Nodes bool `json:"nodes,omitempty"`
+
+	// Zones spreads the instances across zones.
+	Zones bool `json:"zones,omitempty"`
+
+	// Required makes the spread a hard requirement. If not set, the spread is
+	// only preferred, and instances are still scheduled if it cannot be
+	// satisfied.
+	Required bool `json:"required,omitempty"`
+}
+
 // CapsuleScale specifies the horizontal and vertical scaling of the Capsule.
 type CapsuleScale struct {
 	// Horizontal specifies the horizontal scaling of the Capsule.
@@ -86,6 +472,27 @@ type HorizontalScale struct {
 	// CustomMetrics specifies custom metrics emitted by the custom.metrics.k8s.io API
 	// which the autoscaler should scale on
 	CustomMetrics []CustomMetric `json:"customMetrics,omitempty"`
+
+	// DisruptionBudget overrides the default PodDisruptionBudget policy of the
+	// operator for this Capsule. A PodDisruptionBudget is only created when
+	// the Capsule has more than one instance.
+	DisruptionBudget *DisruptionBudget `json:"disruptionBudget,omitempty"`
+}
+
+// DisruptionBudget specifies how many instances of the Capsule can be
+// unavailable due to voluntary disruptions, such as node drains.
+// At most one of MinAvailable and MaxUnavailable can be set.
+type DisruptionBudget struct {
+	// Disabled disables the PodDisruptionBudget for the Capsule.
+	Disabled bool `json:"disabled,omitempty"`
+
+	// MinAvailable is the number or percentage of instances which must
+	// still be available after an eviction.
+	MinAvailable *intstr.IntOrString `json:"minAvailable,omitempty"`
+
+	// MaxUnavailable is the number or percentage of instances which can be
+	// unavailable after an eviction.
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
 }
 
 // Instances specifies the minimum and maximum amount of capsule
@@ -186,6 +593,15 @@ type CapsuleInterface struct {
 	//+kubebuilder:validation:Maximum=65535
 	Port int32 `json:"port"`
 
+	// Protocol is the protocol of the interface. UDP interfaces use UDP
+	// ports, and all others use TCP ports. The application protocols HTTP,
+	// HTTP2 and GRPC are set as appProtocol on the ports of the Services, and
+	// configure the ingress controller to connect to the interface using the
+	// protocol. Interfaces without a protocol use TCP ports and are published
+	// as HTTP on ingress.
+	//+kubebuilder:validation:Enum=TCP;UDP;HTTP;HTTP2;GRPC
+	Protocol InterfaceProtocol `json:"protocol,omitempty"`
+
 	// Liveness specifies that this interface should be used for
 	// liveness probing. Only one of the Capsule interfaces can be
 	// used as liveness probe.
@@ -200,6 +616,26 @@ type CapsuleInterface struct {
 	Public *CapsulePublicInterface `json:"public,omitempty"`
 }
 
+// InterfaceProtocol is the protocol of an interface.
+type InterfaceProtocol string
+
+const (
+	InterfaceProtocolTCP   InterfaceProtocol = "TCP"
+	InterfaceProtocolUDP   InterfaceProtocol = "UDP"
+	InterfaceProtocolHTTP  InterfaceProtocol = "HTTP"
+	InterfaceProtocolHTTP2 InterfaceProtocol = "HTTP2"
+	InterfaceProtocolGRPC  InterfaceProtocol = "GRPC"
+)
+
+// GetIngressProtocol returns the protocol used by ingress controllers to
+// connect to the interface. Interfaces without a protocol are HTTP.
+func (i CapsuleInterface) GetIngressProtocol() InterfaceProtocol {
+	if i.Protocol == "" {
+		return InterfaceProtocolHTTP
+	}
+	return i.Protocol
+}
+
 // InterfaceProbe specifies an interface probe
 type InterfaceProbe struct {
 	// Path is the HTTP path of the probe. Path is mutually
@@ -235,9 +671,82 @@ type CapsulePublicInterface struct {
 
 // CapsuleInterfaceIngress defines that the interface should be exposed as http
 // ingress
+//
+// The features of the ingress, such as CORS and rate limits, are translated
+// to the ingress controller of the ingress class by its profile in the
+// operator config. Interfaces with the same features share an Ingress named
+// after the Capsule. If the features differ, each interface has an Ingress
+// of its own, named after the Capsule and the interface.
 type CapsuleInterfaceIngress struct {
 	// Host specifies the DNS name of the Ingress resource
 	Host string `json:"host"`
+
+	// CORS allows browsers to make cross-origin requests to the interface.
+	CORS *IngressCORS `json:"cors,omitempty"`
+
+	// RateLimit limits the requests to the interface from each client IP.
+	RateLimit *IngressRateLimit `json:"rateLimit,omitempty"`
+
+	// SourceRanges restricts the client IPs which can access the interface
+	// to the CIDRs.
+	SourceRanges []string `json:"sourceRanges,omitempty"`
+
+	// BasicAuth requires clients to authenticate with HTTP basic
+	// authentication.
+	BasicAuth *IngressBasicAuth `json:"basicAuth,omitempty"`
+
+	// MaxBodySize is the maximum size of the body of requests.
+	MaxBodySize *resource.Quantity `json:"maxBodySize,omitempty"`
+
+	// Timeout is how long the ingress controller waits for the interface to
+	// respond to requests.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// Issuer is the cert-manager issuer of the certificate of the host,
+	// overriding the issuer of the Capsule. The host gets a certificate of
+	// its own, which requires the operator to create Certificate resources.
+	Issuer *IssuerReference `json:"issuer,omitempty"`
+}
+
+// IssuerReference references a cert-manager issuer.
+type IssuerReference struct {
+	// Kind is the kind of the issuer, either an Issuer in the namespace of
+	// the Capsule or a ClusterIssuer. Defaults to ClusterIssuer.
+	//+kubebuilder:validation:Enum=Issuer;ClusterIssuer
+	Kind string `json:"kind,omitempty"`
+
+	// Name is the name of the issuer.
+	Name string `json:"name"`
+}
+
+// GetKind returns the kind of the issuer, defaulting to ClusterIssuer.
+func (r *IssuerReference) GetKind() string {
+	if r.Kind == "" {
+		return "ClusterIssuer"
+	}
+	return r.Kind
+}
+
+// IngressCORS configures Cross-Origin Resource Sharing for an interface.
+type IngressCORS struct {
+	// AllowOrigins are the origins which can make cross-origin requests.
+	//+kubebuilder:validation:MinItems=1
+	AllowOrigins []string `json:"allowOrigins"`
+}
+
+// IngressRateLimit limits the requests to an interface.
+type IngressRateLimit struct {
+	// RequestsPerSecond is the number of requests per second each client IP
+	// can make.
+	//+kubebuilder:validation:Minimum=1
+	RequestsPerSecond int32 `json:"requestsPerSecond"`
+}
+
+// IngressBasicAuth configures HTTP basic authentication for an interface.
+type IngressBasicAuth struct {
+	// SecretName is the name of the Secret, in the namespace of the Capsule,
+	// holding the users, in the format expected by the ingress controller.
+	SecretName string `json:"secretName"`
 }
 
 // CapsuleInterfaceLoadBalancer defines that the interface should be exposed as
@@ -247,6 +756,36 @@ type CapsuleInterfaceLoadBalancer struct {
 	//+kubebuilder:validation:Minimum=1
 	//+kubebuilder:validation:Maximum=65535
 	Port int32 `json:"port"`
+
+	// NodePort pins the port of the nodes which the LoadBalancer forwards
+	// the traffic to. Defaults to a port allocated by Kubernetes.
+	//+kubebuilder:validation:Minimum=1
+	//+kubebuilder:validation:Maximum=65535
+	NodePort int32 `json:"nodePort,omitempty"`
+
+	// Protocol is the protocol of the LoadBalancer port, which is given by
+	// the protocol of the interface: UDP for UDP interfaces and TCP for all
+	// others. It can be omitted, and is rejected if it doesn't match.
+	//+kubebuilder:validation:Enum=TCP;UDP
+	Protocol v1.Protocol `json:"protocol,omitempty"`
+
+	// SourceRanges restricts the client IPs which can access the
+	// LoadBalancer to the CIDRs, if supported by the cloud provider.
+	// As the interfaces of a capsule share the LoadBalancer, it must be the
+	// same for all of them.
+	SourceRanges []string `json:"sourceRanges,omitempty"`
+
+	// ExternalTrafficPolicy specifies if the traffic is routed to the
+	// instances of the capsule on all nodes, or only on the node receiving
+	// it, preserving the client IP. It must be the same for all interfaces
+	// of the capsule.
+	//+kubebuilder:validation:Enum=Cluster;Local
+	ExternalTrafficPolicy v1.ServiceExternalTrafficPolicy `json:"externalTrafficPolicy,omitempty"`
+
+	// Annotations are added to the LoadBalancer Service, e.g. to request an
+	// internal LoadBalancer from the cloud provider. The interfaces of a
+	// capsule can't set different values for the same annotation.
+	Annotations map[string]string `json:"annotations,omitempty"`
 }
 
 // File defines a mounted file and where to retrieve the contents from
@@ -279,10 +818,79 @@ type CapsuleStatus struct {
 	OwnedResources     []OwnedResource   `json:"ownedResources,omitempty"`
 	UsedResources      []UsedResource    `json:"usedResources,omitempty"`
 	Deployment         *DeploymentStatus `json:"deploymentStatus,omitempty"`
+	Rollback           *RollbackStatus   `json:"rollback,omitempty"`
+	// Dependencies is the status of the dependencies of the Capsule.
+	Dependencies *DependenciesStatus `json:"dependencies,omitempty"`
+	// Expiration is when the Capsule expires, for a Capsule with a TTL.
+	Expiration *ExpirationStatus `json:"expiration,omitempty"`
+	// Certificates is the status of the cert-manager Certificates of the
+	// interfaces with an ingress.
+	Certificates []CertificateStatus `json:"certificates,omitempty"`
+}
+
+// CertificateStatus is the status of a cert-manager Certificate used by the
+// Capsule.
+type CertificateStatus struct {
+	// Name is the name of the Certificate.
+	Name string `json:"name"`
+	// DNSNames are the DNS names of the Certificate.
+	DNSNames []string `json:"dnsNames,omitempty"`
+	// Ready is the status of the Ready condition of the Certificate.
+	// +kubebuilder:validation:Enum=True;False;Unknown
+	Ready string `json:"ready"`
+	// Reason is the reason of the Ready condition.
+	Reason string `json:"reason,omitempty"`
+	// Message explains the Ready condition, e.g. why the certificate could
+	// not be issued.
+	Message string `json:"message,omitempty"`
+	// NotAfter is when the issued certificate expires.
+	NotAfter *metav1.Time `json:"notAfter,omitempty"`
+}
+
+// ExpirationStatus describes when a Capsule with a TTL expires.
+type ExpirationStatus struct {
+	// ExpiresAt is when the Capsule expires, unless its lease is extended or,
+	// for an idle timeout, it receives ingress traffic.
+	ExpiresAt metav1.Time `json:"expiresAt"`
+	// Reason is why the Capsule expires, either the ttl or being idle.
+	// +kubebuilder:validation:Enum=ttl;idle
+	Reason string `json:"reason"`
+	// State is expiring when the Capsule expires within the warning period
+	// of the operator, and active otherwise.
+	// +kubebuilder:validation:Enum=active;expiring
+	State string `json:"state"`
+	// LastActivity is when ingress traffic to the Capsule was last observed,
+	// for a Capsule with an idle timeout.
+	LastActivity *metav1.Time `json:"lastActivity,omitempty"`
+}
+
+// DependenciesStatus describes if the Capsule is waiting for its
+// dependencies.
+type DependenciesStatus struct {
+	// State is ready when all dependencies are ready, and waiting otherwise.
+	// +kubebuilder:validation:Enum=waiting;ready
+	State string `json:"state"`
+	// Blocking is the first dependency which is not ready.
+	Blocking *CapsuleDependency `json:"blocking,omitempty"`
+	// Message explains why the blocking dependency is not ready, which
+	// includes the capsules of a dependency cycle through the Capsule.
+	Message string `json:"message,omitempty"`
+}
+
+// RollbackStatus describes an automatic rollback of a rollout of the Capsule.
+type RollbackStatus struct {
+	// Generation is the generation of the Capsule which was rolled back.
+	Generation int64 `json:"generation"`
+	// Revision is the deployment revision which was restored.
+	Revision string `json:"revision,omitempty"`
+	// Reason is why the rollout was rolled back.
+	Reason string `json:"reason"`
+	// Time is when the rollback happened.
+	Time metav1.Time `json:"time"`
 }
 
 type DeploymentStatus struct {
-	// +kubebuilder:validation:Enum=created;failed
+	// +kubebuilder:validation:Enum=created;progressing;failed
 	State   string `json:"state,omitempty"`
 	Message string `json:"message,omitempty"`
 }
@@ -1,12 +1,28 @@
 package v1alpha2
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
 	"path"
+	"slices"
+	"sort"
+	"time"
 
+	configv1alpha1 "github.com/rigdev/rig/pkg/api/config/v1alpha1"
+	"github.com/rigdev/rig/pkg/ptr"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
@@ -15,43 +31,371 @@ import (
 // log is for logging in this package.
 var capsulelog = logf.Log.WithName("capsule-resource")
 
-func (r *Capsule) SetupWebhookWithManager(mgr ctrl.Manager) error {
+// AnnotationDefaultedFields is the capsule annotation recording which fields
+// were set from the capsule defaults of the operator config, as a JSON object
+// from field path to value.
+const AnnotationDefaultedFields = "rig.dev/defaulted-fields"
+
+// SetupWebhookWithManager registers the webhooks of the capsule. The
+// mutating webhook applies the capsule defaults returned by defaults, and the
+// validating webhook evaluates the CapsulePolicies of the namespace.
+func (r *Capsule) SetupWebhookWithManager(
+	mgr ctrl.Manager,
+	config func() *configv1alpha1.OperatorConfig,
+) error {
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(r).
+		WithDefaulter(&capsuleDefaulter{
+			defaults: func() *configv1alpha1.CapsuleDefaults { return &config().Defaults },
+		}).
+		WithValidator(&capsuleValidator{
+			client: mgr.GetClient(),
+			config: config,
+		}).
 		Complete()
 }
 
 //+kubebuilder:webhook:path=/mutate-rig-dev-v1alpha2-capsule,mutating=true,failurePolicy=fail,sideEffects=None,groups=rig.dev,resources=capsules,verbs=create;update,versions=v1alpha2,name=mcapsule.kb.io,admissionReviewVersions=v1
 
-var _ webhook.Defaulter = &Capsule{}
+type capsuleDefaulter struct {
+	defaults func() *configv1alpha1.CapsuleDefaults
+}
+
+var _ webhook.CustomDefaulter = &capsuleDefaulter{}
 
-// Default implements webhook.Defaulter so a webhook will be registered for the type
-func (r *Capsule) Default() {
+// Default implements webhook.CustomDefaulter so a webhook will be registered
+// for the type
+func (d *capsuleDefaulter) Default(_ context.Context, obj runtime.Object) error {
+	r, ok := obj.(*Capsule)
+	if !ok {
+		return fmt.Errorf("expected a Capsule but got a %T", obj)
+	}
 	capsulelog.Info("default", "name", r.Name)
+	return r.Default(d.defaults())
+}
+
+// defaultField is a field of a capsule which can be set from the capsule
+// defaults.
+type defaultField struct {
+	path *field.Path
+	// value is the default value of the field.
+	value string
+	// get returns the current value of the field, or an empty string if it
+	// is unset.
+	get func() string
+	// set sets the field to the default value.
+	set func()
+}
+
+// Default sets the fields of the capsule which are unset to the given
+// defaults, and records the defaulted fields in the AnnotationDefaultedFields
+// annotation. Fields recorded by earlier admissions are kept in the
+// annotation as long as they still hold the defaulted value.
+func (r *Capsule) Default(defaults *configv1alpha1.CapsuleDefaults) error {
+	recorded := map[string]string{}
+	if a, ok := r.GetAnnotations()[AnnotationDefaultedFields]; ok {
+		// An invalid annotation is overwritten.
+		_ = json.Unmarshal([]byte(a), &recorded)
+	}
+
+	defaulted := map[string]string{}
+	for _, f := range r.defaultFields(defaults) {
+		path := f.path.String()
+		if cur := f.get(); cur != "" {
+			if v, ok := recorded[path]; ok && v == cur {
+				defaulted[path] = v
+			}
+			continue
+		}
+		f.set()
+		defaulted[path] = f.value
+	}
+
+	annotations := r.GetAnnotations()
+	if len(defaulted) == 0 {
+		delete(annotations, AnnotationDefaultedFields)
+		r.SetAnnotations(annotations)
+		return nil
+	}
+
+	bs, err := json.Marshal(defaulted)
+	if err != nil {
+		return err
+	}
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[AnnotationDefaultedFields] = string(bs)
+	r.SetAnnotations(annotations)
+	return nil
+}
+
+func (r *Capsule) defaultFields(defaults *configv1alpha1.CapsuleDefaults) []defaultField {
+	if defaults == nil {
+		return nil
+	}
+
+	// The spec of a capsule with a template is defaulted by the operator,
+	// once merged onto the template.
+	if r.Spec.Template != nil {
+		return r.defaultAnnotationFields(defaults)
+	}
+
+	var fields []defaultField
+	verticalPath := field.NewPath("spec").Child("scale").Child("vertical")
+	fields = append(fields, r.defaultResourceFields(
+		verticalPath.Child("cpu"), defaults.CPU, func(v *VerticalScale) **ResourceLimits { return &v.CPU },
+	)...)
+	fields = append(fields, r.defaultResourceFields(
+		verticalPath.Child("memory"), defaults.Memory, func(v *VerticalScale) **ResourceLimits { return &v.Memory },
+	)...)
+
+	// UDP interfaces can't be probed.
+	if p := defaults.Readiness; p != nil && len(r.Spec.Interfaces) > 0 && !r.hasOtherReadiness() &&
+		r.Spec.Interfaces[0].Protocol != InterfaceProtocolUDP {
+		inf := &r.Spec.Interfaces[0]
+		probe := &InterfaceProbe{Path: p.Path, TCP: p.TCP}
+		fields = append(fields, defaultField{
+			path:  field.NewPath("spec").Child("interfaces").Key(inf.Name).Child("readiness"),
+			value: probeString(probe),
+			get:   func() string { return probeString(inf.Readiness) },
+			set:   func() { inf.Readiness = probe },
+		})
+	}
+
+	for _, k := range sortedKeys(defaults.NodeSelector) {
+		k, v := k, defaults.NodeSelector[k]
+		fields = append(fields, defaultField{
+			path:  field.NewPath("spec").Child("nodeSelector").Key(k),
+			value: v,
+			get:   func() string { return r.Spec.NodeSelector[k] },
+			set: func() {
+				if r.Spec.NodeSelector == nil {
+					r.Spec.NodeSelector = map[string]string{}
+				}
+				r.Spec.NodeSelector[k] = v
+			},
+		})
+	}
+
+	return append(fields, r.defaultAnnotationFields(defaults)...)
+}
+
+func (r *Capsule) defaultAnnotationFields(defaults *configv1alpha1.CapsuleDefaults) []defaultField {
+	var fields []defaultField
+	for _, k := range sortedKeys(defaults.Annotations) {
+		if k == AnnotationDefaultedFields {
+			continue
+		}
+		k, v := k, defaults.Annotations[k]
+		fields = append(fields, defaultField{
+			path:  field.NewPath("metadata").Child("annotations").Key(k),
+			value: v,
+			get:   func() string { return r.GetAnnotations()[k] },
+			set: func() {
+				annotations := r.GetAnnotations()
+				if annotations == nil {
+					annotations = map[string]string{}
+				}
+				annotations[k] = v
+				r.SetAnnotations(annotations)
+			},
+		})
+	}
+
+	return fields
+}
+
+// hasOtherReadiness returns true if an interface other than the first is
+// used for readiness probing.
+func (r *Capsule) hasOtherReadiness() bool {
+	for _, inf := range r.Spec.Interfaces[1:] {
+		if inf.Readiness != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultResourceFields returns the request and limit fields of a resource
+// of the capsule. A default is not applied if it conflicts with the request
+// or limit set on the capsule.
+func (r *Capsule) defaultResourceFields(
+	rPath *field.Path,
+	defaults *configv1alpha1.ResourceDefaults,
+	limitsOf func(*VerticalScale) **ResourceLimits,
+) []defaultField {
+	if defaults == nil {
+		return nil
+	}
+
+	var current ResourceLimits
+	if r.Spec.Scale.Vertical != nil {
+		if l := *limitsOf(r.Spec.Scale.Vertical); l != nil {
+			current = *l
+		}
+	}
+	isSet := func(q *resource.Quantity) bool { return q != nil && !q.IsZero() }
+	limits := func() *ResourceLimits {
+		if r.Spec.Scale.Vertical == nil {
+			r.Spec.Scale.Vertical = &VerticalScale{}
+		}
+		l := limitsOf(r.Spec.Scale.Vertical)
+		if *l == nil {
+			*l = &ResourceLimits{}
+		}
+		return *l
+	}
+	get := func(q func(*ResourceLimits) *resource.Quantity) func() string {
+		return func() string {
+			if r.Spec.Scale.Vertical == nil {
+				return ""
+			}
+			l := *limitsOf(r.Spec.Scale.Vertical)
+			if l == nil || !isSet(q(l)) {
+				return ""
+			}
+			return q(l).String()
+		}
+	}
+
+	var fields []defaultField
+	if q := defaults.Request; q != nil && !(isSet(current.Limit) && q.Cmp(*current.Limit) > 0) {
+		fields = append(fields, defaultField{
+			path:  rPath.Child("request"),
+			value: q.String(),
+			get:   get(func(l *ResourceLimits) *resource.Quantity { return l.Request }),
+			set:   func() { limits().Request = ptr.New(q.DeepCopy()) },
+		})
+	}
+	if q := defaults.Limit; q != nil && !(isSet(current.Request) && current.Request.Cmp(*q) > 0) {
+		fields = append(fields, defaultField{
+			path:  rPath.Child("limit"),
+			value: q.String(),
+			get:   get(func(l *ResourceLimits) *resource.Quantity { return l.Limit }),
+			set:   func() { limits().Limit = ptr.New(q.DeepCopy()) },
+		})
+	}
+	return fields
+}
+
+func probeString(p *InterfaceProbe) string {
+	switch {
+	case p == nil:
+		return ""
+	case p.TCP:
+		return "tcp"
+	case p.GRPC != nil:
+		return "grpc:" + p.GRPC.Service
+	default:
+		return "path:" + p.Path
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
 //+kubebuilder:webhook:path=/validate-rig-dev-v1alpha2-capsule,mutating=false,failurePolicy=fail,sideEffects=None,groups=rig.dev,resources=capsules,verbs=create;update,versions=v1alpha2,name=vcapsule.kb.io,admissionReviewVersions=v1
 
-var _ webhook.Validator = &Capsule{}
+type capsuleValidator struct {
+	client client.Reader
+	config func() *configv1alpha1.OperatorConfig
+}
+
+var _ webhook.CustomValidator = &capsuleValidator{}
 
-// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
-func (r *Capsule) ValidateCreate() (admission.Warnings, error) {
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type
+func (v *capsuleValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	r, ok := obj.(*Capsule)
+	if !ok {
+		return nil, fmt.Errorf("expected a Capsule but got a %T", obj)
+	}
 	capsulelog.Info("validate create", "name", r.Name)
-	return r.validate()
+	return v.validate(ctx, r)
 }
 
-// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
-func (r *Capsule) ValidateUpdate(_ runtime.Object) (admission.Warnings, error) {
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type
+func (v *capsuleValidator) ValidateUpdate(
+	ctx context.Context,
+	_ runtime.Object,
+	newObj runtime.Object,
+) (admission.Warnings, error) {
+	r, ok := newObj.(*Capsule)
+	if !ok {
+		return nil, fmt.Errorf("expected a Capsule but got a %T", newObj)
+	}
 	capsulelog.Info("validate update", "name", r.Name)
-	return r.validate()
+	return v.validate(ctx, r)
 }
 
-// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
-func (r *Capsule) ValidateDelete() (admission.Warnings, error) {
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type
+func (v *capsuleValidator) ValidateDelete(context.Context, runtime.Object) (admission.Warnings, error) {
 	return nil, nil
 }
 
-func (r *Capsule) validate() (admission.Warnings, error) {
+// validate validates the capsule and evaluates the CapsulePolicies of its
+// namespace. A capsule with a template is validated merged onto the template.
+func (v *capsuleValidator) validate(ctx context.Context, r *Capsule) (admission.Warnings, error) {
+	namespace := r.Namespace
+	if req, err := admission.RequestFromContext(ctx); err == nil && req.Namespace != "" {
+		namespace = req.Namespace
+	}
+
+	var warns admission.Warnings
+	if ref := r.Spec.Template; ref != nil {
+		c := r.DeepCopy()
+		c.Namespace = namespace
+		t, err := c.GetTemplate(ctx, v.client)
+		if kerrors.IsNotFound(err) {
+			warns = append(warns, fmt.Sprintf(
+				"%s %s not found, the capsule is validated without it", ref.GetKind(), ref.Name,
+			))
+		} else if err != nil {
+			return nil, fmt.Errorf("could not get capsule template: %w", err)
+		} else {
+			r = c.WithTemplate(t)
+		}
+	}
+
+	w, errs := r.validate()
+	warns = append(warns, w...)
+	cfg := v.config()
+	errs = append(errs, r.validateOverrides(cfg.Overrides)...)
+	errs = append(errs, r.validateInterfaceIssuers(cfg.Certmanager)...)
+	warns = append(warns, r.idleTimeoutWarnings(cfg.Cleanup)...)
+	w, monitoringErrs := r.validateMonitoring(cfg)
+	warns = append(warns, w...)
+	errs = append(errs, monitoringErrs...)
+
+	var policies CapsulePolicyList
+	if err := v.client.List(ctx, &policies, client.InNamespace(namespace)); err != nil {
+		return warns, fmt.Errorf("could not list capsule policies: %w", err)
+	}
+	sort.Slice(policies.Items, func(i, j int) bool {
+		return policies.Items[i].Name < policies.Items[j].Name
+	})
+
+	for _, p := range policies.Items {
+		violations := p.evaluate(r)
+		if p.Spec.Enforcement == PolicyEnforcementWarn {
+			for _, violation := range violations {
+				warns = append(warns, violation.Error())
+			}
+			continue
+		}
+		errs = append(errs, violations...)
+	}
+
+	return warns, errs.ToAggregate()
+}
+
+func (r *Capsule) validate() (admission.Warnings, field.ErrorList) {
 	var (
 		allWarns admission.Warnings
 		allErrs  field.ErrorList
@@ -77,7 +421,21 @@ func (r *Capsule) validate() (admission.Warnings, error) {
 
 	allErrs = append(allErrs, r.Spec.Scale.Horizontal.validate(field.NewPath("scale").Child("horizontal"))...)
 
-	return allWarns, allErrs.ToAggregate()
+	allErrs = append(allErrs, r.Spec.Scheduling.validate(field.NewPath("spec").Child("scheduling"))...)
+
+	allErrs = append(allErrs, r.Spec.Lifecycle.validate(field.NewPath("spec").Child("lifecycle"))...)
+
+	allErrs = append(allErrs, r.Spec.Rollout.validate(field.NewPath("spec").Child("rollout"))...)
+
+	allErrs = append(allErrs, r.validateDependsOn()...)
+
+	allErrs = append(allErrs, r.validateConnections()...)
+
+	allErrs = append(allErrs, r.validateGeneratedSecrets()...)
+
+	allErrs = append(allErrs, r.validateTTL()...)
+
+	return allWarns, allErrs
 }
 
 func (r *Capsule) validateSpec() (admission.Warnings, field.ErrorList) {
@@ -86,6 +444,9 @@ func (r *Capsule) validateSpec() (admission.Warnings, field.ErrorList) {
 	if r.Spec.Image == "" {
 		errs = append(errs, field.Required(field.NewPath("spec").Child("image"), ""))
 	}
+	if r.Spec.Issuer != nil {
+		errs = append(errs, r.Spec.Issuer.validate(field.NewPath("spec").Child("issuer"))...)
+	}
 	return nil, errs
 }
 
@@ -101,6 +462,12 @@ func (r *Capsule) validateInterfaces() (admission.Warnings, field.ErrorList) {
 
 	names := map[string]struct{}{}
 	ports := map[int32]struct{}{}
+	// The interfaces share the LoadBalancer Service, which is configured by
+	// the first of them.
+	var first *CapsuleInterfaceLoadBalancer
+	// Interfaces with the same features share an Ingress, whose annotations
+	// configure the protocol of the backends.
+	var ingressProtocol InterfaceProtocol
 	infsPath := field.NewPath("spec").Child("interfaces")
 	for i, inf := range r.Spec.Interfaces {
 		infPath := infsPath.Index(i)
@@ -133,6 +500,49 @@ func (r *Capsule) validateInterfaces() (admission.Warnings, field.ErrorList) {
 			if public.Ingress != nil && public.Ingress.Host == "" {
 				errs = append(errs, field.Required(publicPath.Child("ingress").Child("host"), ""))
 			}
+			if public.Ingress != nil {
+				p := inf.GetIngressProtocol()
+				switch {
+				case p == InterfaceProtocolTCP || p == InterfaceProtocolUDP:
+					errs = append(errs, field.Invalid(infPath.Child("protocol"), inf.Protocol,
+						"interfaces with an ingress must have the HTTP, HTTP2 or GRPC protocol"))
+				case ingressProtocol == "":
+					ingressProtocol = p
+				case p != ingressProtocol:
+					errs = append(errs, field.Invalid(infPath.Child("protocol"), inf.Protocol,
+						"must be the same for all interfaces with an ingress"))
+				}
+
+				ingPath := publicPath.Child("ingress")
+				errs = append(errs, public.Ingress.validate(ingPath)...)
+			}
+			if lb := public.LoadBalancer; lb != nil {
+				lbPath := publicPath.Child("loadBalancer")
+				errs = append(errs, lb.validate(lbPath)...)
+				// The interface determines the protocol of its ports.
+				portProtocol := v1.ProtocolTCP
+				if inf.Protocol == InterfaceProtocolUDP {
+					portProtocol = v1.ProtocolUDP
+				}
+				if lb.Protocol != "" && lb.Protocol != portProtocol {
+					errs = append(errs, field.Invalid(lbPath.Child("protocol"), lb.Protocol,
+						fmt.Sprintf("must be %s to match the protocol of the interface", portProtocol)))
+				}
+				if first == nil {
+					first = lb
+				} else {
+					errs = append(errs, lb.validateShared(lbPath, first)...)
+				}
+			}
+		}
+
+		if inf.Protocol == InterfaceProtocolUDP {
+			if inf.Liveness != nil {
+				errs = append(errs, field.Forbidden(infPath.Child("liveness"), "UDP interfaces can't be probed"))
+			}
+			if inf.Readiness != nil {
+				errs = append(errs, field.Forbidden(infPath.Child("readiness"), "UDP interfaces can't be probed"))
+			}
 		}
 
 		if inf.Liveness != nil {
@@ -159,6 +569,118 @@ func (r *Capsule) validateInterfaces() (admission.Warnings, field.ErrorList) {
 	return nil, errs
 }
 
+func (ing *CapsuleInterfaceIngress) validate(ingPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	if ing.CORS != nil {
+		if len(ing.CORS.AllowOrigins) == 0 {
+			errs = append(errs, field.Required(ingPath.Child("cors").Child("allowOrigins"), ""))
+		}
+		for i, o := range ing.CORS.AllowOrigins {
+			if o == "*" {
+				continue
+			}
+			if u, err := url.Parse(o); err != nil || u.Scheme == "" || u.Host == "" || u.Path != "" {
+				errs = append(errs, field.Invalid(ingPath.Child("cors").Child("allowOrigins").Index(i), o,
+					"must be * or an origin, such as https://example.com"))
+			}
+		}
+	}
+
+	if ing.RateLimit != nil && ing.RateLimit.RequestsPerSecond <= 0 {
+		errs = append(errs, field.Invalid(ingPath.Child("rateLimit").Child("requestsPerSecond"),
+			ing.RateLimit.RequestsPerSecond, "must be positive"))
+	}
+
+	for i, r := range ing.SourceRanges {
+		if _, _, err := net.ParseCIDR(r); err != nil {
+			errs = append(errs, field.Invalid(ingPath.Child("sourceRanges").Index(i), r, "must be a CIDR"))
+		}
+	}
+
+	if ing.BasicAuth != nil && ing.BasicAuth.SecretName == "" {
+		errs = append(errs, field.Required(ingPath.Child("basicAuth").Child("secretName"), ""))
+	}
+
+	if ing.MaxBodySize != nil && ing.MaxBodySize.Sign() <= 0 {
+		errs = append(errs, field.Invalid(ingPath.Child("maxBodySize"), ing.MaxBodySize.String(), "must be positive"))
+	}
+
+	if ing.Issuer != nil {
+		errs = append(errs, ing.Issuer.validate(ingPath.Child("issuer"))...)
+	}
+
+	if ing.Timeout != nil && ing.Timeout.Duration < time.Second {
+		errs = append(errs, field.Invalid(ingPath.Child("timeout"), ing.Timeout.Duration.String(),
+			"must be at least one second"))
+	}
+
+	return errs
+}
+
+func (r *IssuerReference) validate(iPath *field.Path) field.ErrorList {
+	if r.Name == "" {
+		return field.ErrorList{field.Required(iPath.Child("name"), "")}
+	}
+	return nil
+}
+
+// HasFeaturesOf returns true if the ingress has the same features as the
+// other ingress, so the interfaces can share an Ingress.
+func (ing *CapsuleInterfaceIngress) HasFeaturesOf(other *CapsuleInterfaceIngress) bool {
+	// The hosts have certificates of their own if their issuers differ.
+	a, b := *ing, *other
+	a.Host, b.Host = "", ""
+	a.Issuer, b.Issuer = nil, nil
+	return equality.Semantic.DeepEqual(a, b)
+}
+
+func (lb *CapsuleInterfaceLoadBalancer) validate(lbPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	for i, r := range lb.SourceRanges {
+		if _, _, err := net.ParseCIDR(r); err != nil {
+			errs = append(errs, field.Invalid(lbPath.Child("sourceRanges").Index(i), r, "must be a CIDR"))
+		}
+	}
+
+	for k := range lb.Annotations {
+		for _, msg := range validation.IsQualifiedName(k) {
+			errs = append(errs, field.Invalid(lbPath.Child("annotations").Key(k), k, msg))
+		}
+	}
+
+	return errs
+}
+
+// validateShared validates that the LoadBalancer Service is configured the
+// same way as by the first interface published through it.
+func (lb *CapsuleInterfaceLoadBalancer) validateShared(
+	lbPath *field.Path,
+	first *CapsuleInterfaceLoadBalancer,
+) field.ErrorList {
+	var errs field.ErrorList
+
+	if !slices.Equal(lb.SourceRanges, first.SourceRanges) {
+		errs = append(errs, field.Invalid(lbPath.Child("sourceRanges"), lb.SourceRanges,
+			"must be the same for all interfaces with a loadBalancer"))
+	}
+
+	if lb.ExternalTrafficPolicy != first.ExternalTrafficPolicy {
+		errs = append(errs, field.Invalid(lbPath.Child("externalTrafficPolicy"), lb.ExternalTrafficPolicy,
+			"must be the same for all interfaces with a loadBalancer"))
+	}
+
+	for k, v := range lb.Annotations {
+		if fv, ok := first.Annotations[k]; ok && fv != v {
+			errs = append(errs, field.Invalid(lbPath.Child("annotations").Key(k), v,
+				fmt.Sprintf("conflicts with the value %q of another interface", fv)))
+		}
+	}
+
+	return errs
+}
+
 func (p *InterfaceProbe) validate(pPath *field.Path) field.ErrorList {
 	var errs field.ErrorList
 
@@ -184,6 +706,96 @@ func (p *InterfaceProbe) validate(pPath *field.Path) field.ErrorList {
 	return errs
 }
 
+// validateMonitoring validates the monitoring of the capsule. If cfg is not
+// nil, it also warns about monitoring which produces no monitor with the
+// operator config.
+func (r *Capsule) validateMonitoring(cfg *configv1alpha1.OperatorConfig) (admission.Warnings, field.ErrorList) {
+	m := r.Spec.Monitoring
+	if m == nil {
+		return nil, nil
+	}
+
+	var (
+		warns admission.Warnings
+		errs  field.ErrorList
+	)
+
+	mPath := field.NewPath("spec").Child("monitoring")
+	isPodMonitor := m.Kind == "PodMonitor"
+
+	if !m.Disabled && !isPodMonitor && len(r.Spec.Interfaces) == 0 {
+		errs = append(errs, field.Invalid(
+			mPath.Child("kind"), m.Kind, "a ServiceMonitor requires interfaces, use a PodMonitor instead",
+		))
+	}
+
+	if cfg != nil && !m.Disabled && m.Port == nil &&
+		(cfg.PrometheusServiceMonitor == nil || cfg.PrometheusServiceMonitor.PortName == "") {
+		warns = append(warns, "spec.monitoring has no port and the operator has no "+
+			"prometheusServiceMonitor.portName, so no monitor is created for the capsule")
+	}
+
+	if m.Port != nil {
+		pPath := mPath.Child("port")
+		switch m.Port.Type {
+		case intstr.Int:
+			if !isPodMonitor {
+				errs = append(errs, field.Invalid(pPath, m.Port.String(), "port numbers are only supported by a PodMonitor"))
+			}
+			if m.Port.IntVal < 1 || m.Port.IntVal > 65535 {
+				errs = append(errs, field.Invalid(pPath, m.Port.String(), "must be between 1 and 65535"))
+			}
+		case intstr.String:
+			found := false
+			for _, inf := range r.Spec.Interfaces {
+				if inf.Name == m.Port.StrVal {
+					found = true
+				}
+			}
+			if !found {
+				errs = append(errs, field.NotFound(pPath, m.Port.StrVal))
+			}
+		}
+	}
+
+	if m.Path != "" && !path.IsAbs(m.Path) {
+		errs = append(errs, field.Invalid(mPath.Child("path"), m.Path, "path must be an absolute path"))
+	}
+
+	// Prometheus durations are whole numbers of a unit.
+	if m.Interval != nil && m.Interval.Duration <= 0 {
+		errs = append(errs, field.Invalid(mPath.Child("interval"), m.Interval.Duration.String(), "must be positive"))
+	} else if m.Interval != nil && m.Interval.Duration%time.Second != 0 {
+		errs = append(errs, field.Invalid(
+			mPath.Child("interval"), m.Interval.Duration.String(), "must be a whole number of seconds",
+		))
+	}
+
+	names := map[string]struct{}{}
+	for i, rule := range m.AlertRules {
+		rPath := mPath.Child("alertRules").Index(i)
+		if rule.Name == "" {
+			errs = append(errs, field.Required(rPath.Child("name"), ""))
+		} else if _, ok := names[rule.Name]; ok {
+			errs = append(errs, field.Duplicate(rPath.Child("name"), rule.Name))
+		} else {
+			names[rule.Name] = struct{}{}
+		}
+		if rule.Expr == "" {
+			errs = append(errs, field.Required(rPath.Child("expr"), ""))
+		}
+		if rule.For != nil && rule.For.Duration < 0 {
+			errs = append(errs, field.Invalid(rPath.Child("for"), rule.For.Duration.String(), "cannot be negative"))
+		} else if rule.For != nil && rule.For.Duration%time.Second != 0 {
+			errs = append(errs, field.Invalid(
+				rPath.Child("for"), rule.For.Duration.String(), "must be a whole number of seconds",
+			))
+		}
+	}
+
+	return warns, errs
+}
+
 func (r *Capsule) validateEnv() (admission.Warnings, field.ErrorList) {
 	if r.Spec.Env == nil {
 		return nil, nil
@@ -255,6 +867,360 @@ func (r *Capsule) validateFiles() (admission.Warnings, field.ErrorList) {
 	return nil, errs
 }
 
+func (s *Scheduling) validate(sPath *field.Path) field.ErrorList {
+	if s == nil {
+		return nil
+	}
+
+	var errs field.ErrorList
+
+	if s.Spread != nil && !s.Spread.Nodes && !s.Spread.Zones {
+		errs = append(errs, field.Invalid(sPath.Child("spread"), s.Spread, "one of `nodes` or `zones` must be set"))
+	}
+
+	for i, c := range s.TopologySpreadConstraints {
+		cPath := sPath.Child("topologySpreadConstraints").Index(i)
+		if c.MaxSkew < 1 {
+			errs = append(errs, field.Invalid(cPath.Child("maxSkew"), c.MaxSkew, "must be greater than zero"))
+		}
+		if c.TopologyKey == "" {
+			errs = append(errs, field.Required(cPath.Child("topologyKey"), ""))
+		}
+		switch c.WhenUnsatisfiable {
+		case v1.DoNotSchedule, v1.ScheduleAnyway:
+		default:
+			errs = append(errs, field.NotSupported(
+				cPath.Child("whenUnsatisfiable"),
+				c.WhenUnsatisfiable,
+				[]string{string(v1.DoNotSchedule), string(v1.ScheduleAnyway)},
+			))
+		}
+	}
+
+	return errs
+}
+
+func (l *Lifecycle) validate(lPath *field.Path) field.ErrorList {
+	if l == nil {
+		return nil
+	}
+
+	var errs field.ErrorList
+
+	if l.TerminationGracePeriod != nil && l.TerminationGracePeriod.Duration < 0 {
+		errs = append(errs, field.Invalid(
+			lPath.Child("terminationGracePeriod"), l.TerminationGracePeriod.Duration.String(), "cannot be negative",
+		))
+	}
+
+	if l.DrainDelay != nil {
+		if l.DrainDelay.Duration < 0 {
+			errs = append(errs, field.Invalid(
+				lPath.Child("drainDelay"), l.DrainDelay.Duration.String(), "cannot be negative",
+			))
+		}
+		if l.DrainDelay.Duration%time.Second != 0 {
+			errs = append(errs, field.Invalid(
+				lPath.Child("drainDelay"), l.DrainDelay.Duration.String(), "must be a whole number of seconds",
+			))
+		}
+		if l.TerminationGracePeriod != nil && l.DrainDelay.Duration >= l.TerminationGracePeriod.Duration {
+			errs = append(errs, field.Invalid(
+				lPath.Child("drainDelay"),
+				l.DrainDelay.Duration.String(),
+				"must be shorter than terminationGracePeriod",
+			))
+		}
+		if l.PreStop != nil && l.PreStop.HTTP != nil {
+			errs = append(errs, field.Invalid(
+				lPath.Child("drainDelay"), l.DrainDelay.Duration.String(), "cannot be used with an http preStop hook",
+			))
+		}
+	}
+
+	errs = append(errs, l.PreStop.validate(lPath.Child("preStop"))...)
+	errs = append(errs, l.PostStart.validate(lPath.Child("postStart"))...)
+
+	return errs
+}
+
+func (h *LifecycleHook) validate(hPath *field.Path) field.ErrorList {
+	if h == nil {
+		return nil
+	}
+
+	var errs field.ErrorList
+
+	if (h.Exec == nil) == (h.HTTP == nil) {
+		errs = append(errs, field.Invalid(hPath, h, "exactly one of `exec` and `http` must be provided"))
+	}
+	if h.Exec != nil && len(h.Exec.Command) == 0 {
+		errs = append(errs, field.Required(hPath.Child("exec").Child("command"), ""))
+	}
+	if h.HTTP != nil && !path.IsAbs(h.HTTP.Path) {
+		errs = append(errs, field.Invalid(hPath.Child("http").Child("path"), h.HTTP.Path, "path must be an absolute path"))
+	}
+
+	return errs
+}
+
+func (r *Rollout) validate(rPath *field.Path) field.ErrorList {
+	if r == nil {
+		return nil
+	}
+
+	var errs field.ErrorList
+
+	maxSurge, surgeErr := validateRolloutValue(r.MaxSurge, rPath.Child("maxSurge"))
+	if surgeErr != nil {
+		errs = append(errs, surgeErr)
+	}
+	maxUnavailable, unavailableErr := validateRolloutValue(r.MaxUnavailable, rPath.Child("maxUnavailable"))
+	if unavailableErr != nil {
+		errs = append(errs, unavailableErr)
+	}
+	if r.MaxSurge != nil && r.MaxUnavailable != nil &&
+		surgeErr == nil && unavailableErr == nil &&
+		maxSurge == 0 && maxUnavailable == 0 {
+		errs = append(errs, field.Invalid(
+			rPath.Child("maxUnavailable"), r.MaxUnavailable.String(), "cannot be 0 when maxSurge is 0",
+		))
+	}
+
+	if r.ProgressDeadlineSeconds != nil && *r.ProgressDeadlineSeconds <= r.MinReadySeconds {
+		errs = append(errs, field.Invalid(
+			rPath.Child("progressDeadlineSeconds"),
+			*r.ProgressDeadlineSeconds,
+			"must be greater than minReadySeconds",
+		))
+	}
+
+	return errs
+}
+
+func (r *Capsule) validateDependsOn() field.ErrorList {
+	var errs field.ErrorList
+
+	dPath := field.NewPath("spec").Child("dependsOn")
+	seen := map[CapsuleDependency]struct{}{}
+	for i, d := range r.Spec.DependsOn {
+		if d.Capsule == "" {
+			errs = append(errs, field.Required(dPath.Index(i).Child("capsule"), ""))
+			continue
+		}
+		if d.Capsule == r.Name {
+			errs = append(errs, field.Invalid(dPath.Index(i).Child("capsule"), d.Capsule, "capsule cannot depend on itself"))
+			continue
+		}
+		if _, ok := seen[d]; ok {
+			errs = append(errs, field.Duplicate(dPath.Index(i), d))
+			continue
+		}
+		seen[d] = struct{}{}
+	}
+
+	return errs
+}
+
+func (r *Capsule) validateConnections() field.ErrorList {
+	var errs field.ErrorList
+
+	cPath := field.NewPath("spec").Child("connections")
+	prefixes := map[string]struct{}{}
+	for i, c := range r.Spec.Connections {
+		iPath := cPath.Index(i)
+		if c.Capsule == "" {
+			errs = append(errs, field.Required(iPath.Child("capsule"), ""))
+		}
+		if c.Interface == "" {
+			errs = append(errs, field.Required(iPath.Child("interface"), ""))
+		}
+		if c.Name == "" && c.Capsule == "" {
+			continue
+		}
+
+		prefix := c.EnvPrefix()
+		for _, msg := range validation.IsEnvVarName(prefix + "_HOST") {
+			errs = append(errs, field.Invalid(iPath.Child("name"), c.Name, msg))
+		}
+		if _, ok := prefixes[prefix]; ok {
+			errs = append(errs, field.Duplicate(iPath.Child("name"), prefix))
+		}
+		prefixes[prefix] = struct{}{}
+	}
+
+	return errs
+}
+
+// maxGeneratedSecretLength is the maximum length of a Random generated
+// secret.
+const maxGeneratedSecretLength = 4096
+
+func (r *Capsule) validateGeneratedSecrets() field.ErrorList {
+	var errs field.ErrorList
+
+	gPath := field.NewPath("spec").Child("generatedSecrets")
+	keys := map[string]struct{}{}
+	for i, g := range r.Spec.GeneratedSecrets {
+		iPath := gPath.Index(i)
+		if g.Key == "" {
+			errs = append(errs, field.Required(iPath.Child("key"), ""))
+			continue
+		}
+		for _, k := range g.Keys() {
+			for _, msg := range validation.IsConfigMapKey(k) {
+				errs = append(errs, field.Invalid(iPath.Child("key"), g.Key, msg))
+			}
+			if _, ok := keys[k]; ok {
+				errs = append(errs, field.Duplicate(iPath.Child("key"), k))
+			}
+			keys[k] = struct{}{}
+		}
+
+		isRandom := g.Type == "" || g.Type == GeneratedSecretTypeRandom
+		if g.Length < 0 || (g.Length > 0 && !isRandom) {
+			errs = append(errs, field.Invalid(iPath.Child("length"), g.Length, "must be positive and only set for Random"))
+		} else if g.Length > maxGeneratedSecretLength {
+			errs = append(errs, field.Invalid(
+				iPath.Child("length"), g.Length, fmt.Sprintf("must not exceed %d", maxGeneratedSecretLength),
+			))
+		}
+		if g.Charset != "" && !isRandom {
+			errs = append(errs, field.Invalid(iPath.Child("charset"), g.Charset, "must only be set for Random"))
+		} else if hasDuplicateRunes(g.Charset) {
+			errs = append(errs, field.Invalid(iPath.Child("charset"), g.Charset, "must not contain duplicate characters"))
+		}
+		if g.Bits != 0 && (g.Type != GeneratedSecretTypeRSA || g.Bits < 2048 || g.Bits > 8192) {
+			errs = append(errs, field.Invalid(iPath.Child("bits"), g.Bits, "must be between 2048 and 8192 and only set for RSA"))
+		}
+	}
+
+	return errs
+}
+
+// hasDuplicateRunes returns true if a rune occurs more than once in s, which
+// would skew the distribution of random values using s as charset.
+func hasDuplicateRunes(s string) bool {
+	seen := map[rune]struct{}{}
+	for _, r := range s {
+		if _, ok := seen[r]; ok {
+			return true
+		}
+		seen[r] = struct{}{}
+	}
+	return false
+}
+
+func (r *Capsule) validateTTL() field.ErrorList {
+	var errs field.ErrorList
+
+	if a, ok := r.GetAnnotations()[AnnotationLeaseExtendedUntil]; ok {
+		if _, err := time.Parse(time.RFC3339, a); err != nil {
+			errs = append(errs, field.Invalid(
+				field.NewPath("metadata").Child("annotations").Key(AnnotationLeaseExtendedUntil), a, "must be an RFC 3339 time",
+			))
+		}
+	}
+
+	ttl := r.Spec.TTL
+	if ttl == nil {
+		return errs
+	}
+
+	tPath := field.NewPath("spec").Child("ttl")
+	if ttl.ExpiresAt == nil && ttl.IdleTimeout == nil {
+		errs = append(errs, field.Required(tPath, "one of expiresAt and idleTimeout must be set"))
+	}
+	if d := ttl.IdleTimeout; d != nil && d.Duration <= 0 {
+		errs = append(errs, field.Invalid(tPath.Child("idleTimeout"), d.Duration.String(), "must be positive"))
+	}
+	return errs
+}
+
+// validateInterfaceIssuers rejects issuers of interfaces unless the operator
+// creates Certificate resources, as the issuer of an Ingress applies to all
+// of its hosts.
+func (r *Capsule) validateInterfaceIssuers(cfg *configv1alpha1.CertManagerConfig) field.ErrorList {
+	if cfg != nil && cfg.CreateCertificateResources {
+		return nil
+	}
+
+	var errs field.ErrorList
+	iPath := field.NewPath("spec").Child("interfaces")
+	for i, inf := range r.Spec.Interfaces {
+		if inf.Public == nil || inf.Public.Ingress == nil || inf.Public.Ingress.Issuer == nil {
+			continue
+		}
+		errs = append(errs, field.Forbidden(
+			iPath.Index(i).Child("public", "ingress", "issuer"),
+			"requires the operator to create certificate resources",
+		))
+	}
+	return errs
+}
+
+// idleTimeoutWarnings warns about an idle timeout which is not enforced, as
+// the operator has no Prometheus to query the traffic of capsules.
+func (r *Capsule) idleTimeoutWarnings(cfg configv1alpha1.CleanupConfig) admission.Warnings {
+	if r.Spec.TTL == nil || r.Spec.TTL.IdleTimeout == nil || cfg.PrometheusURL != "" {
+		return nil
+	}
+	return admission.Warnings{
+		"spec.ttl.idleTimeout is not enforced, as the operator has no cleanup.prometheusURL to query the traffic",
+	}
+}
+
+func (r *Capsule) validateOverrides(cfg *configv1alpha1.OverridesConfig) field.ErrorList {
+	var errs field.ErrorList
+
+	oPath := field.NewPath("spec").Child("overrides")
+	for i, o := range r.Spec.Overrides {
+		iPath := oPath.Index(i)
+
+		obj, err := NewOverrideObject(o.Kind)
+		if err != nil {
+			errs = append(errs, field.NotSupported(iPath.Child("kind"), o.Kind, configv1alpha1.OverrideKinds))
+			continue
+		}
+		if _, err := o.Paths(); err != nil {
+			errs = append(errs, field.Invalid(iPath.Child("patch"), o.Patch, err.Error()))
+			continue
+		}
+		if err := o.CheckAllowed(cfg); err != nil {
+			errs = append(errs, field.Forbidden(iPath.Child("patch"), err.Error()))
+			continue
+		}
+		// A JSON patch may depend on the fields of the resource, so only a
+		// StrategicMerge patch can be checked against an empty resource.
+		if o.Type != OverrideTypeJSON {
+			if err := o.Apply(obj); err != nil {
+				errs = append(errs, field.Invalid(iPath.Child("patch"), o.Patch, err.Error()))
+			}
+		}
+	}
+
+	return errs
+}
+
+func validateRolloutValue(v *intstr.IntOrString, vPath *field.Path) (int, *field.Error) {
+	if v == nil {
+		return 0, nil
+	}
+
+	value, err := intstr.GetScaledValueFromIntOrPercent(v, 100, true)
+	if err != nil {
+		return 0, field.Invalid(vPath, v.String(), err.Error())
+	}
+	if value < 0 {
+		return 0, field.Invalid(vPath, v.String(), "cannot be negative")
+	}
+	if v.Type == intstr.String && value > 100 {
+		return 0, field.Invalid(vPath, v.String(), "cannot be more than 100%")
+	}
+
+	return value, nil
+}
+
 func (h *HorizontalScale) validate(fPath *field.Path) field.ErrorList {
 	if h == nil {
 		return nil
@@ -290,6 +1256,18 @@ func (h *HorizontalScale) validate(fPath *field.Path) field.ErrorList {
 		}
 	}
 
+	if b := h.DisruptionBudget; b != nil {
+		bPath := fPath.Child("disruptionBudget")
+		if b.MinAvailable != nil && b.MaxUnavailable != nil {
+			errs = append(errs, field.Invalid(bPath, b, "minAvailable and maxUnavailable are mutually exclusive"))
+		}
+		if b.Disabled && (b.MinAvailable != nil || b.MaxUnavailable != nil) {
+			errs = append(errs, field.Invalid(
+				bPath.Child("disabled"), b.Disabled, "cannot be set together with minAvailable or maxUnavailable",
+			))
+		}
+	}
+
 	for idx, m := range h.CustomMetrics {
 		fPath := fPath.Child("customMetrics").Index(idx)
 		if (m.InstanceMetric == nil) == (m.ObjectMetric == nil) {
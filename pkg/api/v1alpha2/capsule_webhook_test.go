@@ -2,22 +2,143 @@ package v1alpha2
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	v2 "k8s.io/api/autoscaling/v2"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 
+	configv1alpha1 "github.com/rigdev/rig/pkg/api/config/v1alpha1"
 	"github.com/rigdev/rig/pkg/ptr"
 )
 
 func TestDefault(t *testing.T) {
 	t.Parallel()
 
+	defaults := &configv1alpha1.CapsuleDefaults{
+		CPU: &configv1alpha1.ResourceDefaults{
+			Request: ptr.New(resource.MustParse("100m")),
+			Limit:   ptr.New(resource.MustParse("1")),
+		},
+		Memory: &configv1alpha1.ResourceDefaults{
+			Request: ptr.New(resource.MustParse("128Mi")),
+		},
+		Readiness:    &configv1alpha1.ProbeDefaults{Path: "/ready"},
+		NodeSelector: map[string]string{"pool": "apps"},
+		Annotations:  map[string]string{"team": "platform"},
+	}
+
 	tests := []struct {
-		name     string
-		spec     CapsuleSpec
-		expected CapsuleSpec
-	}{}
+		name                string
+		annotations         map[string]string
+		spec                CapsuleSpec
+		defaults            *configv1alpha1.CapsuleDefaults
+		expected            CapsuleSpec
+		expectedAnnotations map[string]string
+	}{
+		{
+			name:     "no defaults",
+			spec:     CapsuleSpec{Image: "nginx"},
+			expected: CapsuleSpec{Image: "nginx"},
+		},
+		{
+			name:     "all defaults are applied",
+			defaults: defaults,
+			spec: CapsuleSpec{
+				Interfaces: []CapsuleInterface{{Name: "http", Port: 80}},
+			},
+			expected: CapsuleSpec{
+				Interfaces: []CapsuleInterface{{
+					Name:      "http",
+					Port:      80,
+					Readiness: &InterfaceProbe{Path: "/ready"},
+				}},
+				NodeSelector: map[string]string{"pool": "apps"},
+				Scale: CapsuleScale{
+					Vertical: &VerticalScale{
+						CPU: &ResourceLimits{
+							Request: ptr.New(resource.MustParse("100m")),
+							Limit:   ptr.New(resource.MustParse("1")),
+						},
+						Memory: &ResourceLimits{
+							Request: ptr.New(resource.MustParse("128Mi")),
+						},
+					},
+				},
+			},
+			expectedAnnotations: map[string]string{
+				"team": "platform",
+				AnnotationDefaultedFields: `{"metadata.annotations[team]":"platform",` +
+					`"spec.interfaces[http].readiness":"path:/ready",` +
+					`"spec.nodeSelector[pool]":"apps",` +
+					`"spec.scale.vertical.cpu.limit":"1",` +
+					`"spec.scale.vertical.cpu.request":"100m",` +
+					`"spec.scale.vertical.memory.request":"128Mi"}`,
+			},
+		},
+		{
+			name:        "values of the capsule are kept",
+			defaults:    defaults,
+			annotations: map[string]string{"team": "payments"},
+			spec: CapsuleSpec{
+				Interfaces: []CapsuleInterface{
+					{Name: "http", Port: 80},
+					{Name: "admin", Port: 81, Readiness: &InterfaceProbe{TCP: true}},
+				},
+				NodeSelector: map[string]string{"pool": "gpu"},
+				Scale: CapsuleScale{
+					Vertical: &VerticalScale{
+						CPU: &ResourceLimits{
+							Request: ptr.New(resource.MustParse("2")),
+						},
+						Memory: &ResourceLimits{
+							Request: ptr.New(resource.MustParse("1Gi")),
+						},
+					},
+				},
+			},
+			expected: CapsuleSpec{
+				Interfaces: []CapsuleInterface{
+					{Name: "http", Port: 80},
+					{Name: "admin", Port: 81, Readiness: &InterfaceProbe{TCP: true}},
+				},
+				NodeSelector: map[string]string{"pool": "gpu"},
+				Scale: CapsuleScale{
+					Vertical: &VerticalScale{
+						CPU: &ResourceLimits{
+							Request: ptr.New(resource.MustParse("2")),
+						},
+						Memory: &ResourceLimits{
+							Request: ptr.New(resource.MustParse("1Gi")),
+						},
+					},
+				},
+			},
+			expectedAnnotations: map[string]string{"team": "payments"},
+		},
+		{
+			name:     "defaulted fields are kept while unchanged",
+			defaults: &configv1alpha1.CapsuleDefaults{NodeSelector: map[string]string{"pool": "apps", "zone": "a"}},
+			annotations: map[string]string{
+				AnnotationDefaultedFields: `{"spec.nodeSelector[pool]":"apps","spec.nodeSelector[zone]":"a"}`,
+			},
+			spec: CapsuleSpec{
+				NodeSelector: map[string]string{"pool": "apps", "zone": "b"},
+			},
+			expected: CapsuleSpec{
+				NodeSelector: map[string]string{"pool": "apps", "zone": "b"},
+			},
+			expectedAnnotations: map[string]string{
+				AnnotationDefaultedFields: `{"spec.nodeSelector[pool]":"apps"}`,
+			},
+		},
+	}
 
 	for i := range tests {
 		test := tests[i]
@@ -26,8 +147,19 @@ func TestDefault(t *testing.T) {
 			c := &Capsule{
 				Spec: test.spec,
 			}
-			c.Default()
-			assert.Equal(t, test.expected, c.Spec)
+			c.SetAnnotations(test.annotations)
+			assert.NoError(t, c.Default(test.defaults))
+			// Quantities are compared semantically, as they cache their
+			// string representation.
+			assert.True(
+				t, equality.Semantic.DeepEqual(test.expected, c.Spec),
+				"expected %+v, got %+v", test.expected, c.Spec,
+			)
+			if test.expectedAnnotations == nil {
+				assert.Empty(t, c.GetAnnotations())
+			} else {
+				assert.Equal(t, test.expectedAnnotations, c.GetAnnotations())
+			}
 		})
 	}
 }
@@ -47,6 +179,16 @@ func TestValidateSpec(t *testing.T) {
 				field.Required(specPath.Child("image"), ""),
 			},
 		},
+		{
+			name: "issuer name is required",
+			spec: CapsuleSpec{
+				Image:  "test",
+				Issuer: &IssuerReference{Kind: "Issuer"},
+			},
+			expectedErrs: field.ErrorList{
+				field.Required(specPath.Child("issuer").Child("name"), ""),
+			},
+		},
 	}
 
 	for i := range tests {
@@ -109,6 +251,174 @@ func TestValidateInterfaces(t *testing.T) {
 				field.Required(infsPath.Index(0).Child("public"), "ingress or loadBalancer is required"),
 			},
 		},
+		{
+			name: "public: loadBalancer options",
+			interfaces: []CapsuleInterface{
+				{
+					Name: "test1",
+					Port: 1,
+					Public: &CapsulePublicInterface{
+						LoadBalancer: &CapsuleInterfaceLoadBalancer{
+							Port:                  80,
+							SourceRanges:          []string{"10.0.0.0/8", "office"},
+							ExternalTrafficPolicy: v1.ServiceExternalTrafficPolicyLocal,
+							Annotations:           map[string]string{"lb/internal": "true"},
+						},
+					},
+				},
+				{
+					Name: "test2",
+					Port: 2,
+					Public: &CapsulePublicInterface{
+						LoadBalancer: &CapsuleInterfaceLoadBalancer{
+							Port:        53,
+							Protocol:    v1.ProtocolUDP,
+							Annotations: map[string]string{"lb/internal": "false"},
+						},
+					},
+				},
+			},
+			expectedErrs: field.ErrorList{
+				field.Invalid(
+					infsPath.Index(0).Child("public").Child("loadBalancer").Child("sourceRanges").Index(1),
+					"office", "must be a CIDR",
+				),
+				field.Invalid(
+					infsPath.Index(1).Child("public").Child("loadBalancer").Child("protocol"), v1.ProtocolUDP,
+					"must be TCP to match the protocol of the interface",
+				),
+				field.Invalid(
+					infsPath.Index(1).Child("public").Child("loadBalancer").Child("sourceRanges"),
+					[]string(nil), "must be the same for all interfaces with a loadBalancer",
+				),
+				field.Invalid(
+					infsPath.Index(1).Child("public").Child("loadBalancer").Child("externalTrafficPolicy"),
+					v1.ServiceExternalTrafficPolicy(""), "must be the same for all interfaces with a loadBalancer",
+				),
+				field.Invalid(
+					infsPath.Index(1).Child("public").Child("loadBalancer").Child("annotations").Key("lb/internal"),
+					"false", `conflicts with the value "true" of another interface`,
+				),
+			},
+		},
+		{
+			name: "protocols",
+			interfaces: []CapsuleInterface{
+				{
+					Name:      "dns",
+					Port:      53,
+					Protocol:  InterfaceProtocolUDP,
+					Readiness: &InterfaceProbe{TCP: true},
+					Public: &CapsulePublicInterface{
+						Ingress: &CapsuleInterfaceIngress{Host: "dns.example.com"},
+					},
+				},
+				{
+					Name: "http",
+					Port: 80,
+					Public: &CapsulePublicInterface{
+						Ingress: &CapsuleInterfaceIngress{Host: "example.com"},
+					},
+				},
+				{
+					Name:     "grpc",
+					Port:     5000,
+					Protocol: InterfaceProtocolGRPC,
+					Public: &CapsulePublicInterface{
+						Ingress: &CapsuleInterfaceIngress{Host: "grpc.example.com"},
+					},
+				},
+				{
+					Name:     "udp",
+					Port:     5001,
+					Protocol: InterfaceProtocolUDP,
+					Public: &CapsulePublicInterface{
+						LoadBalancer: &CapsuleInterfaceLoadBalancer{Port: 5001, Protocol: v1.ProtocolTCP},
+					},
+				},
+			},
+			expectedErrs: field.ErrorList{
+				field.Invalid(
+					infsPath.Index(0).Child("protocol"), InterfaceProtocolUDP,
+					"interfaces with an ingress must have the HTTP, HTTP2 or GRPC protocol",
+				),
+				field.Forbidden(infsPath.Index(0).Child("readiness"), "UDP interfaces can't be probed"),
+				field.Invalid(
+					infsPath.Index(2).Child("protocol"), InterfaceProtocolGRPC,
+					"must be the same for all interfaces with an ingress",
+				),
+				field.Invalid(
+					infsPath.Index(3).Child("public").Child("loadBalancer").Child("protocol"), v1.ProtocolTCP,
+					"must be UDP to match the protocol of the interface",
+				),
+			},
+		},
+		{
+			name: "public: ingress features",
+			interfaces: []CapsuleInterface{
+				{
+					Name: "test1",
+					Port: 1,
+					Public: &CapsulePublicInterface{
+						Ingress: &CapsuleInterfaceIngress{
+							Host:         "test1",
+							CORS:         &IngressCORS{AllowOrigins: []string{"https://example.com", "example.com"}},
+							RateLimit:    &IngressRateLimit{RequestsPerSecond: 10},
+							SourceRanges: []string{"10.0.0.0/8"},
+							BasicAuth:    &IngressBasicAuth{},
+							MaxBodySize:  ptr.New(resource.MustParse("0")),
+							Timeout:      &metav1.Duration{Duration: time.Millisecond},
+						},
+					},
+				},
+				{
+					Name: "test2",
+					Port: 2,
+					Public: &CapsulePublicInterface{
+						Ingress: &CapsuleInterfaceIngress{Host: "test2"},
+					},
+				},
+			},
+			expectedErrs: field.ErrorList{
+				field.Invalid(
+					infsPath.Index(0).Child("public").Child("ingress").Child("cors").Child("allowOrigins").Index(1),
+					"example.com", "must be * or an origin, such as https://example.com",
+				),
+				field.Required(infsPath.Index(0).Child("public").Child("ingress").Child("basicAuth").Child("secretName"), ""),
+				field.Invalid(infsPath.Index(0).Child("public").Child("ingress").Child("maxBodySize"), "0", "must be positive"),
+				field.Invalid(
+					infsPath.Index(0).Child("public").Child("ingress").Child("timeout"), "1ms", "must be at least one second",
+				),
+			},
+		},
+		{
+			name: "public: ingress issuers",
+			interfaces: []CapsuleInterface{
+				{
+					Name: "test1",
+					Port: 1,
+					Public: &CapsulePublicInterface{
+						Ingress: &CapsuleInterfaceIngress{
+							Host:   "test1",
+							Issuer: &IssuerReference{Kind: "Issuer", Name: "test"},
+						},
+					},
+				},
+				{
+					Name: "test2",
+					Port: 2,
+					Public: &CapsulePublicInterface{
+						Ingress: &CapsuleInterfaceIngress{
+							Host:   "test2",
+							Issuer: &IssuerReference{},
+						},
+					},
+				},
+			},
+			expectedErrs: field.ErrorList{
+				field.Required(infsPath.Index(1).Child("public").Child("ingress").Child("issuer").Child("name"), ""),
+			},
+		},
 		{
 			name: "public: ingress and loadBalancer are mutually exclusive",
 			interfaces: []CapsuleInterface{
@@ -517,6 +827,47 @@ func Test_HorizontalScaleValidate(t *testing.T) {
 				),
 			},
 		},
+		{
+			name: "disruption budget with both minAvailable and maxUnavailable",
+			h: HorizontalScale{
+				Instances: Instances{
+					Min: 3,
+				},
+				DisruptionBudget: &DisruptionBudget{
+					MinAvailable:   ptr.New(intstr.FromInt32(1)),
+					MaxUnavailable: ptr.New(intstr.FromString("50%")),
+				},
+			},
+			expectedErrs: []*field.Error{
+				field.Invalid(
+					path.Child("disruptionBudget"),
+					&DisruptionBudget{
+						MinAvailable:   ptr.New(intstr.FromInt32(1)),
+						MaxUnavailable: ptr.New(intstr.FromString("50%")),
+					},
+					"minAvailable and maxUnavailable are mutually exclusive",
+				),
+			},
+		},
+		{
+			name: "disabled disruption budget with maxUnavailable",
+			h: HorizontalScale{
+				Instances: Instances{
+					Min: 3,
+				},
+				DisruptionBudget: &DisruptionBudget{
+					Disabled:       true,
+					MaxUnavailable: ptr.New(intstr.FromInt32(1)),
+				},
+			},
+			expectedErrs: []*field.Error{
+				field.Invalid(
+					path.Child("disruptionBudget").Child("disabled"),
+					true,
+					"cannot be set together with minAvailable or maxUnavailable",
+				),
+			},
+		},
 		{
 			name: "invalid object metric, both value and averageValue",
 			h: HorizontalScale{
@@ -561,3 +912,643 @@ func Test_HorizontalScaleValidate(t *testing.T) {
 		})
 	}
 }
+
+func Test_SchedulingValidate(t *testing.T) {
+	t.Parallel()
+	path := field.NewPath("spec").Child("scheduling")
+	tests := []struct {
+		name         string
+		s            *Scheduling
+		expectedErrs field.ErrorList
+	}{
+		{
+			name: "no scheduling",
+		},
+		{
+			name: "spread without topology",
+			s: &Scheduling{
+				Spread: &Spread{Required: true},
+			},
+			expectedErrs: field.ErrorList{
+				field.Invalid(path.Child("spread"), &Spread{Required: true}, "one of `nodes` or `zones` must be set"),
+			},
+		},
+		{
+			name: "invalid topology spread constraint",
+			s: &Scheduling{
+				TopologySpreadConstraints: []v1.TopologySpreadConstraint{{
+					WhenUnsatisfiable: "Sometimes",
+				}},
+			},
+			expectedErrs: field.ErrorList{
+				field.Invalid(
+					path.Child("topologySpreadConstraints").Index(0).Child("maxSkew"),
+					int32(0),
+					"must be greater than zero",
+				),
+				field.Required(path.Child("topologySpreadConstraints").Index(0).Child("topologyKey"), ""),
+				field.NotSupported(
+					path.Child("topologySpreadConstraints").Index(0).Child("whenUnsatisfiable"),
+					v1.UnsatisfiableConstraintAction("Sometimes"),
+					[]string{string(v1.DoNotSchedule), string(v1.ScheduleAnyway)},
+				),
+			},
+		},
+		{
+			name: "good",
+			s: &Scheduling{
+				Spread: &Spread{Zones: true},
+				TopologySpreadConstraints: []v1.TopologySpreadConstraint{{
+					MaxSkew:           1,
+					TopologyKey:       v1.LabelTopologyZone,
+					WhenUnsatisfiable: v1.ScheduleAnyway,
+				}},
+				PriorityClassName: "high",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.s.validate(path)
+			assert.Equal(t, tt.expectedErrs, err)
+		})
+	}
+}
+
+func Test_LifecycleValidate(t *testing.T) {
+	t.Parallel()
+	path := field.NewPath("spec").Child("lifecycle")
+	tests := []struct {
+		name         string
+		l            *Lifecycle
+		expectedErrs field.ErrorList
+	}{
+		{
+			name: "no lifecycle",
+		},
+		{
+			name: "drain delay longer than grace period",
+			l: &Lifecycle{
+				TerminationGracePeriod: &metav1.Duration{Duration: 10 * time.Second},
+				DrainDelay:             &metav1.Duration{Duration: 15 * time.Second},
+			},
+			expectedErrs: field.ErrorList{
+				field.Invalid(path.Child("drainDelay"), "15s", "must be shorter than terminationGracePeriod"),
+			},
+		},
+		{
+			name: "drain delay with fractional seconds",
+			l: &Lifecycle{
+				DrainDelay: &metav1.Duration{Duration: 1500 * time.Millisecond},
+			},
+			expectedErrs: field.ErrorList{
+				field.Invalid(path.Child("drainDelay"), "1.5s", "must be a whole number of seconds"),
+			},
+		},
+		{
+			name: "drain delay with http pre stop hook",
+			l: &Lifecycle{
+				DrainDelay: &metav1.Duration{Duration: 5 * time.Second},
+				PreStop: &LifecycleHook{
+					HTTP: &HTTPHook{Path: "/shutdown", Port: 8080},
+				},
+			},
+			expectedErrs: field.ErrorList{
+				field.Invalid(path.Child("drainDelay"), "5s", "cannot be used with an http preStop hook"),
+			},
+		},
+		{
+			name: "invalid hooks",
+			l: &Lifecycle{
+				PreStop: &LifecycleHook{},
+				PostStart: &LifecycleHook{
+					Exec: &ExecHook{},
+					HTTP: &HTTPHook{Path: "started", Port: 8080},
+				},
+			},
+			expectedErrs: field.ErrorList{
+				field.Invalid(path.Child("preStop"), &LifecycleHook{}, "exactly one of `exec` and `http` must be provided"),
+				field.Invalid(
+					path.Child("postStart"),
+					&LifecycleHook{
+						Exec: &ExecHook{},
+						HTTP: &HTTPHook{Path: "started", Port: 8080},
+					},
+					"exactly one of `exec` and `http` must be provided",
+				),
+				field.Required(path.Child("postStart").Child("exec").Child("command"), ""),
+				field.Invalid(path.Child("postStart").Child("http").Child("path"), "started", "path must be an absolute path"),
+			},
+		},
+		{
+			name: "good",
+			l: &Lifecycle{
+				TerminationGracePeriod: &metav1.Duration{Duration: time.Minute},
+				DrainDelay:             &metav1.Duration{Duration: 5 * time.Second},
+				PreStop: &LifecycleHook{
+					Exec: &ExecHook{Command: []string{"/bin/shutdown"}},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.l.validate(path)
+			assert.Equal(t, tt.expectedErrs, err)
+		})
+	}
+}
+
+func Test_RolloutValidate(t *testing.T) {
+	t.Parallel()
+	path := field.NewPath("spec").Child("rollout")
+	tests := []struct {
+		name         string
+		r            *Rollout
+		expectedErrs field.ErrorList
+	}{
+		{
+			name: "no rollout",
+		},
+		{
+			name: "max surge and max unavailable both zero",
+			r: &Rollout{
+				MaxSurge:       ptr.New(intstr.FromInt32(0)),
+				MaxUnavailable: ptr.New(intstr.FromString("0%")),
+			},
+			expectedErrs: field.ErrorList{
+				field.Invalid(path.Child("maxUnavailable"), "0%", "cannot be 0 when maxSurge is 0"),
+			},
+		},
+		{
+			name: "invalid values",
+			r: &Rollout{
+				MaxSurge:       ptr.New(intstr.FromString("150%")),
+				MaxUnavailable: ptr.New(intstr.FromInt32(-1)),
+			},
+			expectedErrs: field.ErrorList{
+				field.Invalid(path.Child("maxSurge"), "150%", "cannot be more than 100%"),
+				field.Invalid(path.Child("maxUnavailable"), "-1", "cannot be negative"),
+			},
+		},
+		{
+			name: "progress deadline not greater than min ready seconds",
+			r: &Rollout{
+				ProgressDeadlineSeconds: ptr.New(int32(30)),
+				MinReadySeconds:         30,
+			},
+			expectedErrs: field.ErrorList{
+				field.Invalid(path.Child("progressDeadlineSeconds"), int32(30), "must be greater than minReadySeconds"),
+			},
+		},
+		{
+			name: "good",
+			r: &Rollout{
+				MaxSurge:                ptr.New(intstr.FromString("50%")),
+				MaxUnavailable:          ptr.New(intstr.FromInt32(0)),
+				ProgressDeadlineSeconds: ptr.New(int32(300)),
+				MinReadySeconds:         10,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.r.validate(path)
+			assert.Equal(t, tt.expectedErrs, err)
+		})
+	}
+}
+
+func Test_MonitoringValidate(t *testing.T) {
+	t.Parallel()
+	path := field.NewPath("spec").Child("monitoring")
+	tests := []struct {
+		name         string
+		spec         CapsuleSpec
+		expectedErrs field.ErrorList
+	}{
+		{
+			name: "no monitoring",
+		},
+		{
+			name: "service monitor without interfaces",
+			spec: CapsuleSpec{
+				Monitoring: &Monitoring{},
+			},
+			expectedErrs: field.ErrorList{
+				field.Invalid(path.Child("kind"), "", "a ServiceMonitor requires interfaces, use a PodMonitor instead"),
+			},
+		},
+		{
+			name: "service monitor with port number",
+			spec: CapsuleSpec{
+				Interfaces: []CapsuleInterface{{Name: "http", Port: 8080}},
+				Monitoring: &Monitoring{
+					Kind: "ServiceMonitor",
+					Port: ptr.New(intstr.FromInt32(9090)),
+				},
+			},
+			expectedErrs: field.ErrorList{
+				field.Invalid(path.Child("port"), "9090", "port numbers are only supported by a PodMonitor"),
+			},
+		},
+		{
+			name: "unknown port name and invalid rules",
+			spec: CapsuleSpec{
+				Interfaces: []CapsuleInterface{{Name: "http", Port: 8080}},
+				Monitoring: &Monitoring{
+					Port: ptr.New(intstr.FromString("metrics")),
+					Path: "metrics",
+					AlertRules: []AlertRule{
+						{Name: "Down", Expr: "up == 0"},
+						{Name: "Down"},
+					},
+				},
+			},
+			expectedErrs: field.ErrorList{
+				field.NotFound(path.Child("port"), "metrics"),
+				field.Invalid(path.Child("path"), "metrics", "path must be an absolute path"),
+				field.Duplicate(path.Child("alertRules").Index(1).Child("name"), "Down"),
+				field.Required(path.Child("alertRules").Index(1).Child("expr"), ""),
+			},
+		},
+		{
+			name: "fractional durations",
+			spec: CapsuleSpec{
+				Interfaces: []CapsuleInterface{{Name: "http", Port: 8080}},
+				Monitoring: &Monitoring{
+					Port:     ptr.New(intstr.FromString("http")),
+					Interval: &metav1.Duration{Duration: 1500 * time.Millisecond},
+					AlertRules: []AlertRule{
+						{Name: "Down", Expr: "up == 0", For: &metav1.Duration{Duration: 100 * time.Millisecond}},
+					},
+				},
+			},
+			expectedErrs: field.ErrorList{
+				field.Invalid(path.Child("interval"), "1.5s", "must be a whole number of seconds"),
+				field.Invalid(path.Child("alertRules").Index(0).Child("for"), "100ms", "must be a whole number of seconds"),
+			},
+		},
+		{
+			name: "good pod monitor",
+			spec: CapsuleSpec{
+				Monitoring: &Monitoring{
+					Kind:     "PodMonitor",
+					Port:     ptr.New(intstr.FromInt32(9090)),
+					Path:     "/metrics",
+					Interval: &metav1.Duration{Duration: 30 * time.Second},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Capsule{Spec: tt.spec}
+			_, err := c.validateMonitoring(nil)
+			assert.Equal(t, tt.expectedErrs, err)
+		})
+	}
+}
+
+func Test_MonitoringWarnings(t *testing.T) {
+	t.Parallel()
+	warning := "spec.monitoring has no port and the operator has no prometheusServiceMonitor.portName, " +
+		"so no monitor is created for the capsule"
+	interfaces := []CapsuleInterface{{Name: "http", Port: 8080}}
+	tests := []struct {
+		name       string
+		monitoring *Monitoring
+		cfg        *configv1alpha1.OperatorConfig
+		expected   []string
+	}{
+		{
+			name:       "no port without config port",
+			monitoring: &Monitoring{},
+			cfg:        &configv1alpha1.OperatorConfig{},
+			expected:   []string{warning},
+		},
+		{
+			name:       "no port with config port",
+			monitoring: &Monitoring{},
+			cfg: &configv1alpha1.OperatorConfig{
+				PrometheusServiceMonitor: &configv1alpha1.PrometheusServiceMonitor{PortName: "http"},
+			},
+		},
+		{
+			name:       "port",
+			monitoring: &Monitoring{Port: ptr.New(intstr.FromString("http"))},
+			cfg:        &configv1alpha1.OperatorConfig{},
+		},
+		{
+			name:       "disabled",
+			monitoring: &Monitoring{Disabled: true},
+			cfg:        &configv1alpha1.OperatorConfig{},
+		},
+		{
+			name:       "without config",
+			monitoring: &Monitoring{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Capsule{Spec: CapsuleSpec{Interfaces: interfaces, Monitoring: tt.monitoring}}
+			warns, errs := c.validateMonitoring(tt.cfg)
+			assert.Empty(t, errs)
+			assert.Equal(t, tt.expected, []string(warns))
+		})
+	}
+}
+
+func Test_DependsOnValidate(t *testing.T) {
+	t.Parallel()
+	path := field.NewPath("spec").Child("dependsOn")
+	tests := []struct {
+		name         string
+		dependsOn    []CapsuleDependency
+		expectedErrs field.ErrorList
+	}{
+		{
+			name: "no dependencies",
+		},
+		{
+			name: "invalid dependencies",
+			dependsOn: []CapsuleDependency{
+				{Interface: "http"},
+				{Capsule: "api"},
+				{Capsule: "db", Interface: "postgres"},
+				{Capsule: "db", Interface: "postgres"},
+			},
+			expectedErrs: field.ErrorList{
+				field.Required(path.Index(0).Child("capsule"), ""),
+				field.Invalid(path.Index(1).Child("capsule"), "api", "capsule cannot depend on itself"),
+				field.Duplicate(path.Index(3), CapsuleDependency{Capsule: "db", Interface: "postgres"}),
+			},
+		},
+		{
+			name: "good",
+			dependsOn: []CapsuleDependency{
+				{Capsule: "db", Interface: "postgres"},
+				{Capsule: "db", Interface: "metrics"},
+				{Capsule: "cache"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Capsule{
+				ObjectMeta: metav1.ObjectMeta{Name: "api"},
+				Spec:       CapsuleSpec{DependsOn: tt.dependsOn},
+			}
+			assert.Equal(t, tt.expectedErrs, c.validateDependsOn())
+		})
+	}
+}
+
+func Test_ConnectionsValidate(t *testing.T) {
+	t.Parallel()
+	path := field.NewPath("spec").Child("connections")
+	tests := []struct {
+		name         string
+		connections  []Connection
+		expectedErrs field.ErrorList
+	}{
+		{
+			name: "no connections",
+		},
+		{
+			name: "invalid connections",
+			connections: []Connection{
+				{},
+				{Name: "1db", Capsule: "db", Interface: "postgres"},
+				{Capsule: "payments-api", Interface: "http"},
+				{Name: "PAYMENTS_API", Capsule: "payments", Interface: "grpc"},
+			},
+			expectedErrs: field.ErrorList{
+				field.Required(path.Index(0).Child("capsule"), ""),
+				field.Required(path.Index(0).Child("interface"), ""),
+				field.Invalid(path.Index(1).Child("name"), "1db", validation.IsEnvVarName("1DB_HOST")[0]),
+				field.Duplicate(path.Index(3).Child("name"), "PAYMENTS_API"),
+			},
+		},
+		{
+			name: "good",
+			connections: []Connection{
+				{Capsule: "payments-api", Interface: "http"},
+				{Name: "payments-grpc", Capsule: "payments-api", Interface: "grpc", Scheme: "grpc"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Capsule{Spec: CapsuleSpec{Connections: tt.connections}}
+			assert.Equal(t, tt.expectedErrs, c.validateConnections())
+		})
+	}
+}
+
+func Test_GeneratedSecretsValidate(t *testing.T) {
+	t.Parallel()
+	path := field.NewPath("spec").Child("generatedSecrets")
+	tests := []struct {
+		name             string
+		generatedSecrets []GeneratedSecret
+		expectedErrs     field.ErrorList
+	}{
+		{
+			name: "no generated secrets",
+		},
+		{
+			name: "invalid generated secrets",
+			generatedSecrets: []GeneratedSecret{
+				{Length: 16},
+				{Key: "jwt", Type: GeneratedSecretTypeRSA, Bits: 1024, Charset: "abc"},
+				{Key: "jwt_PUB"},
+				{Key: "signing", Type: GeneratedSecretTypeEd25519, Length: 16},
+			},
+			expectedErrs: field.ErrorList{
+				field.Required(path.Index(0).Child("key"), ""),
+				field.Invalid(path.Index(1).Child("charset"), "abc", "must only be set for Random"),
+				field.Invalid(path.Index(1).Child("bits"), int32(1024), "must be between 2048 and 8192 and only set for RSA"),
+				field.Duplicate(path.Index(2).Child("key"), "jwt_PUB"),
+				field.Invalid(path.Index(3).Child("length"), int32(16), "must be positive and only set for Random"),
+			},
+		},
+		{
+			name: "too long random value with duplicate characters",
+			generatedSecrets: []GeneratedSecret{
+				{Key: "TOKEN", Length: 1_000_000_000, Charset: "abca"},
+			},
+			expectedErrs: field.ErrorList{
+				field.Invalid(path.Index(0).Child("length"), int32(1_000_000_000), "must not exceed 4096"),
+				field.Invalid(path.Index(0).Child("charset"), "abca", "must not contain duplicate characters"),
+			},
+		},
+		{
+			name: "good",
+			generatedSecrets: []GeneratedSecret{
+				{Key: "DB_PASSWORD"},
+				{Key: "PIN", Length: 6, Charset: "0123456789"},
+				{Key: "JWT_KEY", Type: GeneratedSecretTypeRSA, Bits: 4096},
+				{Key: "SIGNING_KEY", Type: GeneratedSecretTypeEd25519},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Capsule{Spec: CapsuleSpec{GeneratedSecrets: tt.generatedSecrets}}
+			assert.Equal(t, tt.expectedErrs, c.validateGeneratedSecrets())
+		})
+	}
+}
+
+func Test_TTLValidate(t *testing.T) {
+	t.Parallel()
+	path := field.NewPath("spec").Child("ttl")
+	annotationPath := field.NewPath("metadata").Child("annotations").Key(AnnotationLeaseExtendedUntil)
+	tests := []struct {
+		name         string
+		ttl          *CapsuleTTL
+		annotations  map[string]string
+		expectedErrs field.ErrorList
+	}{
+		{
+			name: "no ttl",
+		},
+		{
+			name: "invalid ttl",
+			ttl:  &CapsuleTTL{},
+			annotations: map[string]string{
+				AnnotationLeaseExtendedUntil: "tomorrow",
+			},
+			expectedErrs: field.ErrorList{
+				field.Invalid(annotationPath, "tomorrow", "must be an RFC 3339 time"),
+				field.Required(path, "one of expiresAt and idleTimeout must be set"),
+			},
+		},
+		{
+			name: "negative idle timeout",
+			ttl:  &CapsuleTTL{IdleTimeout: &metav1.Duration{Duration: -time.Hour}},
+			expectedErrs: field.ErrorList{
+				field.Invalid(path.Child("idleTimeout"), "-1h0m0s", "must be positive"),
+			},
+		},
+		{
+			name: "good",
+			ttl: &CapsuleTTL{
+				ExpiresAt:   &metav1.Time{Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+				IdleTimeout: &metav1.Duration{Duration: 24 * time.Hour},
+			},
+			annotations: map[string]string{
+				AnnotationLeaseExtendedUntil: "2024-01-02T00:00:00Z",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Capsule{
+				ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations},
+				Spec:       CapsuleSpec{TTL: tt.ttl},
+			}
+			assert.Equal(t, tt.expectedErrs, c.validateTTL())
+		})
+	}
+}
+
+func Test_InterfaceIssuersValidate(t *testing.T) {
+	t.Parallel()
+	path := field.NewPath("spec").Child("interfaces").Index(1).Child("public", "ingress", "issuer")
+	capsule := &Capsule{Spec: CapsuleSpec{Interfaces: []CapsuleInterface{
+		{
+			Name:   "http",
+			Public: &CapsulePublicInterface{Ingress: &CapsuleInterfaceIngress{Host: "a.example.com"}},
+		},
+		{
+			Name: "admin",
+			Public: &CapsulePublicInterface{Ingress: &CapsuleInterfaceIngress{
+				Host:   "b.example.com",
+				Issuer: &IssuerReference{Name: "internal"},
+			}},
+		},
+	}}}
+
+	tests := []struct {
+		name         string
+		cfg          *configv1alpha1.CertManagerConfig
+		expectedErrs field.ErrorList
+	}{
+		{
+			name: "no cert-manager",
+			expectedErrs: field.ErrorList{
+				field.Forbidden(path, "requires the operator to create certificate resources"),
+			},
+		},
+		{
+			name: "ingress annotations",
+			cfg:  &configv1alpha1.CertManagerConfig{ClusterIssuer: "letsencrypt"},
+			expectedErrs: field.ErrorList{
+				field.Forbidden(path, "requires the operator to create certificate resources"),
+			},
+		},
+		{
+			name: "certificate resources",
+			cfg: &configv1alpha1.CertManagerConfig{
+				ClusterIssuer:              "letsencrypt",
+				CreateCertificateResources: true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedErrs, capsule.validateInterfaceIssuers(tt.cfg))
+		})
+	}
+}
+
+func Test_IdleTimeoutWarnings(t *testing.T) {
+	t.Parallel()
+	idle := &CapsuleTTL{IdleTimeout: &metav1.Duration{Duration: time.Hour}}
+	tests := []struct {
+		name          string
+		ttl           *CapsuleTTL
+		prometheusURL string
+		expected      []string
+	}{
+		{
+			name: "no ttl",
+		},
+		{
+			name: "expires at",
+			ttl:  &CapsuleTTL{ExpiresAt: &metav1.Time{Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}},
+		},
+		{
+			name: "idle timeout without prometheus",
+			ttl:  idle,
+			expected: []string{
+				"spec.ttl.idleTimeout is not enforced, as the operator has no cleanup.prometheusURL to query the traffic",
+			},
+		},
+		{
+			name:          "idle timeout with prometheus",
+			ttl:           idle,
+			prometheusURL: "http://prometheus:9090",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Capsule{Spec: CapsuleSpec{TTL: tt.ttl}}
+			warns := c.idleTimeoutWarnings(configv1alpha1.CleanupConfig{PrometheusURL: tt.prometheusURL})
+			assert.Equal(t, tt.expected, []string(warns))
+		})
+	}
+}
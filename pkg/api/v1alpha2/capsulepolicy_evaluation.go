@@ -0,0 +1,126 @@
+package v1alpha2
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// evaluate returns the violations of the policy by the capsule.
+func (p *CapsulePolicy) evaluate(c *Capsule) field.ErrorList {
+	var errs field.ErrorList
+	spec := p.Spec
+	source := fmt.Sprintf("CapsulePolicy %s", p.Name)
+
+	for _, l := range spec.RequiredLabels {
+		if _, ok := c.GetLabels()[l]; !ok {
+			errs = append(errs, field.Required(
+				field.NewPath("metadata").Child("labels").Key(l), fmt.Sprintf("required by %s", source),
+			))
+		}
+	}
+
+	if m := spec.MaxInstances; m != nil {
+		instances := c.Spec.Scale.Horizontal.Instances
+		iPath := field.NewPath("spec").Child("scale").Child("horizontal").Child("instances")
+		if instances.Min > *m {
+			errs = append(errs, field.Invalid(
+				iPath.Child("min"), instances.Min, fmt.Sprintf("must not exceed %d, the maximum of %s", *m, source),
+			))
+		}
+		if instances.Max != nil && *instances.Max > *m {
+			errs = append(errs, field.Invalid(
+				iPath.Child("max"), *instances.Max, fmt.Sprintf("must not exceed %d, the maximum of %s", *m, source),
+			))
+		}
+	}
+
+	verticalPath := field.NewPath("spec").Child("scale").Child("vertical")
+	var cpu, memory *ResourceLimits
+	if v := c.Spec.Scale.Vertical; v != nil {
+		cpu, memory = v.CPU, v.Memory
+	}
+	errs = append(errs, evaluateMaxResource(cpu, spec.MaxCPU, verticalPath.Child("cpu"), source)...)
+	errs = append(errs, evaluateMaxResource(memory, spec.MaxMemory, verticalPath.Child("memory"), source)...)
+
+	infsPath := field.NewPath("spec").Child("interfaces")
+	for i, inf := range c.Spec.Interfaces {
+		if inf.Public == nil {
+			continue
+		}
+		publicPath := infsPath.Index(i).Child("public")
+
+		if inf.Public.LoadBalancer != nil && spec.AllowLoadBalancers != nil && !*spec.AllowLoadBalancers {
+			errs = append(errs, field.Forbidden(
+				publicPath.Child("loadBalancer"), fmt.Sprintf("load balancers are not allowed by %s", source),
+			))
+		}
+
+		if ing := inf.Public.Ingress; ing != nil && len(spec.AllowedIngressHostSuffixes) > 0 {
+			allowed := false
+			for _, suffix := range spec.AllowedIngressHostSuffixes {
+				if hasHostSuffix(ing.Host, suffix) {
+					allowed = true
+					break
+				}
+			}
+			if !allowed {
+				errs = append(errs, field.Invalid(
+					publicPath.Child("ingress").Child("host"), ing.Host,
+					fmt.Sprintf(
+						"must end with one of %s, as required by %s",
+						strings.Join(spec.AllowedIngressHostSuffixes, ", "), source,
+					),
+				))
+			}
+		}
+	}
+
+	return errs
+}
+
+// hasHostSuffix returns true if the host ends with the suffix on a label
+// boundary, so example.com matches example.com and www.example.com, but not
+// evilexample.com.
+func hasHostSuffix(host, suffix string) bool {
+	if strings.HasPrefix(suffix, ".") {
+		return strings.HasSuffix(host, suffix)
+	}
+	return host == suffix || strings.HasSuffix(host, "."+suffix)
+}
+
+// evaluateMaxResource returns the violations of a maximum of a resource by
+// the request and limit of the resource. Without a limit, the usage of the
+// resource is unbounded, which violates the maximum.
+func evaluateMaxResource(
+	limits *ResourceLimits,
+	max *resource.Quantity,
+	rPath *field.Path,
+	source string,
+) field.ErrorList {
+	if max == nil {
+		return nil
+	}
+	if limits == nil || limits.Limit == nil {
+		return field.ErrorList{field.Required(
+			rPath.Child("limit"), fmt.Sprintf("must be set, as %s sets a maximum of %s", source, max.String()),
+		)}
+	}
+
+	var errs field.ErrorList
+	if limits.Request != nil && limits.Request.Cmp(*max) > 0 {
+		errs = append(errs, field.Invalid(
+			rPath.Child("request"), limits.Request.String(),
+			fmt.Sprintf("must not exceed %s, the maximum of %s", max.String(), source),
+		))
+	}
+	if limits.Limit != nil && limits.Limit.Cmp(*max) > 0 {
+		errs = append(errs, field.Invalid(
+			rPath.Child("limit"), limits.Limit.String(),
+			fmt.Sprintf("must not exceed %s, the maximum of %s", max.String(), source),
+		))
+	}
+	return errs
+}
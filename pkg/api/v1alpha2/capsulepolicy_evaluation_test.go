@@ -0,0 +1,149 @@
+package v1alpha2
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"github.com/rigdev/rig/pkg/ptr"
+)
+
+func Test_CapsulePolicyEvaluate(t *testing.T) {
+	t.Parallel()
+
+	instancesPath := field.NewPath("spec").Child("scale").Child("horizontal").Child("instances")
+	cpuPath := field.NewPath("spec").Child("scale").Child("vertical").Child("cpu")
+	publicPath := field.NewPath("spec").Child("interfaces").Index(0).Child("public")
+
+	tests := []struct {
+		name     string
+		policy   CapsulePolicySpec
+		capsule  Capsule
+		expected field.ErrorList
+	}{
+		{
+			name:    "empty policy allows everything",
+			capsule: Capsule{Spec: CapsuleSpec{Scale: CapsuleScale{Horizontal: HorizontalScale{Instances: Instances{Min: 10}}}}},
+		},
+		{
+			name:   "required labels",
+			policy: CapsulePolicySpec{RequiredLabels: []string{"team", "tier"}},
+			capsule: Capsule{ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{"team": "payments"},
+			}},
+			expected: field.ErrorList{
+				field.Required(
+					field.NewPath("metadata").Child("labels").Key("tier"), "required by CapsulePolicy guardrails",
+				),
+			},
+		},
+		{
+			name:   "max instances",
+			policy: CapsulePolicySpec{MaxInstances: ptr.New(uint32(5))},
+			capsule: Capsule{Spec: CapsuleSpec{Scale: CapsuleScale{Horizontal: HorizontalScale{
+				Instances: Instances{Min: 2, Max: ptr.New(uint32(10))},
+			}}}},
+			expected: field.ErrorList{
+				field.Invalid(instancesPath.Child("max"), uint32(10), "must not exceed 5, the maximum of CapsulePolicy guardrails"),
+			},
+		},
+		{
+			name:   "max cpu",
+			policy: CapsulePolicySpec{MaxCPU: ptr.New(resource.MustParse("1"))},
+			capsule: Capsule{Spec: CapsuleSpec{Scale: CapsuleScale{Vertical: &VerticalScale{
+				CPU: &ResourceLimits{
+					Request: ptr.New(resource.MustParse("500m")),
+					Limit:   ptr.New(resource.MustParse("2")),
+				},
+			}}}},
+			expected: field.ErrorList{
+				field.Invalid(cpuPath.Child("limit"), "2", "must not exceed 1, the maximum of CapsulePolicy guardrails"),
+			},
+		},
+		{
+			name:   "max cpu and memory without limits",
+			policy: CapsulePolicySpec{MaxCPU: ptr.New(resource.MustParse("1")), MaxMemory: ptr.New(resource.MustParse("1Gi"))},
+			capsule: Capsule{Spec: CapsuleSpec{Scale: CapsuleScale{Vertical: &VerticalScale{
+				CPU: &ResourceLimits{Request: ptr.New(resource.MustParse("500m"))},
+			}}}},
+			expected: field.ErrorList{
+				field.Required(cpuPath.Child("limit"), "must be set, as CapsulePolicy guardrails sets a maximum of 1"),
+				field.Required(
+					field.NewPath("spec").Child("scale").Child("vertical").Child("memory").Child("limit"),
+					"must be set, as CapsulePolicy guardrails sets a maximum of 1Gi",
+				),
+			},
+		},
+		{
+			name:   "load balancers are not allowed",
+			policy: CapsulePolicySpec{AllowLoadBalancers: ptr.New(false)},
+			capsule: Capsule{Spec: CapsuleSpec{Interfaces: []CapsuleInterface{{
+				Name:   "tcp",
+				Port:   5432,
+				Public: &CapsulePublicInterface{LoadBalancer: &CapsuleInterfaceLoadBalancer{Port: 5432}},
+			}}}},
+			expected: field.ErrorList{
+				field.Forbidden(
+					publicPath.Child("loadBalancer"), "load balancers are not allowed by CapsulePolicy guardrails",
+				),
+			},
+		},
+		{
+			name:   "ingress host suffixes",
+			policy: CapsulePolicySpec{AllowedIngressHostSuffixes: []string{".apps.example.com"}},
+			capsule: Capsule{Spec: CapsuleSpec{Interfaces: []CapsuleInterface{{
+				Name:   "http",
+				Port:   80,
+				Public: &CapsulePublicInterface{Ingress: &CapsuleInterfaceIngress{Host: "www.example.com"}},
+			}}}},
+			expected: field.ErrorList{
+				field.Invalid(
+					publicPath.Child("ingress").Child("host"), "www.example.com",
+					"must end with one of .apps.example.com, as required by CapsulePolicy guardrails",
+				),
+			},
+		},
+		{
+			name:   "ingress host suffixes match on label boundaries",
+			policy: CapsulePolicySpec{AllowedIngressHostSuffixes: []string{"example.com"}},
+			capsule: Capsule{Spec: CapsuleSpec{Interfaces: []CapsuleInterface{
+				{
+					Name:   "http",
+					Port:   80,
+					Public: &CapsulePublicInterface{Ingress: &CapsuleInterfaceIngress{Host: "evilexample.com"}},
+				},
+				{
+					Name:   "www",
+					Port:   81,
+					Public: &CapsulePublicInterface{Ingress: &CapsuleInterfaceIngress{Host: "www.example.com"}},
+				},
+				{
+					Name:   "apex",
+					Port:   82,
+					Public: &CapsulePublicInterface{Ingress: &CapsuleInterfaceIngress{Host: "example.com"}},
+				},
+			}}},
+			expected: field.ErrorList{
+				field.Invalid(
+					publicPath.Child("ingress").Child("host"), "evilexample.com",
+					"must end with one of example.com, as required by CapsulePolicy guardrails",
+				),
+			},
+		},
+	}
+
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			p := &CapsulePolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "guardrails"},
+				Spec:       test.policy,
+			}
+			assert.Equal(t, test.expected, p.evaluate(&test.capsule))
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package v1alpha2
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CapsulePolicySpec defines the guardrails of the capsules in the namespace
+// of the policy. Omitted fields are not enforced.
+type CapsulePolicySpec struct {
+	// Enforcement specifies how violations of the policy are reported. Deny
+	// rejects capsules which violate the policy, and Warn admits them with a
+	// warning. Defaults to Deny.
+	//+kubebuilder:validation:Enum=Deny;Warn
+	Enforcement PolicyEnforcement `json:"enforcement,omitempty"`
+
+	// MaxCPU is the maximum CPU request and limit of each instance of a
+	// capsule. Capsules must set a CPU limit if this is set.
+	MaxCPU *resource.Quantity `json:"maxCPU,omitempty"`
+
+	// MaxMemory is the maximum memory request and limit of each instance of
+	// a capsule. Capsules must set a memory limit if this is set.
+	MaxMemory *resource.Quantity `json:"maxMemory,omitempty"`
+
+	// MaxInstances is the maximum number of instances of a capsule.
+	MaxInstances *uint32 `json:"maxInstances,omitempty"`
+
+	// AllowLoadBalancers specifies if interfaces can be published using a
+	// LoadBalancer Service. Defaults to true.
+	AllowLoadBalancers *bool `json:"allowLoadBalancers,omitempty"`
+
+	// AllowedIngressHostSuffixes are the domain suffixes which the hosts of
+	// ingress interfaces must have. A suffix with a leading dot, e.g.
+	// .apps.example.com, only allows subdomains, while apps.example.com also
+	// allows the domain itself. All hosts are allowed if empty.
+	AllowedIngressHostSuffixes []string `json:"allowedIngressHostSuffixes,omitempty"`
+
+	// RequiredLabels are the labels which capsules must have.
+	RequiredLabels []string `json:"requiredLabels,omitempty"`
+}
+
+// PolicyEnforcement is the enforcement mode of a policy.
+type PolicyEnforcement string
+
+const (
+	// PolicyEnforcementDeny rejects capsules which violate the policy.
+	PolicyEnforcementDeny PolicyEnforcement = "Deny"
+	// PolicyEnforcementWarn admits capsules which violate the policy with a
+	// warning.
+	PolicyEnforcementWarn PolicyEnforcement = "Warn"
+)
+
+//+kubebuilder:object:root=true
+//+kubebuilder:printcolumn:name="Enforcement",type=string,JSONPath=`.spec.enforcement`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// CapsulePolicy is the Schema for the capsulepolicies API. The policies of
+// a namespace are evaluated by the validating webhook of capsules when they
+// are created or updated.
+type CapsulePolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec holds the specification of the CapsulePolicy.
+	Spec CapsulePolicySpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// CapsulePolicyList contains a list of CapsulePolicy
+type CapsulePolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []CapsulePolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&CapsulePolicy{}, &CapsulePolicyList{})
+}
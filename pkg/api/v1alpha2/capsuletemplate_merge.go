@@ -0,0 +1,131 @@
+package v1alpha2
+
+import (
+	"context"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// GetTemplate returns the spec of the template referenced by the capsule, or
+// nil if the capsule has no template.
+func (c *Capsule) GetTemplate(ctx context.Context, r client.Reader) (*CapsuleTemplateSpec, error) {
+	ref := c.Spec.Template
+	if ref == nil {
+		return nil, nil
+	}
+
+	if ref.GetKind() == TemplateKindClusterCapsuleTemplate {
+		t := &ClusterCapsuleTemplate{}
+		if err := r.Get(ctx, types.NamespacedName{Name: ref.Name}, t); err != nil {
+			return nil, err
+		}
+		return &t.Spec, nil
+	}
+
+	t := &CapsuleTemplate{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: c.Namespace, Name: ref.Name}, t); err != nil {
+		return nil, err
+	}
+	return &t.Spec, nil
+}
+
+// WithTemplate returns a copy of the capsule with its spec merged onto the
+// template, as documented on CapsuleSpec.Template. The copy no longer
+// references the template.
+func (c *Capsule) WithTemplate(t *CapsuleTemplateSpec) *Capsule {
+	res := c.DeepCopy()
+	t = t.DeepCopy()
+	spec := &res.Spec
+	spec.Template = nil
+
+	if spec.Command == "" {
+		spec.Command = t.Command
+	}
+	if spec.Args == nil {
+		spec.Args = t.Args
+	}
+
+	spec.Interfaces = mergeInterfaces(t.Interfaces, spec.Interfaces)
+
+	if reflect.ValueOf(spec.Scale.Horizontal).IsZero() {
+		spec.Scale.Horizontal = t.Scale.Horizontal
+	}
+	spec.Scale.Vertical = mergeVerticalScale(t.Scale.Vertical, spec.Scale.Vertical)
+
+	for k, v := range t.NodeSelector {
+		if _, ok := spec.NodeSelector[k]; ok {
+			continue
+		}
+		if spec.NodeSelector == nil {
+			spec.NodeSelector = map[string]string{}
+		}
+		spec.NodeSelector[k] = v
+	}
+
+	if spec.Scheduling == nil {
+		spec.Scheduling = t.Scheduling
+	}
+	if spec.Lifecycle == nil {
+		spec.Lifecycle = t.Lifecycle
+	}
+	if spec.Rollout == nil {
+		spec.Rollout = t.Rollout
+	}
+	if spec.Monitoring == nil {
+		spec.Monitoring = t.Monitoring
+	}
+
+	return res
+}
+
+// mergeInterfaces returns the interfaces of the template, with those of the
+// capsule replacing interfaces of the same name. Interfaces only in the
+// capsule are added after the interfaces of the template.
+func mergeInterfaces(template, capsule []CapsuleInterface) []CapsuleInterface {
+	if len(template) == 0 {
+		return capsule
+	}
+
+	byName := map[string]CapsuleInterface{}
+	for _, inf := range capsule {
+		byName[inf.Name] = inf
+	}
+
+	res := make([]CapsuleInterface, 0, len(template)+len(capsule))
+	for _, inf := range template {
+		if c, ok := byName[inf.Name]; ok {
+			inf = c
+			delete(byName, inf.Name)
+		}
+		res = append(res, inf)
+	}
+	for _, inf := range capsule {
+		if _, ok := byName[inf.Name]; ok {
+			res = append(res, inf)
+		}
+	}
+	return res
+}
+
+func mergeVerticalScale(template, capsule *VerticalScale) *VerticalScale {
+	if template == nil {
+		return capsule
+	}
+	if capsule == nil {
+		return template
+	}
+
+	res := *capsule
+	if res.CPU == nil {
+		res.CPU = template.CPU
+	}
+	if res.Memory == nil {
+		res.Memory = template.Memory
+	}
+	if res.GPU == nil {
+		res.GPU = template.GPU
+	}
+	return &res
+}
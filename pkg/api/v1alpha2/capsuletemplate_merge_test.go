@@ -0,0 +1,99 @@
+package v1alpha2
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/rigdev/rig/pkg/ptr"
+)
+
+func TestCapsuleWithTemplate(t *testing.T) {
+	t.Parallel()
+
+	template := &CapsuleTemplateSpec{
+		Command: "/bin/server",
+		Args:    []string{"serve"},
+		Interfaces: []CapsuleInterface{
+			{Name: "http", Port: 8080, Readiness: &InterfaceProbe{Path: "/ready"}},
+			{Name: "metrics", Port: 9090},
+		},
+		Scale: CapsuleScale{
+			Horizontal: HorizontalScale{Instances: Instances{Min: 2}},
+			Vertical: &VerticalScale{
+				CPU:    &ResourceLimits{Request: ptr.New(resource.MustParse("100m"))},
+				Memory: &ResourceLimits{Request: ptr.New(resource.MustParse("128Mi"))},
+			},
+		},
+		NodeSelector: map[string]string{"pool": "default", "arch": "amd64"},
+		Rollout:      &Rollout{MinReadySeconds: 10},
+	}
+
+	tests := []struct {
+		name     string
+		spec     CapsuleSpec
+		expected CapsuleSpec
+	}{
+		{
+			name: "template only",
+			spec: CapsuleSpec{Image: "api:1"},
+			expected: CapsuleSpec{
+				Image:        "api:1",
+				Command:      template.Command,
+				Args:         template.Args,
+				Interfaces:   template.Interfaces,
+				Scale:        template.Scale,
+				NodeSelector: template.NodeSelector,
+				Rollout:      template.Rollout,
+			},
+		},
+		{
+			name: "capsule takes precedence",
+			spec: CapsuleSpec{
+				Image: "api:1",
+				Args:  []string{"worker"},
+				Interfaces: []CapsuleInterface{
+					{Name: "grpc", Port: 5000},
+					{Name: "http", Port: 8000},
+				},
+				Scale: CapsuleScale{
+					Horizontal: HorizontalScale{Instances: Instances{Min: 1, Max: ptr.New(uint32(4))}},
+					Vertical: &VerticalScale{
+						CPU: &ResourceLimits{Request: ptr.New(resource.MustParse("1"))},
+					},
+				},
+				NodeSelector: map[string]string{"pool": "batch"},
+			},
+			expected: CapsuleSpec{
+				Image:   "api:1",
+				Command: template.Command,
+				Args:    []string{"worker"},
+				Interfaces: []CapsuleInterface{
+					{Name: "http", Port: 8000},
+					{Name: "metrics", Port: 9090},
+					{Name: "grpc", Port: 5000},
+				},
+				Scale: CapsuleScale{
+					Horizontal: HorizontalScale{Instances: Instances{Min: 1, Max: ptr.New(uint32(4))}},
+					Vertical: &VerticalScale{
+						CPU:    &ResourceLimits{Request: ptr.New(resource.MustParse("1"))},
+						Memory: template.Scale.Vertical.Memory,
+					},
+				},
+				NodeSelector: map[string]string{"pool": "batch", "arch": "amd64"},
+				Rollout:      template.Rollout,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.spec.Template = &TemplateReference{Name: "template"}
+			c := &Capsule{Spec: tt.spec}
+			merged := c.WithTemplate(template)
+			assert.Equal(t, tt.expected, merged.Spec)
+			assert.NotNil(t, c.Spec.Template)
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package v1alpha2
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CapsuleTemplateSpec holds the fields of a capsule spec which can be shared
+// by capsules through a template. The fields are documented on CapsuleSpec.
+type CapsuleTemplateSpec struct {
+	// Command is the command of the capsules.
+	Command string `json:"command,omitempty"`
+
+	// Args are the arguments of the capsules.
+	Args []string `json:"args,omitempty"`
+
+	// Interfaces are the interfaces of the capsules.
+	Interfaces []CapsuleInterface `json:"interfaces,omitempty"`
+
+	// Scale is the scaling of the capsules.
+	Scale CapsuleScale `json:"scale,omitempty"`
+
+	// NodeSelector selects the nodes the capsules should live on.
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Scheduling is the scheduling of the capsule instances.
+	Scheduling *Scheduling `json:"scheduling,omitempty"`
+
+	// Lifecycle is the lifecycle of the capsule instances.
+	Lifecycle *Lifecycle `json:"lifecycle,omitempty"`
+
+	// Rollout is the rollout of new versions of the capsules.
+	Rollout *Rollout `json:"rollout,omitempty"`
+
+	// Monitoring is the monitoring of the capsules.
+	Monitoring *Monitoring `json:"monitoring,omitempty"`
+}
+
+// TemplateReference references the template of a capsule.
+type TemplateReference struct {
+	// Kind is the kind of the template. A CapsuleTemplate must be in the
+	// namespace of the Capsule. Defaults to CapsuleTemplate.
+	//+kubebuilder:validation:Enum=CapsuleTemplate;ClusterCapsuleTemplate
+	Kind string `json:"kind,omitempty"`
+
+	// Name is the name of the template.
+	Name string `json:"name"`
+}
+
+const (
+	TemplateKindCapsuleTemplate        = "CapsuleTemplate"
+	TemplateKindClusterCapsuleTemplate = "ClusterCapsuleTemplate"
+)
+
+// GetKind returns the kind of the template, defaulting to CapsuleTemplate.
+func (t TemplateReference) GetKind() string {
+	if t.Kind == "" {
+		return TemplateKindCapsuleTemplate
+	}
+	return t.Kind
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// CapsuleTemplate is the Schema for the capsuletemplates API. A
+// CapsuleTemplate holds a spec shared by capsules in its namespace, which
+// reference it from spec.template.
+type CapsuleTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec holds the specification of the CapsuleTemplate.
+	Spec CapsuleTemplateSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// CapsuleTemplateList contains a list of CapsuleTemplate
+type CapsuleTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []CapsuleTemplate `json:"items"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ClusterCapsuleTemplate is the Schema for the clustercapsuletemplates API.
+// A ClusterCapsuleTemplate holds a spec shared by capsules in all
+// namespaces, which reference it from spec.template.
+type ClusterCapsuleTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec holds the specification of the ClusterCapsuleTemplate.
+	Spec CapsuleTemplateSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ClusterCapsuleTemplateList contains a list of ClusterCapsuleTemplate
+type ClusterCapsuleTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []ClusterCapsuleTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(
+		&CapsuleTemplate{}, &CapsuleTemplateList{},
+		&ClusterCapsuleTemplate{}, &ClusterCapsuleTemplateList{},
+	)
+}
@@ -0,0 +1,104 @@
+package v1alpha2
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// SetupWebhookWithManager registers the validating webhook of the capsule
+// template.
+func (t *CapsuleTemplate) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(t).
+		WithValidator(&capsuleTemplateValidator{}).
+		Complete()
+}
+
+// SetupWebhookWithManager registers the validating webhook of the cluster
+// capsule template.
+func (t *ClusterCapsuleTemplate) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(t).
+		WithValidator(&capsuleTemplateValidator{}).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-rig-dev-v1alpha2-capsuletemplate,mutating=false,failurePolicy=fail,sideEffects=None,groups=rig.dev,resources=capsuletemplates,verbs=create;update,versions=v1alpha2,name=vcapsuletemplate.kb.io,admissionReviewVersions=v1
+//+kubebuilder:webhook:path=/validate-rig-dev-v1alpha2-clustercapsuletemplate,mutating=false,failurePolicy=fail,sideEffects=None,groups=rig.dev,resources=clustercapsuletemplates,verbs=create;update,versions=v1alpha2,name=vclustercapsuletemplate.kb.io,admissionReviewVersions=v1
+
+// capsuleTemplateValidator validates both CapsuleTemplates and
+// ClusterCapsuleTemplates.
+type capsuleTemplateValidator struct{}
+
+var _ webhook.CustomValidator = &capsuleTemplateValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type
+func (v *capsuleTemplateValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return v.validate(obj)
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type
+func (v *capsuleTemplateValidator) ValidateUpdate(
+	_ context.Context,
+	_ runtime.Object,
+	newObj runtime.Object,
+) (admission.Warnings, error) {
+	return v.validate(newObj)
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type
+func (v *capsuleTemplateValidator) ValidateDelete(context.Context, runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *capsuleTemplateValidator) validate(obj runtime.Object) (admission.Warnings, error) {
+	switch t := obj.(type) {
+	case *CapsuleTemplate:
+		warns, errs := t.Spec.Validate()
+		return warns, errs.ToAggregate()
+	case *ClusterCapsuleTemplate:
+		warns, errs := t.Spec.Validate()
+		return warns, errs.ToAggregate()
+	default:
+		return nil, fmt.Errorf("expected a CapsuleTemplate or ClusterCapsuleTemplate but got a %T", obj)
+	}
+}
+
+// Validate validates the template spec with the capsule validation of its
+// fields, as the spec of a capsule adding nothing to the template. Fields
+// of the capsule which are not part of a template, such as the image, are
+// not required.
+func (t *CapsuleTemplateSpec) Validate() (admission.Warnings, field.ErrorList) {
+	c := (&Capsule{}).WithTemplate(t)
+
+	var (
+		allWarns admission.Warnings
+		allErrs  field.ErrorList
+	)
+
+	warns, errs := c.validateInterfaces()
+	allWarns = append(allWarns, warns...)
+	allErrs = append(allErrs, errs...)
+
+	allErrs = append(allErrs, c.Spec.Scale.Horizontal.validate(field.NewPath("scale").Child("horizontal"))...)
+
+	allErrs = append(allErrs, c.Spec.Scheduling.validate(field.NewPath("spec").Child("scheduling"))...)
+
+	allErrs = append(allErrs, c.Spec.Lifecycle.validate(field.NewPath("spec").Child("lifecycle"))...)
+
+	allErrs = append(allErrs, c.Spec.Rollout.validate(field.NewPath("spec").Child("rollout"))...)
+
+	// The port of the monitoring can be set by the capsules of the template,
+	// so the template is validated without the operator config.
+	warns, errs = c.validateMonitoring(nil)
+	allWarns = append(allWarns, warns...)
+	allErrs = append(allErrs, errs...)
+
+	return allWarns, allErrs
+}
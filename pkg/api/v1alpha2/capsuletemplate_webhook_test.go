@@ -0,0 +1,62 @@
+package v1alpha2
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func Test_CapsuleTemplateSpecValidate(t *testing.T) {
+	t.Parallel()
+	infsPath := field.NewPath("spec").Child("interfaces")
+	tests := []struct {
+		name         string
+		spec         CapsuleTemplateSpec
+		expectedErrs field.ErrorList
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "good",
+			spec: CapsuleTemplateSpec{
+				Command:    "./run",
+				Interfaces: []CapsuleInterface{{Name: "http", Port: 8080}},
+				Monitoring: &Monitoring{Path: "/metrics"},
+			},
+		},
+		{
+			name: "invalid interfaces",
+			spec: CapsuleTemplateSpec{
+				Interfaces: []CapsuleInterface{
+					{Name: "http", Port: 8080},
+					{Name: "http", Port: 8080},
+				},
+			},
+			expectedErrs: field.ErrorList{
+				field.Duplicate(infsPath.Index(1).Child("name"), "http"),
+				field.Duplicate(infsPath.Index(1).Child("port"), int32(8080)),
+			},
+		},
+		{
+			name: "service monitor without interfaces",
+			spec: CapsuleTemplateSpec{
+				Monitoring: &Monitoring{Path: "/metrics"},
+			},
+			expectedErrs: field.ErrorList{
+				field.Invalid(field.NewPath("spec").Child("monitoring").Child("kind"), "",
+					"a ServiceMonitor requires interfaces, use a PodMonitor instead"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, errs := tt.spec.Validate()
+			assert.Equal(t, tt.expectedErrs, errs)
+		})
+	}
+}
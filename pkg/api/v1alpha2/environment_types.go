@@ -0,0 +1,170 @@
+package v1alpha2
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// EnvironmentSpec defines the namespace of an environment and how it is
+// configured.
+type EnvironmentSpec struct {
+	// Namespace is the name of the namespace created for the environment.
+	// Defaults to the name of the Environment. An existing namespace which
+	// is not owned by the Environment is not adopted.
+	Namespace string `json:"namespace,omitempty"`
+
+	// NamespaceDeletionPolicy specifies what happens to the namespace when
+	// the Environment is deleted. With Delete, the namespace is owned by the
+	// Environment and deleted with it, including all capsules in it. With
+	// Retain, the namespace is kept. Defaults to Delete.
+	//+kubebuilder:validation:Enum=Delete;Retain
+	NamespaceDeletionPolicy NamespaceDeletionPolicy `json:"namespaceDeletionPolicy,omitempty"`
+
+	// NamespaceLabels are added to the namespace.
+	NamespaceLabels map[string]string `json:"namespaceLabels,omitempty"`
+
+	// Quota is the hard limits of the ResourceQuota of the namespace. No
+	// ResourceQuota is created if omitted.
+	Quota v1.ResourceList `json:"quota,omitempty"`
+
+	// LimitRange holds the limits of the LimitRange of the namespace, e.g.
+	// the default requests and limits of containers. No LimitRange is
+	// created if omitted.
+	LimitRange []v1.LimitRangeItem `json:"limitRange,omitempty"`
+
+	// SharedConfig holds ConfigMaps which are created in the namespace as
+	// shared config, so their data is set as environment variables of all
+	// capsules in the namespace.
+	SharedConfig []SharedConfig `json:"sharedConfig,omitempty"`
+
+	// ImagePullSecrets are copied into the namespace and used by all
+	// capsules in the namespace to pull their images.
+	ImagePullSecrets []ImagePullSecret `json:"imagePullSecrets,omitempty"`
+}
+
+// NamespaceDeletionPolicy specifies what happens to the namespace of an
+// environment when the environment is deleted.
+type NamespaceDeletionPolicy string
+
+const (
+	// NamespaceDeletionPolicyDelete deletes the namespace and everything in
+	// it with the environment.
+	NamespaceDeletionPolicyDelete NamespaceDeletionPolicy = "Delete"
+	// NamespaceDeletionPolicyRetain keeps the namespace when the environment
+	// is deleted.
+	NamespaceDeletionPolicyRetain NamespaceDeletionPolicy = "Retain"
+)
+
+// SharedConfig is a ConfigMap shared by all capsules of an environment.
+type SharedConfig struct {
+	// Name is the name of the ConfigMap.
+	Name string `json:"name"`
+
+	// Data is the data of the ConfigMap.
+	Data map[string]string `json:"data,omitempty"`
+}
+
+// ImagePullSecret is an image pull secret copied into the namespace of an
+// environment.
+type ImagePullSecret struct {
+	// Name is the name of the secret in the namespace of the environment.
+	Name string `json:"name"`
+
+	// From is the secret to copy. It must be of type
+	// kubernetes.io/dockerconfigjson.
+	From SecretReference `json:"from"`
+}
+
+// SecretReference references a secret in any namespace.
+type SecretReference struct {
+	// Namespace is the namespace of the secret.
+	Namespace string `json:"namespace"`
+
+	// Name is the name of the secret.
+	Name string `json:"name"`
+}
+
+// EnvironmentStatus is the status of an environment.
+type EnvironmentStatus struct {
+	// ObservedGeneration is the generation of the Environment which was last
+	// reconciled without errors.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Namespace is the name of the namespace of the environment.
+	Namespace string `json:"namespace,omitempty"`
+
+	// State is the state of the environment, either ready or failed.
+	//+kubebuilder:validation:Enum=ready;failed
+	State string `json:"state,omitempty"`
+
+	// Message explains why the environment has failed.
+	Message string `json:"message,omitempty"`
+
+	// OwnedResources are the resources created for the environment.
+	OwnedResources []OwnedResource `json:"ownedResources,omitempty"`
+
+	// Usage is the aggregate usage of the capsules in the namespace.
+	Usage EnvironmentUsage `json:"usage,omitempty"`
+}
+
+// EnvironmentUsage is the aggregate usage of the capsules of an environment.
+type EnvironmentUsage struct {
+	// Capsules is the number of capsules in the namespace.
+	Capsules int32 `json:"capsules"`
+
+	// Instances is the total number of instances of the capsules.
+	Instances int32 `json:"instances"`
+
+	// Requests is the total resource requests of all instances.
+	Requests v1.ResourceList `json:"requests,omitempty"`
+
+	// Limits is the total resource limits of all instances.
+	Limits v1.ResourceList `json:"limits,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Namespace",type=string,JSONPath=`.status.namespace`
+//+kubebuilder:printcolumn:name="Capsules",type=integer,JSONPath=`.status.usage.capsules`
+//+kubebuilder:printcolumn:name="Instances",type=integer,JSONPath=`.status.usage.instances`
+//+kubebuilder:printcolumn:name="State",type=string,JSONPath=`.status.state`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// Environment is the Schema for the environments API. An Environment
+// provisions a namespace for capsules, with quotas, limits, shared config
+// and image pull secrets. Unless the namespace deletion policy is Retain,
+// the namespace is owned by the Environment, so deleting the Environment
+// deletes the namespace and all of its capsules.
+type Environment struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec holds the specification of the Environment.
+	Spec EnvironmentSpec `json:"spec,omitempty"`
+
+	// Status holds the status of the Environment.
+	Status *EnvironmentStatus `json:"status,omitempty"`
+}
+
+// NamespaceName returns the name of the namespace of the environment.
+func (e *Environment) NamespaceName() string {
+	if e.Spec.Namespace != "" {
+		return e.Spec.Namespace
+	}
+	return e.Name
+}
+
+//+kubebuilder:object:root=true
+
+// EnvironmentList contains a list of Environment
+type EnvironmentList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []Environment `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Environment{}, &EnvironmentList{})
+}
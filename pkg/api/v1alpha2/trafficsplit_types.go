@@ -0,0 +1,134 @@
+package v1alpha2
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TrafficSplitSpec defines how the traffic of a host is split between
+// interfaces of capsules.
+type TrafficSplitSpec struct {
+	// Host is the hostname whose traffic is split. The interfaces should
+	// not also be published on the host by their capsules, which is reported
+	// in the warnings of the status.
+	Host string `json:"host"`
+
+	// Backends are the capsule interfaces receiving the traffic of the host,
+	// in the namespace of the TrafficSplit. The weights of the backends must
+	// add up to 100.
+	//+kubebuilder:validation:MinItems=2
+	Backends []TrafficSplitBackend `json:"backends"`
+
+	// Provider specifies how the split is rendered. Nginx renders an Ingress
+	// for the first backend and an ingress-nginx canary Ingress for the
+	// second, and supports exactly two backends. Gateway renders a Gateway
+	// API HTTPRoute with the weights of the backends. Defaults to Nginx.
+	//+kubebuilder:validation:Enum=Nginx;Gateway
+	Provider TrafficSplitProvider `json:"provider,omitempty"`
+
+	// Gateway is the Gateway which the HTTPRoute is attached to. Required
+	// for the Gateway provider.
+	Gateway *GatewayReference `json:"gateway,omitempty"`
+}
+
+// TrafficSplitProvider is the provider rendering a traffic split.
+type TrafficSplitProvider string
+
+const (
+	TrafficSplitProviderNginx   TrafficSplitProvider = "Nginx"
+	TrafficSplitProviderGateway TrafficSplitProvider = "Gateway"
+)
+
+// TrafficSplitBackend is a capsule interface receiving a share of the
+// traffic of a TrafficSplit.
+type TrafficSplitBackend struct {
+	// Capsule is the name of the capsule.
+	Capsule string `json:"capsule"`
+
+	// Interface is the name of the interface of the capsule.
+	Interface string `json:"interface"`
+
+	// Weight is the percentage of the traffic sent to the interface.
+	//+kubebuilder:validation:Minimum=0
+	//+kubebuilder:validation:Maximum=100
+	Weight int32 `json:"weight"`
+}
+
+// GatewayReference references a Gateway API Gateway.
+type GatewayReference struct {
+	// Name is the name of the Gateway.
+	Name string `json:"name"`
+
+	// Namespace is the namespace of the Gateway. Defaults to the namespace
+	// of the TrafficSplit.
+	Namespace string `json:"namespace,omitempty"`
+
+	// SectionName is the name of the listener of the Gateway to attach to.
+	// Defaults to all listeners.
+	SectionName string `json:"sectionName,omitempty"`
+}
+
+// TrafficSplitStatus is the status of a traffic split.
+type TrafficSplitStatus struct {
+	// ObservedGeneration is the generation of the TrafficSplit which was
+	// last reconciled without errors.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// State is the state of the traffic split, either ready or failed.
+	//+kubebuilder:validation:Enum=ready;failed
+	State string `json:"state,omitempty"`
+
+	// Message explains why the traffic split has failed.
+	Message string `json:"message,omitempty"`
+
+	// Warnings are problems which don't fail the traffic split, but may
+	// keep it from splitting the traffic as specified, e.g. an Ingress of a
+	// capsule publishing the same host.
+	Warnings []string `json:"warnings,omitempty"`
+
+	// OwnedResources are the resources created for the traffic split.
+	OwnedResources []OwnedResource `json:"ownedResources,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Host",type=string,JSONPath=`.spec.host`
+//+kubebuilder:printcolumn:name="Provider",type=string,JSONPath=`.spec.provider`
+//+kubebuilder:printcolumn:name="State",type=string,JSONPath=`.status.state`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// TrafficSplit is the Schema for the trafficsplits API. A TrafficSplit
+// sends weighted shares of the traffic of a host to interfaces of capsules,
+// e.g. to migrate traffic to a new capsule.
+type TrafficSplit struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec holds the specification of the TrafficSplit.
+	Spec TrafficSplitSpec `json:"spec,omitempty"`
+
+	// Status holds the status of the TrafficSplit.
+	Status *TrafficSplitStatus `json:"status,omitempty"`
+}
+
+// GetProvider returns the provider of the traffic split, defaulting to
+// Nginx.
+func (t *TrafficSplit) GetProvider() TrafficSplitProvider {
+	if t.Spec.Provider == "" {
+		return TrafficSplitProviderNginx
+	}
+	return t.Spec.Provider
+}
+
+//+kubebuilder:object:root=true
+
+// TrafficSplitList contains a list of TrafficSplit
+type TrafficSplitList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []TrafficSplit `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&TrafficSplit{}, &TrafficSplitList{})
+}
@@ -0,0 +1,121 @@
+package v1alpha2
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// SetupWebhookWithManager registers the validating webhook of the traffic
+// split.
+func (t *TrafficSplit) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(t).
+		WithValidator(&trafficSplitValidator{}).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-rig-dev-v1alpha2-trafficsplit,mutating=false,failurePolicy=fail,sideEffects=None,groups=rig.dev,resources=trafficsplits,verbs=create;update,versions=v1alpha2,name=vtrafficsplit.kb.io,admissionReviewVersions=v1
+
+type trafficSplitValidator struct{}
+
+var _ webhook.CustomValidator = &trafficSplitValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type
+func (v *trafficSplitValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	t, ok := obj.(*TrafficSplit)
+	if !ok {
+		return nil, fmt.Errorf("expected a TrafficSplit but got a %T", obj)
+	}
+	return nil, t.Validate().ToAggregate()
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type
+func (v *trafficSplitValidator) ValidateUpdate(
+	_ context.Context,
+	_ runtime.Object,
+	newObj runtime.Object,
+) (admission.Warnings, error) {
+	t, ok := newObj.(*TrafficSplit)
+	if !ok {
+		return nil, fmt.Errorf("expected a TrafficSplit but got a %T", newObj)
+	}
+	return nil, t.Validate().ToAggregate()
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type
+func (v *trafficSplitValidator) ValidateDelete(context.Context, runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// Validate validates the traffic split. The capsules and interfaces of the
+// backends are not required to exist.
+func (t *TrafficSplit) Validate() field.ErrorList {
+	var errs field.ErrorList
+	sPath := field.NewPath("spec")
+
+	if t.Spec.Host == "" {
+		errs = append(errs, field.Required(sPath.Child("host"), ""))
+	} else {
+		for _, msg := range validation.IsDNS1123Subdomain(t.Spec.Host) {
+			errs = append(errs, field.Invalid(sPath.Child("host"), t.Spec.Host, msg))
+		}
+	}
+
+	bPath := sPath.Child("backends")
+	var total int32
+	backends := map[TrafficSplitBackend]struct{}{}
+	for i, b := range t.Spec.Backends {
+		iPath := bPath.Index(i)
+		if b.Capsule == "" {
+			errs = append(errs, field.Required(iPath.Child("capsule"), ""))
+		}
+		if b.Interface == "" {
+			errs = append(errs, field.Required(iPath.Child("interface"), ""))
+		}
+		if b.Weight < 0 || b.Weight > 100 {
+			errs = append(errs, field.Invalid(iPath.Child("weight"), b.Weight, "must be between 0 and 100"))
+		}
+		total += b.Weight
+
+		key := TrafficSplitBackend{Capsule: b.Capsule, Interface: b.Interface}
+		if _, ok := backends[key]; ok {
+			errs = append(errs, field.Duplicate(iPath, fmt.Sprintf("%s/%s", b.Capsule, b.Interface)))
+		}
+		backends[key] = struct{}{}
+	}
+	if len(t.Spec.Backends) < 2 {
+		errs = append(errs, field.Required(bPath, "at least two backends are required"))
+	}
+	if total != 100 {
+		errs = append(errs, field.Invalid(bPath, total, "the weights of the backends must add up to 100"))
+	}
+
+	switch t.GetProvider() {
+	case TrafficSplitProviderNginx:
+		if len(t.Spec.Backends) > 2 {
+			errs = append(errs, field.TooMany(bPath, len(t.Spec.Backends), 2))
+		}
+		if t.Spec.Gateway != nil {
+			errs = append(errs, field.Forbidden(sPath.Child("gateway"), "only supported by the Gateway provider"))
+		}
+	case TrafficSplitProviderGateway:
+		if t.Spec.Gateway == nil {
+			errs = append(errs, field.Required(sPath.Child("gateway"), "required by the Gateway provider"))
+		} else if t.Spec.Gateway.Name == "" {
+			errs = append(errs, field.Required(sPath.Child("gateway").Child("name"), ""))
+		}
+	default:
+		errs = append(errs, field.NotSupported(sPath.Child("provider"), t.Spec.Provider, []string{
+			string(TrafficSplitProviderNginx), string(TrafficSplitProviderGateway),
+		}))
+	}
+
+	return errs
+}
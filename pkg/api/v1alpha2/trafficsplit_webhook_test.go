@@ -0,0 +1,117 @@
+package v1alpha2
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func Test_TrafficSplitValidate(t *testing.T) {
+	t.Parallel()
+	path := field.NewPath("spec")
+	bPath := path.Child("backends")
+	tests := []struct {
+		name         string
+		spec         TrafficSplitSpec
+		expectedErrs field.ErrorList
+	}{
+		{
+			name: "nginx",
+			spec: TrafficSplitSpec{
+				Host: "api.example.com",
+				Backends: []TrafficSplitBackend{
+					{Capsule: "api", Interface: "http", Weight: 90},
+					{Capsule: "api-v2", Interface: "http", Weight: 10},
+				},
+			},
+		},
+		{
+			name: "gateway",
+			spec: TrafficSplitSpec{
+				Host:     "api.example.com",
+				Provider: TrafficSplitProviderGateway,
+				Gateway:  &GatewayReference{Name: "public"},
+				Backends: []TrafficSplitBackend{
+					{Capsule: "api", Interface: "http", Weight: 50},
+					{Capsule: "api-v2", Interface: "http", Weight: 25},
+					{Capsule: "api-v3", Interface: "http", Weight: 25},
+				},
+			},
+		},
+		{
+			name: "weights must add up to 100",
+			spec: TrafficSplitSpec{
+				Host: "api.example.com",
+				Backends: []TrafficSplitBackend{
+					{Capsule: "api", Interface: "http", Weight: 90},
+					{Capsule: "api-v2", Interface: "http", Weight: 20},
+				},
+			},
+			expectedErrs: field.ErrorList{
+				field.Invalid(bPath, int32(110), "the weights of the backends must add up to 100"),
+			},
+		},
+		{
+			name: "invalid backends",
+			spec: TrafficSplitSpec{
+				Host: "API",
+				Backends: []TrafficSplitBackend{
+					{Capsule: "api", Weight: 50},
+					{Capsule: "api", Weight: 50},
+				},
+			},
+			expectedErrs: field.ErrorList{
+				field.Invalid(path.Child("host"), "API", ""),
+				field.Required(bPath.Index(0).Child("interface"), ""),
+				field.Required(bPath.Index(1).Child("interface"), ""),
+				field.Duplicate(bPath.Index(1), "api/"),
+			},
+		},
+		{
+			name: "nginx supports two backends",
+			spec: TrafficSplitSpec{
+				Host:    "api.example.com",
+				Gateway: &GatewayReference{Name: "public"},
+				Backends: []TrafficSplitBackend{
+					{Capsule: "api", Interface: "http", Weight: 50},
+					{Capsule: "api-v2", Interface: "http", Weight: 25},
+					{Capsule: "api-v3", Interface: "http", Weight: 25},
+				},
+			},
+			expectedErrs: field.ErrorList{
+				field.TooMany(bPath, 3, 2),
+				field.Forbidden(path.Child("gateway"), "only supported by the Gateway provider"),
+			},
+		},
+		{
+			name: "gateway requires a gateway",
+			spec: TrafficSplitSpec{
+				Host:     "api.example.com",
+				Provider: TrafficSplitProviderGateway,
+				Backends: []TrafficSplitBackend{
+					{Capsule: "api", Interface: "http", Weight: 100},
+					{Capsule: "api-v2", Interface: "http"},
+				},
+			},
+			expectedErrs: field.ErrorList{
+				field.Required(path.Child("gateway"), "required by the Gateway provider"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			split := &TrafficSplit{Spec: tt.spec}
+			errs := split.Validate()
+			assert.Len(t, errs, len(tt.expectedErrs))
+			for i := range errs {
+				if i >= len(tt.expectedErrs) {
+					break
+				}
+				assert.Equal(t, tt.expectedErrs[i].Type, errs[i].Type)
+				assert.Equal(t, tt.expectedErrs[i].Field, errs[i].Field)
+			}
+		})
+	}
+}
@@ -19,26 +19,30 @@ package controller
 import (
 	"context"
 	"crypto/sha256"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"path"
+	"reflect"
 	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	cmv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
-	cmmetav1 "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
 	"github.com/go-logr/logr"
 	monitorv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
-	configv1alpha1 "github.com/rigdev/rig/pkg/api/config/v1alpha1"
 	"github.com/rigdev/rig/pkg/api/v1alpha2"
 	"github.com/rigdev/rig/pkg/hash"
 	"github.com/rigdev/rig/pkg/ptr"
+	"github.com/rigdev/rig/pkg/service/config"
 	"github.com/rigdev/rig/pkg/utils"
 	"golang.org/x/exp/maps"
 	appsv1 "k8s.io/api/apps/v1"
 	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	v1 "k8s.io/api/core/v1"
 	netv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -48,22 +52,33 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 // CapsuleReconciler reconciles a Capsule object
 type CapsuleReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
-	Config *configv1alpha1.OperatorConfig
+	Scheme   *runtime.Scheme
+	Config   config.Service
+	Recorder record.EventRecorder
 
-	reconcileSteps []reconcileStepFunc
+	reconcileSteps []reconcileStep
+}
+
+// reconcileStep is a named step of a capsule reconciliation. The name is used
+// to label the reconciliation metrics.
+type reconcileStep struct {
+	name      string
+	reconcile reconcileStepFunc
 }
 
 type reconcileStepFunc func(
@@ -75,10 +90,21 @@ type reconcileStepFunc func(
 ) error
 
 const (
-	AnnotationChecksumFiles     = "rig.dev/config-checksum-files"
-	AnnotationChecksumAutoEnv   = "rig.dev/config-checksum-auto-env"
-	AnnotationChecksumEnv       = "rig.dev/config-checksum-env"
-	AnnotationChecksumSharedEnv = "rig.dev/config-checksum-shared-env"
+	AnnotationChecksumFiles       = "rig.dev/config-checksum-files"
+	AnnotationChecksumAutoEnv     = "rig.dev/config-checksum-auto-env"
+	AnnotationChecksumEnv         = "rig.dev/config-checksum-env"
+	AnnotationChecksumSharedEnv   = "rig.dev/config-checksum-shared-env"
+	AnnotationChecksumConnections = "rig.dev/config-checksum-connections"
+
+	AnnotationPodTemplateHash    = "rig.dev/pod-template-hash"
+	AnnotationRolledBackTemplate = "rig.dev/rolled-back-template"
+	AnnotationDeploymentRevision = "deployment.kubernetes.io/revision"
+
+	// AnnotationManagedAnnotations lists the keys of the annotations set by
+	// the operator on a resource, separated by commas. Listed annotations
+	// which are no longer desired are removed, and other annotations are
+	// left to whoever set them.
+	AnnotationManagedAnnotations = "rig.dev/managed-annotations"
 
 	LabelSharedConfig = "rig.dev/shared-config"
 	LabelCapsule      = "rig.dev/capsule"
@@ -169,27 +195,88 @@ func (r *CapsuleReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		return fmt.Errorf("could not setup indexer for %s: %w", fieldEnvSecretName, err)
 	}
 
-	r.reconcileSteps = []reconcileStepFunc{
-		r.reconcileHorizontalPodAutoscaler,
-		r.reconcileDeployment,
-		r.reconcileService,
-		r.reconcileCertificate,
-		r.reconcileIngress,
-		r.reconcileLoadBalancer,
-		r.reconcileServiceAccount,
-		r.reconcilePrometheusServiceMonitor,
+	if err := mgr.GetFieldIndexer().IndexField(
+		context.Background(),
+		&v1alpha2.Capsule{},
+		fieldDependsOnCapsule,
+		indexDependsOnCapsule,
+	); err != nil {
+		return fmt.Errorf("could not setup indexer for %s: %w", fieldDependsOnCapsule, err)
+	}
+
+	if err := mgr.GetFieldIndexer().IndexField(
+		context.Background(),
+		&v1alpha2.Capsule{},
+		fieldConnectionsCapsule,
+		indexConnectionsCapsule,
+	); err != nil {
+		return fmt.Errorf("could not setup indexer for %s: %w", fieldConnectionsCapsule, err)
+	}
+
+	if err := mgr.GetFieldIndexer().IndexField(
+		context.Background(),
+		&v1alpha2.Capsule{},
+		fieldTemplateName,
+		indexTemplateName,
+	); err != nil {
+		return fmt.Errorf("could not setup indexer for %s: %w", fieldTemplateName, err)
+	}
+
+	if err := mgr.GetFieldIndexer().IndexField(
+		context.Background(),
+		&v1alpha2.Capsule{},
+		fieldClusterTemplateName,
+		indexClusterTemplateName,
+	); err != nil {
+		return fmt.Errorf("could not setup indexer for %s: %w", fieldClusterTemplateName, err)
+	}
+
+	r.reconcileSteps = []reconcileStep{
+		{"dependencies", r.reconcileDependencies},
+		{"generated_secrets", r.reconcileGeneratedSecrets},
+		{"horizontal_pod_autoscaler", r.reconcileHorizontalPodAutoscaler},
+		{"deployment", r.reconcileDeployment},
+		{"auto_rollback", r.reconcileAutoRollback},
+		{"pod_disruption_budget", r.reconcilePodDisruptionBudget},
+		{"service", r.reconcileService},
+		{"certificate", r.reconcileCertificate},
+		{"traefik_middlewares", r.reconcileTraefikMiddlewares},
+		{"ingress", r.reconcileIngress},
+		{"load_balancer", r.reconcileLoadBalancer},
+		{"service_account", r.reconcileServiceAccount},
+		{"prometheus_service_monitor", r.reconcilePrometheusServiceMonitor},
+		{"prometheus_pod_monitor", r.reconcilePrometheusPodMonitor},
+		{"prometheus_rule", r.reconcilePrometheusRule},
 	}
 
 	configEventHandler := handler.EnqueueRequestsFromMapFunc(findCapsulesForConfig(mgr))
 
+	r.Config.OnChange(logRestartRequired(mgr.GetLogger().WithName("config")))
+	requeuer := newConfigRequeuer(mgr.GetClient(), mgr.GetLogger(), func() client.ObjectList {
+		return &v1alpha2.CapsuleList{}
+	})
+	r.Config.OnChange(requeuer.onConfigChange)
+	if err := mgr.Add(requeuer); err != nil {
+		return fmt.Errorf("could not add config requeuer: %w", err)
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&v1alpha2.Capsule{}).
 		Owns(&appsv1.Deployment{}).
 		Owns(&v1.Service{}).
+		Owns(&v1.Secret{}).
 		Owns(&netv1.Ingress{}).
 		Owns(&autoscalingv2.HorizontalPodAutoscaler{}).
-		Owns(&cmv1.Certificate{}).
+		Owns(&policyv1.PodDisruptionBudget{}).
 		Owns(&monitorv1.ServiceMonitor{}).
+		Owns(&monitorv1.PodMonitor{}).
+		Owns(&monitorv1.PrometheusRule{}).
+		// Certificates enqueue all their owners, as wildcard certificates are
+		// shared by the capsules of the namespace without a controller.
+		Watches(
+			&cmv1.Certificate{},
+			handler.EnqueueRequestForOwner(mgr.GetScheme(), mgr.GetRESTMapper(), &v1alpha2.Capsule{}),
+		).
 		Watches(
 			&v1.ConfigMap{},
 			configEventHandler,
@@ -200,9 +287,79 @@ func (r *CapsuleReconciler) SetupWithManager(mgr ctrl.Manager) error {
 			configEventHandler,
 			builder.WithPredicates(predicate.ResourceVersionChangedPredicate{}),
 		).
+		WatchesRawSource(
+			&source.Channel{Source: requeuer.events},
+			&handler.EnqueueRequestForObject{},
+		).
+		Watches(
+			&appsv1.Deployment{},
+			handler.EnqueueRequestsFromMapFunc(findCapsulesReferencing(mgr, fieldDependsOnCapsule)),
+		).
+		Watches(
+			&v1.Service{},
+			handler.EnqueueRequestsFromMapFunc(findCapsulesReferencing(mgr, fieldConnectionsCapsule)),
+		).
+		Watches(
+			&v1alpha2.CapsuleTemplate{},
+			handler.EnqueueRequestsFromMapFunc(findCapsulesReferencing(mgr, fieldTemplateName)),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}),
+		).
+		Watches(
+			&v1alpha2.ClusterCapsuleTemplate{},
+			handler.EnqueueRequestsFromMapFunc(findCapsulesReferencing(mgr, fieldClusterTemplateName)),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}),
+		).
+		Watches(
+			&v1.Pod{},
+			handler.EnqueueRequestsFromMapFunc(findCapsuleForPod),
+			builder.WithPredicates(podRestartedPredicate),
+		).
 		Complete(r)
 }
 
+func findCapsuleForPod(_ context.Context, o client.Object) []ctrl.Request {
+	name, ok := o.GetLabels()[LabelCapsule]
+	if !ok {
+		return nil
+	}
+	return []ctrl.Request{{
+		NamespacedName: types.NamespacedName{
+			Namespace: o.GetNamespace(),
+			Name:      name,
+		},
+	}}
+}
+
+// podRestartedPredicate only lets through updates of capsule pods where a
+// container has restarted. These are needed to detect failing rollouts.
+var podRestartedPredicate = predicate.Funcs{
+	CreateFunc:  func(event.CreateEvent) bool { return false },
+	DeleteFunc:  func(event.DeleteEvent) bool { return false },
+	GenericFunc: func(event.GenericEvent) bool { return false },
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		if _, ok := e.ObjectNew.GetLabels()[LabelCapsule]; !ok {
+			return false
+		}
+		oldPod, ok := e.ObjectOld.(*v1.Pod)
+		if !ok {
+			return false
+		}
+		newPod, ok := e.ObjectNew.(*v1.Pod)
+		if !ok {
+			return false
+		}
+		return podRestarts(newPod) > podRestarts(oldPod)
+	},
+}
+
+func podRestarts(pod *v1.Pod) int32 {
+	var restarts int32
+	for _, cs := range pod.Status.ContainerStatuses {
+		restarts += cs.RestartCount
+	}
+	return restarts
+}
+
 func findCapsulesForConfig(mgr ctrl.Manager) handler.MapFunc {
 	scheme := mgr.GetScheme()
 	log := mgr.GetLogger().WithName("configEventHandler")
@@ -211,7 +368,8 @@ func findCapsulesForConfig(mgr ctrl.Manager) handler.MapFunc {
 	return func(ctx context.Context, o client.Object) []ctrl.Request {
 		var capsulesWithReference v1alpha2.CapsuleList
 		// Queue reconcile for all capsules in namespace if this is a shared config
-		if sharedConfig := o.GetLabels()[LabelSharedConfig]; sharedConfig == "true" {
+		// or an image pull secret
+		if o.GetLabels()[LabelSharedConfig] == "true" || o.GetLabels()[LabelImagePullSecret] == "true" {
 			if err := c.List(ctx, &capsulesWithReference, client.InNamespace(o.GetNamespace())); err != nil {
 				log.Error(err, "could not get capsules")
 			}
@@ -299,12 +457,20 @@ func findCapsulesForConfig(mgr ctrl.Manager) handler.MapFunc {
 //+kubebuilder:rbac:groups=rig.dev,resources=capsules,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=rig.dev,resources=capsules/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=rig.dev,resources=capsules/finalizers,verbs=update
+//+kubebuilder:rbac:groups=rig.dev,resources=capsulepolicies,verbs=get;list;watch
+//+kubebuilder:rbac:groups=rig.dev,resources=capsuletemplates;clustercapsuletemplates,verbs=get;list;watch
 //+kubebuilder:rbac:groups="apps",resources=deployments,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups="",resources=services;serviceaccounts,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=cert-manager.io,resources=certificates,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=autoscaling,resources=horizontalpodautoscalers,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=monitoring.coreos.com,resources=servicemonitors;podmonitors;prometheusrules,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups="",resources=configmaps;secrets,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+//+kubebuilder:rbac:groups="apps",resources=replicasets,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile compares the state specified by the Capsule object against the
 // actual cluster state, and then performs operations to make the cluster state
@@ -317,6 +483,7 @@ func (r *CapsuleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	capsule := &v1alpha2.Capsule{}
 	if err := r.Get(ctx, req.NamespacedName, capsule); err != nil {
 		if kerrors.IsNotFound(err) {
+			deleteCapsuleMetrics(req.Namespace, req.Name)
 			return ctrl.Result{}, nil
 		}
 		return ctrl.Result{}, fmt.Errorf("could not fetch Capsule: %w", err)
@@ -325,13 +492,38 @@ func (r *CapsuleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	status := &v1alpha2.CapsuleStatus{
 		Deployment: &v1alpha2.DeploymentStatus{},
 	}
+	// A rollback is kept until the capsule is changed again.
+	if capsule.Status != nil && capsule.Status.Rollback != nil &&
+		capsule.Status.Rollback.Generation == capsule.GetGeneration() {
+		status.Rollback = capsule.Status.Rollback
+	}
+	// The expiration is updated by the cleanup controller.
+	if capsule.Status != nil && capsule.Spec.TTL != nil {
+		status.Expiration = capsule.Status.Expiration
+	}
+
+	merged, err := r.applyTemplate(ctx, capsule, status)
+	if err != nil {
+		// The capsule is not reconciled without its template.
+		observeCapsuleStatus(capsule, status)
+		capsule.Status = status
+		return ctrl.Result{}, errors.Join(err, r.Status().Update(ctx, capsule))
+	}
+	capsule = merged
+
 	var stepErrs []error
-	for _, sf := range r.reconcileSteps {
-		if err := sf(ctx, req, log, capsule, status); err != nil {
+	for _, step := range r.reconcileSteps {
+		start := time.Now()
+		err := step.reconcile(ctx, req, log, capsule, status)
+		reconcileStepDuration.WithLabelValues(step.name).Observe(time.Since(start).Seconds())
+		if err != nil {
+			reconcileStepErrors.WithLabelValues(step.name).Inc()
 			stepErrs = append(stepErrs, err)
 		}
 	}
 
+	observeCapsuleStatus(capsule, status)
+
 	if len(stepErrs) == 0 {
 		status.ObservedGeneration = capsule.GetGeneration()
 	}
@@ -349,6 +541,7 @@ type configs struct {
 	secrets             map[string]*v1.Secret
 	sharedEnvConfigMaps []string
 	sharedEnvSecrets    []string
+	connectionEnv       []v1.EnvVar
 }
 
 func (c *configs) hasSharedConfig() bool {
@@ -356,10 +549,11 @@ func (c *configs) hasSharedConfig() bool {
 }
 
 type checksums struct {
-	sharedEnv string
-	autoEnv   string
-	env       string
-	files     string
+	sharedEnv   string
+	autoEnv     string
+	env         string
+	files       string
+	connections string
 }
 
 func (r *CapsuleReconciler) configChecksums(
@@ -390,10 +584,11 @@ func (r *CapsuleReconciler) configChecksums(
 	}
 
 	return &checksums{
-		sharedEnv: sharedEnv,
-		autoEnv:   autoEnv,
-		env:       env,
-		files:     files,
+		sharedEnv:   sharedEnv,
+		autoEnv:     autoEnv,
+		env:         env,
+		files:       files,
+		connections: connectionsChecksum(configs.connectionEnv),
 	}, nil
 }
 
@@ -622,14 +817,22 @@ func (r *CapsuleReconciler) getUsedSource(
 		},
 	}
 
+	// Optional sources, like those of the automatic env, are expected to be
+	// missing, so no event is recorded for them.
 	defer func() {
-		if kerrors.IsNotFound(err) && !required {
+		switch {
+		case kerrors.IsNotFound(err) && !required:
 			ref.State = "missing"
 			err = nil
-		} else if err != nil {
+		case kerrors.IsNotFound(err):
 			ref.State = "error"
 			ref.Message = err.Error()
-		} else {
+			r.Recorder.Eventf(capsule, v1.EventTypeWarning, EventReasonMissingConfig, "%s %s not found", kind, name)
+		case err != nil:
+			ref.State = "error"
+			ref.Message = err.Error()
+			r.Recorder.Eventf(capsule, v1.EventTypeWarning, EventReasonFailed, "Could not get %s %s: %v", kind, name, err)
+		default:
 			ref.State = "found"
 		}
 
@@ -678,6 +881,11 @@ func (r *CapsuleReconciler) reconcileDeployment(
 		return err
 	}
 
+	cfgs.connectionEnv, err = r.getConnectionEnv(ctx, capsule, status)
+	if err != nil {
+		return err
+	}
+
 	checksums, err := r.configChecksums(capsule, cfgs)
 	if err != nil {
 		return err
@@ -695,14 +903,58 @@ func (r *CapsuleReconciler) reconcileDeployment(
 		}
 	}
 
-	deploy, err := createDeployment(capsule, r.Scheme, cfgs, checksums, existingDeploy)
+	deploy, err := r.createDeployment(capsule, r.Scheme, cfgs, checksums, existingDeploy)
+	if err != nil {
+		return err
+	}
+	if err := r.applyOverrides(capsule, "Deployment", deploy); err != nil {
+		status.Deployment.State = "failed"
+		status.Deployment.Message = err.Error()
+		return err
+	}
+
+	// The hash identifies the desired pod template, which changes with the
+	// capsule and everything else the pod template is rendered from.
+	templateHash, err := podTemplateHash(&deploy.Spec.Template)
 	if err != nil {
 		return err
 	}
 
+	annotations := deploy.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	// Keep the pod template of a rolled back deployment until the desired
+	// pod template changes.
+	if hasExistingDeployment && isRolledBack(existingDeploy, templateHash) {
+		deploy.Spec.Template = *existingDeploy.Spec.Template.DeepCopy()
+		annotations[AnnotationRolledBackTemplate] = templateHash
+	}
+	annotations[AnnotationPodTemplateHash] = templateHash
+	deploy.SetAnnotations(annotations)
+
+	switch {
+	// Only a new Deployment, or one which is already held, is held for its
+	// dependencies. An autoscaler does not scale a Deployment with zero
+	// replicas, so this also holds autoscaled capsules.
+	case awaitingDependencies(status) && (!hasExistingDeployment || isAwaitingDependencies(existingDeploy)):
+		deploy.Spec.Replicas = ptr.New(int32(0))
+		annotations := deploy.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[AnnotationAwaitingDependencies] = "true"
+		deploy.SetAnnotations(annotations)
+	case hasExistingDeployment && isAwaitingDependencies(existingDeploy):
+		// The dependencies are ready, so start the minimum number of
+		// instances, also if autoscaled.
+		log.Info("dependencies are ready, starting deployment")
+		deploy.Spec.Replicas = ptr.New(int32(capsule.Spec.Scale.Horizontal.Instances.Min))
+	}
+
 	if !hasExistingDeployment {
 		log.Info("creating deployment")
-		if err := r.Create(ctx, deploy); err != nil {
+		if err := r.createOwned(ctx, capsule, deploy); err != nil {
 			status.Deployment.State = "failed"
 			status.Deployment.Message = err.Error()
 			return fmt.Errorf("could not create deployment: %w", err)
@@ -720,16 +972,230 @@ func (r *CapsuleReconciler) reconcileDeployment(
 	delete(existingDeploy.Spec.Template.Annotations, "kubectl.kubernetes.io/restartedAt")
 
 	err = upsertIfNewer(ctx, r, existingDeploy, deploy, log, capsule, status, func(t1, t2 *appsv1.Deployment) bool {
-		return equality.Semantic.DeepEqual(t1.Spec, t2.Spec)
+		return t1.GetAnnotations()[AnnotationPodTemplateHash] == t2.GetAnnotations()[AnnotationPodTemplateHash] &&
+			equality.Semantic.DeepEqual(t1.Spec, t2.Spec)
 	})
 	if err != nil {
 		status.Deployment.State = "failed"
 		status.Deployment.Message = err.Error()
+		return err
+	}
+
+	// existingDeploy holds the updated Deployment, so a rollout started by
+	// this reconciliation is reported as progressing.
+	status.Deployment.State, status.Deployment.Message = deploymentRolloutState(existingDeploy)
+	return nil
+}
+
+// Reasons of the Progressing condition of a Deployment, as set by the
+// deployment controller.
+const (
+	deploymentReasonProgressDeadlineExceeded = "ProgressDeadlineExceeded"
+	deploymentReasonNewReplicaSetAvailable   = "NewReplicaSetAvailable"
+)
+
+// deploymentRolloutState returns the state and message of the rollout of
+// the given deployment, based on its Progressing condition.
+func deploymentRolloutState(deploy *appsv1.Deployment) (string, string) {
+	if deploy.Status.ObservedGeneration < deploy.GetGeneration() {
+		return "progressing", ""
+	}
+
+	for _, c := range deploy.Status.Conditions {
+		if c.Type != appsv1.DeploymentProgressing {
+			continue
+		}
+
+		switch {
+		case c.Reason == deploymentReasonProgressDeadlineExceeded:
+			return "failed", "rollout stalled: progress deadline exceeded"
+		case c.Status == v1.ConditionTrue && c.Reason != deploymentReasonNewReplicaSetAvailable:
+			return "progressing", c.Message
+		}
+	}
+
+	return "created", ""
+}
+
+// defaultAutoRollbackMaxRestarts is the number of restarts allowed before a
+// rollout is rolled back, if not set on the capsule.
+const defaultAutoRollbackMaxRestarts = 3
+
+func (r *CapsuleReconciler) reconcileAutoRollback(
+	ctx context.Context,
+	req ctrl.Request,
+	log logr.Logger,
+	capsule *v1alpha2.Capsule,
+	status *v1alpha2.CapsuleStatus,
+) error {
+	if capsule.Spec.Rollout == nil || capsule.Spec.Rollout.AutoRollback == nil {
+		return nil
+	}
+
+	deploy := &appsv1.Deployment{}
+	if err := r.Get(ctx, req.NamespacedName, deploy); err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("could not fetch deployment: %w", err)
+	}
+	templateHash := deploy.GetAnnotations()[AnnotationPodTemplateHash]
+	if !IsOwnedBy(capsule, deploy) || templateHash == "" || isRolledBack(deploy, templateHash) {
+		return nil
+	}
+
+	// Only rollouts in progress are rolled back.
+	if state, _ := deploymentRolloutState(deploy); state == "created" {
+		return nil
+	}
+
+	current, previous, err := r.getRolloutReplicaSets(ctx, capsule, deploy)
+	if err != nil {
+		return err
+	}
+	if current == nil || previous == nil {
+		return nil
+	}
+
+	reason, err := r.rollbackReason(ctx, capsule, deploy, current)
+	if err != nil {
+		return err
+	}
+	if reason == "" {
+		return nil
+	}
+
+	revision := previous.GetAnnotations()[AnnotationDeploymentRevision]
+	log.Info("rolling back deployment", "reason", reason, "revision", revision)
+
+	template := previous.Spec.Template.DeepCopy()
+	delete(template.Labels, appsv1.DefaultDeploymentUniqueLabelKey)
+	deploy.Spec.Template = *template
+	if deploy.Annotations == nil {
+		deploy.Annotations = map[string]string{}
+	}
+	deploy.Annotations[AnnotationRolledBackTemplate] = templateHash
+	if err := r.Update(ctx, deploy); err != nil {
+		return fmt.Errorf("could not roll back deployment: %w", err)
+	}
+
+	status.Rollback = &v1alpha2.RollbackStatus{
+		Generation: capsule.GetGeneration(),
+		Revision:   revision,
+		Reason:     reason,
+		Time:       metav1.Now(),
+	}
+	status.Deployment.State = "progressing"
+	status.Deployment.Message = fmt.Sprintf("rolled back to revision %s: %s", revision, reason)
+	r.Recorder.Eventf(
+		capsule, v1.EventTypeWarning, EventReasonRolledBack, "Rolled back to revision %s: %s", revision, reason,
+	)
+
+	return nil
+}
+
+// isRolledBack returns true if the deployment has been rolled back from the
+// pod template with the given hash.
+func isRolledBack(deploy *appsv1.Deployment, templateHash string) bool {
+	return deploy.GetAnnotations()[AnnotationRolledBackTemplate] == templateHash
+}
+
+// podTemplateHash returns a hash of the pod template.
+func podTemplateHash(template *v1.PodTemplateSpec) (string, error) {
+	bs, err := json.Marshal(template)
+	if err != nil {
+		return "", fmt.Errorf("could not hash pod template: %w", err)
 	}
-	return err
+	return fmt.Sprintf("%x", sha256.Sum256(bs)), nil
 }
 
-func createDeployment(
+// getRolloutReplicaSets returns the replica set of the current revision of
+// the deployment and the replica set of the revision before it.
+func (r *CapsuleReconciler) getRolloutReplicaSets(
+	ctx context.Context,
+	capsule *v1alpha2.Capsule,
+	deploy *appsv1.Deployment,
+) (*appsv1.ReplicaSet, *appsv1.ReplicaSet, error) {
+	currentRevision, err := strconv.ParseInt(deploy.GetAnnotations()[AnnotationDeploymentRevision], 10, 64)
+	if err != nil {
+		return nil, nil, nil
+	}
+
+	var rsList appsv1.ReplicaSetList
+	if err := r.List(
+		ctx,
+		&rsList,
+		client.InNamespace(capsule.Namespace),
+		client.MatchingLabels{LabelCapsule: capsule.Name},
+	); err != nil {
+		return nil, nil, fmt.Errorf("could not list replica sets: %w", err)
+	}
+
+	var (
+		current, previous *appsv1.ReplicaSet
+		previousRevision  int64
+	)
+	for i := range rsList.Items {
+		rs := &rsList.Items[i]
+		if !IsOwnedBy(deploy, rs) {
+			continue
+		}
+		revision, err := strconv.ParseInt(rs.GetAnnotations()[AnnotationDeploymentRevision], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch {
+		case revision == currentRevision:
+			current = rs
+		case revision < currentRevision && revision > previousRevision:
+			previous = rs
+			previousRevision = revision
+		}
+	}
+
+	return current, previous, nil
+}
+
+// rollbackReason returns why the rollout of the current replica set should be
+// rolled back, or an empty string if it should not.
+func (r *CapsuleReconciler) rollbackReason(
+	ctx context.Context,
+	capsule *v1alpha2.Capsule,
+	deploy *appsv1.Deployment,
+	current *appsv1.ReplicaSet,
+) (string, error) {
+	if state, _ := deploymentRolloutState(deploy); state == "failed" {
+		return "progress deadline exceeded", nil
+	}
+
+	maxRestarts := int32(defaultAutoRollbackMaxRestarts)
+	if m := capsule.Spec.Rollout.AutoRollback.MaxRestarts; m != nil {
+		maxRestarts = *m
+	}
+
+	var pods v1.PodList
+	if err := r.List(
+		ctx,
+		&pods,
+		client.InNamespace(capsule.Namespace),
+		client.MatchingLabels{
+			LabelCapsule:                           capsule.Name,
+			appsv1.DefaultDeploymentUniqueLabelKey: current.Labels[appsv1.DefaultDeploymentUniqueLabelKey],
+		},
+	); err != nil {
+		return "", fmt.Errorf("could not list pods: %w", err)
+	}
+
+	for _, pod := range pods.Items {
+		if restarts := podRestarts(&pod); restarts >= maxRestarts {
+			return fmt.Sprintf("instance %s restarted %d times", pod.Name, restarts), nil
+		}
+	}
+
+	return "", nil
+}
+
+func (r *CapsuleReconciler) createDeployment(
 	capsule *v1alpha2.Capsule,
 	scheme *runtime.Scheme,
 	configs *configs,
@@ -741,6 +1207,13 @@ func createDeployment(
 		ports = append(ports, v1.ContainerPort{
 			Name:          i.Name,
 			ContainerPort: i.Port,
+			Protocol:      interfaceProtocol(i),
+		})
+	}
+	if port := extraMetricsPort(capsule); port != 0 {
+		ports = append(ports, v1.ContainerPort{
+			Name:          metricsPortName,
+			ContainerPort: port,
 		})
 	}
 
@@ -807,6 +1280,9 @@ func createDeployment(
 	if checksums.sharedEnv != "" {
 		podAnnotations[AnnotationChecksumSharedEnv] = checksums.sharedEnv
 	}
+	if checksums.connections != "" {
+		podAnnotations[AnnotationChecksumConnections] = checksums.connections
+	}
 
 	var envFrom []v1.EnvFromSource
 	if capsule.Spec.Env == nil || !capsule.Spec.Env.DisableAutomatic {
@@ -861,9 +1337,13 @@ func createDeployment(
 	}
 
 	c := v1.Container{
-		Name:         capsule.Name,
-		Image:        capsule.Spec.Image,
-		EnvFrom:      envFrom,
+		Name:    capsule.Name,
+		Image:   capsule.Spec.Image,
+		EnvFrom: envFrom,
+		Env: append(
+			telemetryEnv(capsule, r.Config.Get().Telemetry, envFrom, configs),
+			connectionEnv(configs, envFrom)...,
+		),
 		VolumeMounts: volumeMounts,
 		Ports:        ports,
 		Resources:    makeResourceRequirements(capsule),
@@ -874,6 +1354,9 @@ func createDeployment(
 		c.Command = []string{capsule.Spec.Command}
 	}
 
+	lifecycle, terminationGracePeriodSeconds := makeLifecycle(capsule)
+	c.Lifecycle = lifecycle
+
 	for _, i := range capsule.Spec.Interfaces {
 		if i.Liveness != nil {
 			c.LivenessProbe = &v1.Probe{
@@ -927,15 +1410,32 @@ func createDeployment(
 					},
 				},
 				Spec: v1.PodSpec{
-					Containers:         []v1.Container{c},
-					ServiceAccountName: capsule.Name,
-					Volumes:            volumes,
-					NodeSelector:       capsule.Spec.NodeSelector,
+					Containers:                    []v1.Container{c},
+					ServiceAccountName:            capsule.Name,
+					Volumes:                       volumes,
+					NodeSelector:                  capsule.Spec.NodeSelector,
+					TerminationGracePeriodSeconds: terminationGracePeriodSeconds,
 				},
 			},
 		},
 	}
 
+	if ro := capsule.Spec.Rollout; ro != nil {
+		d.Spec.ProgressDeadlineSeconds = ro.ProgressDeadlineSeconds
+		d.Spec.MinReadySeconds = ro.MinReadySeconds
+		if ro.MaxSurge != nil || ro.MaxUnavailable != nil {
+			d.Spec.Strategy = appsv1.DeploymentStrategy{
+				Type: appsv1.RollingUpdateDeploymentStrategyType,
+				RollingUpdate: &appsv1.RollingUpdateDeployment{
+					MaxSurge:       ro.MaxSurge,
+					MaxUnavailable: ro.MaxUnavailable,
+				},
+			}
+		}
+	}
+
+	r.applyScheduling(capsule, &d.Spec.Template.Spec)
+
 	if err := controllerutil.SetControllerReference(capsule, d, scheme); err != nil {
 		return nil, fmt.Errorf("could not set owner reference on deployment: %w", err)
 	}
@@ -943,6 +1443,137 @@ func createDeployment(
 	return d, nil
 }
 
+// defaultTerminationGracePeriod is the kubernetes default termination grace
+// period, used as a base when only a drain delay is specified.
+const defaultTerminationGracePeriod = 30 * time.Second
+
+func makeLifecycle(capsule *v1alpha2.Capsule) (*v1.Lifecycle, *int64) {
+	l := capsule.Spec.Lifecycle
+	if l == nil {
+		return nil, nil
+	}
+
+	var terminationGracePeriodSeconds *int64
+	if l.TerminationGracePeriod != nil {
+		terminationGracePeriodSeconds = ptr.New(int64(l.TerminationGracePeriod.Seconds()))
+	} else if l.DrainDelay != nil {
+		terminationGracePeriodSeconds = ptr.New(int64((l.DrainDelay.Duration + defaultTerminationGracePeriod).Seconds()))
+	}
+
+	lifecycle := &v1.Lifecycle{
+		PreStop:   makeLifecycleHandler(l.PreStop),
+		PostStart: makeLifecycleHandler(l.PostStart),
+	}
+
+	if l.DrainDelay != nil && l.DrainDelay.Duration > 0 {
+		sleep := fmt.Sprintf("sleep %d", int64(l.DrainDelay.Seconds()))
+		if lifecycle.PreStop == nil {
+			lifecycle.PreStop = &v1.LifecycleHandler{
+				Exec: &v1.ExecAction{
+					Command: []string{"sh", "-c", sleep},
+				},
+			}
+		} else if lifecycle.PreStop.Exec != nil {
+			// Run the sleep before the hook, passing the hook command as
+			// positional arguments to avoid having to quote it.
+			lifecycle.PreStop.Exec.Command = append(
+				[]string{"sh", "-c", sleep + ` && exec "$0" "$@"`},
+				lifecycle.PreStop.Exec.Command...,
+			)
+		}
+	}
+
+	if lifecycle.PreStop == nil && lifecycle.PostStart == nil {
+		lifecycle = nil
+	}
+
+	return lifecycle, terminationGracePeriodSeconds
+}
+
+func makeLifecycleHandler(hook *v1alpha2.LifecycleHook) *v1.LifecycleHandler {
+	if hook == nil {
+		return nil
+	}
+
+	switch {
+	case hook.Exec != nil:
+		return &v1.LifecycleHandler{
+			Exec: &v1.ExecAction{
+				Command: slices.Clone(hook.Exec.Command),
+			},
+		}
+	case hook.HTTP != nil:
+		return &v1.LifecycleHandler{
+			HTTPGet: &v1.HTTPGetAction{
+				Path: hook.HTTP.Path,
+				Port: intstr.FromInt32(hook.HTTP.Port),
+			},
+		}
+	}
+
+	return nil
+}
+
+func (r *CapsuleReconciler) applyScheduling(capsule *v1alpha2.Capsule, spec *v1.PodSpec) {
+	selector := &metav1.LabelSelector{
+		MatchLabels: map[string]string{
+			LabelCapsule: capsule.Name,
+		},
+	}
+
+	cfg := r.Config.Get()
+	spec.Tolerations = append(spec.Tolerations, cfg.Scheduling.Tolerations...)
+
+	constraints := cfg.Scheduling.TopologySpreadConstraints
+	if s := capsule.Spec.Scheduling; s != nil {
+		spec.Tolerations = append(spec.Tolerations, s.Tolerations...)
+		spec.PriorityClassName = s.PriorityClassName
+		if s.Affinity != nil {
+			spec.Affinity = s.Affinity.DeepCopy()
+		}
+		if len(s.TopologySpreadConstraints) > 0 {
+			constraints = s.TopologySpreadConstraints
+		}
+	}
+
+	for _, c := range constraints {
+		c := *c.DeepCopy()
+		if c.LabelSelector == nil {
+			c.LabelSelector = selector
+		}
+		spec.TopologySpreadConstraints = append(spec.TopologySpreadConstraints, c)
+	}
+
+	if s := capsule.Spec.Scheduling; s != nil && s.Spread != nil {
+		when := v1.ScheduleAnyway
+		if s.Spread.Required {
+			when = v1.DoNotSchedule
+		}
+		var topologyKeys []string
+		if s.Spread.Nodes {
+			topologyKeys = append(topologyKeys, v1.LabelHostname)
+		}
+		if s.Spread.Zones {
+			topologyKeys = append(topologyKeys, v1.LabelTopologyZone)
+		}
+		for _, key := range topologyKeys {
+			// A constraint of the same topology key takes precedence, as
+			// Kubernetes rejects duplicate constraints.
+			if slices.ContainsFunc(spec.TopologySpreadConstraints, func(c v1.TopologySpreadConstraint) bool {
+				return c.TopologyKey == key && c.WhenUnsatisfiable == when
+			}) {
+				continue
+			}
+			spec.TopologySpreadConstraints = append(spec.TopologySpreadConstraints, v1.TopologySpreadConstraint{
+				MaxSkew:           1,
+				TopologyKey:       key,
+				WhenUnsatisfiable: when,
+				LabelSelector:     selector,
+			})
+		}
+	}
+}
+
 func makeResourceRequirements(capsule *v1alpha2.Capsule) v1.ResourceRequirements {
 	requests := utils.DefaultResources.Requests
 	res := v1.ResourceRequirements{
@@ -990,6 +1621,9 @@ func (r *CapsuleReconciler) reconcileService(
 	if err != nil {
 		return err
 	}
+	if err := r.applyOverrides(capsule, "Service", service); err != nil {
+		return err
+	}
 
 	existingService := &v1.Service{}
 	if err := r.Get(ctx, req.NamespacedName, existingService); err != nil {
@@ -999,7 +1633,7 @@ func (r *CapsuleReconciler) reconcileService(
 			}
 
 			log.Info("creating service")
-			if err := r.Create(ctx, service); err != nil {
+			if err := r.createOwned(ctx, capsule, service); err != nil {
 				return fmt.Errorf("could not create service: %w", err)
 			}
 			existingService = service
@@ -1013,12 +1647,13 @@ func (r *CapsuleReconciler) reconcileService(
 			log.Info("Found existing service not owned by capsule. Will not delete it.")
 		} else {
 			log.Info("Found existing service not owned by capsule. Will not update it.")
+			r.recordNotOwned(capsule, existingService)
 			return errors.New("found existing service not owned by capsule")
 		}
 	} else {
 		if len(capsule.Spec.Interfaces) == 0 {
 			log.Info("deleting service")
-			if err := r.Delete(ctx, existingService); err != nil {
+			if err := r.deleteOwned(ctx, capsule, existingService); err != nil {
 				return fmt.Errorf("could not delete service: %w", err)
 			}
 		} else {
@@ -1052,9 +1687,11 @@ func createService(
 
 	for _, inf := range capsule.Spec.Interfaces {
 		svc.Spec.Ports = append(svc.Spec.Ports, v1.ServicePort{
-			Name:       inf.Name,
-			Port:       inf.Port,
-			TargetPort: intstr.FromString(inf.Name),
+			Name:        inf.Name,
+			Protocol:    interfaceProtocol(inf),
+			AppProtocol: interfaceAppProtocol(inf),
+			Port:        inf.Port,
+			TargetPort:  intstr.FromString(inf.Name),
 		})
 	}
 
@@ -1065,168 +1702,139 @@ func createService(
 	return svc, nil
 }
 
-func (r *CapsuleReconciler) reconcileCertificate(
+// interfaceProtocol returns the protocol of the ports of the interface. A
+// port must have the same protocol in the container and in the Services
+// targeting it. The webhook ensures the protocol of a LoadBalancer matches.
+func interfaceProtocol(inf v1alpha2.CapsuleInterface) v1.Protocol {
+	if inf.Protocol == v1alpha2.InterfaceProtocolUDP {
+		return v1.ProtocolUDP
+	}
+	return v1.ProtocolTCP
+}
+
+// interfaceAppProtocol returns the appProtocol of the Service ports of the
+// interface, or nil if the interface has no application protocol.
+func interfaceAppProtocol(inf v1alpha2.CapsuleInterface) *string {
+	switch inf.Protocol {
+	case v1alpha2.InterfaceProtocolHTTP:
+		return ptr.New("http")
+	case v1alpha2.InterfaceProtocolHTTP2:
+		return ptr.New("kubernetes.io/h2c")
+	case v1alpha2.InterfaceProtocolGRPC:
+		return ptr.New("grpc")
+	default:
+		return nil
+	}
+}
+
+// ingressIsSupported returns true if cert-manager is configured and an
+// issuer is resolvable for the capsule, either the issuer of the capsule or
+// the clusterIssuer of the config.
+func (r *CapsuleReconciler) ingressIsSupported(capsule *v1alpha2.Capsule) bool {
+	cm := r.Config.Get().Certmanager
+	return cm != nil && (cm.ClusterIssuer != "" || capsule.Spec.Issuer != nil)
+}
+
+func (r *CapsuleReconciler) reconcileIngress(
 	ctx context.Context,
 	req ctrl.Request,
 	log logr.Logger,
 	capsule *v1alpha2.Capsule,
 	status *v1alpha2.CapsuleStatus,
 ) error {
-	crt, err := r.createCertificate(capsule, r.Scheme)
-	if err != nil {
-		return err
-	}
-
-	existingCrt := &cmv1.Certificate{}
-	if err := r.Get(ctx, req.NamespacedName, existingCrt); err != nil {
-		if kerrors.IsNotFound(err) {
-			if !capsuleHasIngress(capsule) {
-				return nil
+	var ings []*netv1.Ingress
+	if capsuleHasIngress(capsule) {
+		if r.ingressIsSupported(capsule) {
+			var err error
+			if ings, err = r.createIngresses(capsule, r.Scheme); err != nil {
+				return err
 			}
-			if !r.ingressIsSupported() {
-				log.V(1).Info("not creating certificate as ingress is not supported: cert-manager config missing")
-				return nil
-			}
-			if !r.shouldCreateCertificateRessource() {
-				log.V(1).Info("not creating certificate as operator is configured to use ingress annotations")
-				return nil
-			}
-
-			log.Info("creating certificate")
-			if err := r.Create(ctx, crt); err != nil {
-				return fmt.Errorf("could not create certificate: %w", err)
-			}
-			existingCrt = crt
 		} else {
-			return fmt.Errorf("could not fetch certificate: %w", err)
+			log.V(1).Info("ingress not supported: cert-manager config or issuer missing")
 		}
 	}
 
-	if !IsOwnedBy(capsule, existingCrt) {
-		if capsuleHasIngress(capsule) {
-			log.Info("Found existing certificate not owned by capsule. Will not update it.")
-			return errors.New("found existing certificate not owned by capsule")
-		}
-		log.Info("Found existing certificate not owned by capsule. Will not delete it.")
-	} else {
-		if r.ingressIsSupported() && r.shouldCreateCertificateRessource() && capsuleHasIngress(capsule) {
-			return upsertIfNewer(ctx, r, existingCrt, crt, log, capsule, status, func(t1, t2 *cmv1.Certificate) bool {
-				return equality.Semantic.DeepEqual(t1.Spec, t2.Spec)
-			})
-		}
-		if !r.ingressIsSupported() {
-			log.V(1).Info("deleting certificate as ingress is not supported: cert-manager config missing")
-		} else if !r.shouldCreateCertificateRessource() {
-			log.V(1).Info("deleting certificate becausee operator is configured to use ingress annotations")
-		} else {
-			log.Info("deleting certificate")
-		}
-		if err := r.Delete(ctx, existingCrt); err != nil {
-			return fmt.Errorf("could not delete certificate: %w", err)
-		}
+	desired := map[string]struct{}{}
+	var errs []error
+	for _, ing := range ings {
+		desired[ing.Name] = struct{}{}
+		errs = append(errs, r.upsertIngress(ctx, log, capsule, status, ing))
 	}
 
-	return nil
-}
-
-func (r *CapsuleReconciler) shouldCreateCertificateRessource() bool {
-	return r.Config.Certmanager != nil &&
-		r.Config.Certmanager.CreateCertificateResources
+	// The Ingress named after the capsule may have been created before the
+	// Ingresses were labelled with the capsule.
+	if _, ok := desired[req.Name]; !ok {
+		errs = append(errs, r.deleteIngress(ctx, log, capsule, req.NamespacedName))
+	}
+	errs = append(errs, deleteStaleOwned(
+		ctx, r.Client, capsule, LabelCapsule, capsule.Namespace, &netv1.IngressList{}, desired,
+	))
+	return errors.Join(errs...)
 }
 
-func (r *CapsuleReconciler) createCertificate(
+func (r *CapsuleReconciler) upsertIngress(
+	ctx context.Context,
+	log logr.Logger,
 	capsule *v1alpha2.Capsule,
-	scheme *runtime.Scheme,
-) (*cmv1.Certificate, error) {
-	crt := &cmv1.Certificate{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      capsule.Name,
-			Namespace: capsule.Namespace,
-		},
-		Spec: cmv1.CertificateSpec{
-			SecretName: fmt.Sprintf("%s-tls", capsule.Name),
-		},
+	status *v1alpha2.CapsuleStatus,
+	ing *netv1.Ingress,
+) error {
+	if err := r.applyOverrides(capsule, "Ingress", ing); err != nil {
+		return err
 	}
+	setManagedAnnotations(ing)
 
-	if r.Config.Certmanager != nil {
-		crt.Spec.IssuerRef = cmmetav1.ObjectReference{
-			Kind: cmv1.ClusterIssuerKind,
-			Name: r.Config.Certmanager.ClusterIssuer,
+	existingIng := &netv1.Ingress{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(ing), existingIng); err != nil {
+		if !kerrors.IsNotFound(err) {
+			return fmt.Errorf("could not fetch ingress: %w", err)
 		}
-	}
 
-	for _, inf := range capsule.Spec.Interfaces {
-		if inf.Public != nil && inf.Public.Ingress != nil {
-			crt.Spec.DNSNames = append(crt.Spec.DNSNames, inf.Public.Ingress.Host)
+		log.Info("creating ingress", "name", ing.Name)
+		if err := r.createOwned(ctx, capsule, ing); err != nil {
+			return fmt.Errorf("could not create ingress: %w", err)
 		}
+		existingIng = ing
 	}
 
-	if err := controllerutil.SetControllerReference(capsule, crt, scheme); err != nil {
-		return nil, fmt.Errorf("could not set owner reference on certificate: %w", err)
+	if !IsOwnedBy(capsule, existingIng) {
+		log.Info("Found existing ingress not owned by capsule. Will not update it.", "name", ing.Name)
+		r.recordNotOwned(capsule, existingIng)
+		return fmt.Errorf("found existing ingress %s not owned by capsule", ing.Name)
 	}
 
-	return crt, nil
-}
-
-func (r *CapsuleReconciler) ingressIsSupported() bool {
-	cm := r.Config.Certmanager
-	return cm != nil && cm.ClusterIssuer != ""
+	return upsertIfNewer(ctx, r, existingIng, ing, log, capsule, status, func(t1, t2 *netv1.Ingress) bool {
+		// The protocol and features of the interfaces are configured by
+		// annotations, which are compared through the list of their keys.
+		return hasAnnotations(t2, t1.Annotations) &&
+			t2.Labels[LabelCapsule] == t1.Labels[LabelCapsule] &&
+			equality.Semantic.DeepEqual(t1.Spec, t2.Spec)
+	})
 }
 
-func (r *CapsuleReconciler) reconcileIngress(
+func (r *CapsuleReconciler) deleteIngress(
 	ctx context.Context,
-	req ctrl.Request,
 	log logr.Logger,
 	capsule *v1alpha2.Capsule,
-	status *v1alpha2.CapsuleStatus,
+	key types.NamespacedName,
 ) error {
-	ing, err := r.createIngress(capsule, r.Scheme)
-	if err != nil {
-		return err
-	}
-
 	existingIng := &netv1.Ingress{}
-	if err := r.Get(ctx, req.NamespacedName, existingIng); err != nil {
+	if err := r.Get(ctx, key, existingIng); err != nil {
 		if kerrors.IsNotFound(err) {
-			if !capsuleHasIngress(capsule) {
-				return nil
-			}
-			if !r.ingressIsSupported() {
-				log.V(1).Info("ingress not supported: cert-manager config missing")
-				return nil
-			}
-
-			log.Info("creating ingress")
-			if err := r.Create(ctx, ing); err != nil {
-				return fmt.Errorf("could not create ingress: %w", err)
-			}
-			existingIng = ing
-		} else {
-			return fmt.Errorf("could not fetch ingress: %w", err)
+			return nil
 		}
+		return fmt.Errorf("could not fetch ingress: %w", err)
 	}
 
 	if !IsOwnedBy(capsule, existingIng) {
-		if capsuleHasIngress(capsule) {
-			log.Info("Found existing ingress not owned by capsule. Will not update it.")
-			return errors.New("found existing ingress not owned by capsule")
-		}
-		log.Info("Found existing ingress not owned by capsule. Will not delete it.")
-	} else {
-		if r.ingressIsSupported() && capsuleHasIngress(capsule) {
-			return upsertIfNewer(ctx, r, existingIng, ing, log, capsule, status, func(t1, t2 *netv1.Ingress) bool {
-				return equality.Semantic.DeepEqual(t1.Spec, t2.Spec)
-			})
-		}
-		if !r.ingressIsSupported() {
-			log.V(1).Info("ingress not supported: cert-manager config missing")
-		}
-		log.Info("deleting ingress")
-		if err := r.Delete(ctx, existingIng); err != nil {
-			return fmt.Errorf("could not delete ingress: %w", err)
-		}
+		log.Info("Found existing ingress not owned by capsule. Will not delete it.", "name", key.Name)
+		return nil
 	}
 
+	log.Info("deleting ingress", "name", key.Name)
+	if err := r.deleteOwned(ctx, capsule, existingIng); err != nil {
+		return fmt.Errorf("could not delete ingress: %w", err)
+	}
 	return nil
 }
 
@@ -1239,56 +1847,101 @@ func capsuleHasIngress(capsule *v1alpha2.Capsule) bool {
 	return false
 }
 
+// createIngresses returns the Ingresses of the interfaces of the capsule, as
+// grouped by ingressGroups.
+func (r *CapsuleReconciler) createIngresses(
+	capsule *v1alpha2.Capsule,
+	scheme *runtime.Scheme,
+) ([]*netv1.Ingress, error) {
+	var ings []*netv1.Ingress
+	for _, g := range ingressGroups(capsule) {
+		ing, err := r.createIngress(capsule, g, scheme)
+		if err != nil {
+			return nil, err
+		}
+		ings = append(ings, ing)
+	}
+	return ings, nil
+}
+
 func (r *CapsuleReconciler) createIngress(
 	capsule *v1alpha2.Capsule,
+	g ingressGroup,
 	scheme *runtime.Scheme,
 ) (*netv1.Ingress, error) {
+	cfg := r.Config.Get()
 	ing := &netv1.Ingress{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:        capsule.Name,
+			Name:        g.name,
 			Namespace:   capsule.Namespace,
-			Annotations: r.Config.Ingress.Annotations,
+			Labels:      map[string]string{LabelCapsule: capsule.Name},
+			Annotations: map[string]string{},
 		},
 	}
+	// Copy the annotations, as the config is shared between reconciliations.
+	maps.Copy(ing.Annotations, cfg.Ingress.Annotations)
 
-	if r.Config.Ingress.ClassName != "" {
-		ing.Spec.IngressClassName = ptr.New(r.Config.Ingress.ClassName)
+	if cfg.Ingress.ClassName != "" {
+		ing.Spec.IngressClassName = ptr.New(cfg.Ingress.ClassName)
 	}
 
-	if r.ingressIsSupported() && !r.shouldCreateCertificateRessource() {
-		ing.Annotations["cert-manager.io/cluster-issuer"] = r.Config.Certmanager.ClusterIssuer
+	if r.ingressIsSupported(capsule) && !r.shouldCreateCertificateRessource() {
+		if err := r.setIssuerAnnotation(capsule, ing); err != nil {
+			return nil, err
+		}
 	}
 
-	for _, inf := range capsule.Spec.Interfaces {
-		if inf.Public != nil && inf.Public.Ingress != nil {
-			ing.Spec.Rules = append(ing.Spec.Rules, netv1.IngressRule{
-				Host: inf.Public.Ingress.Host,
-				IngressRuleValue: netv1.IngressRuleValue{
-					HTTP: &netv1.HTTPIngressRuleValue{
-						Paths: []netv1.HTTPIngressPath{
-							{
-								PathType: ptr.New(netv1.PathTypePrefix),
-								Path:     "/",
-								Backend: netv1.IngressBackend{
-									Service: &netv1.IngressServiceBackend{
-										Name: capsule.Name,
-										Port: netv1.ServiceBackendPort{
-											Name: inf.Name,
-										},
+	// The webhook ensures the interfaces of the Ingress have the same
+	// protocol.
+	pas := cfg.Ingress.ProtocolAnnotations[cfg.Ingress.ClassName]
+	maps.Copy(ing.Annotations, pas[string(g.interfaces[0].GetIngressProtocol())])
+
+	features, err := ingressFeatureAnnotations(&cfg.Ingress, capsule.Namespace, g)
+	if err != nil {
+		return nil, err
+	}
+	maps.Copy(ing.Annotations, features)
+
+	hosts := map[string]struct{}{}
+	for _, inf := range g.interfaces {
+		hosts[inf.Public.Ingress.Host] = struct{}{}
+		ing.Spec.Rules = append(ing.Spec.Rules, netv1.IngressRule{
+			Host: inf.Public.Ingress.Host,
+			IngressRuleValue: netv1.IngressRuleValue{
+				HTTP: &netv1.HTTPIngressRuleValue{
+					Paths: []netv1.HTTPIngressPath{
+						{
+							PathType: ptr.New(netv1.PathTypePrefix),
+							Path:     "/",
+							Backend: netv1.IngressBackend{
+								Service: &netv1.IngressServiceBackend{
+									Name: capsule.Name,
+									Port: netv1.ServiceBackendPort{
+										Name: inf.Name,
 									},
 								},
 							},
 						},
 					},
 				},
-			})
-			if len(ing.Spec.TLS) == 0 {
-				ing.Spec.TLS = []netv1.IngressTLS{{
-					SecretName: fmt.Sprintf("%s-tls", capsule.Name),
-				}}
+			},
+		})
+	}
+
+	for _, crt := range r.certificates(capsule) {
+		var crtHosts []string
+		for _, h := range crt.hosts {
+			if _, ok := hosts[h]; ok {
+				crtHosts = append(crtHosts, h)
 			}
-			ing.Spec.TLS[0].Hosts = append(ing.Spec.TLS[0].Hosts, inf.Public.Ingress.Host)
 		}
+		if len(crtHosts) == 0 {
+			continue
+		}
+		ing.Spec.TLS = append(ing.Spec.TLS, netv1.IngressTLS{
+			Hosts:      crtHosts,
+			SecretName: crt.secretName,
+		})
 	}
 
 	if err := controllerutil.SetControllerReference(capsule, ing, scheme); err != nil {
@@ -1309,6 +1962,7 @@ func (r *CapsuleReconciler) reconcileLoadBalancer(
 	if err != nil {
 		return err
 	}
+	setManagedAnnotations(svc)
 
 	nsName := types.NamespacedName{
 		Name:      fmt.Sprintf("%s-lb", req.NamespacedName.Name),
@@ -1322,7 +1976,7 @@ func (r *CapsuleReconciler) reconcileLoadBalancer(
 			}
 
 			log.Info("creating loadbalancer service")
-			if err := r.Create(ctx, svc); err != nil {
+			if err := r.createOwned(ctx, capsule, svc); err != nil {
 				return fmt.Errorf("could not create loadbalancer: %w", err)
 			}
 			existingSvc = svc
@@ -1334,17 +1988,20 @@ func (r *CapsuleReconciler) reconcileLoadBalancer(
 	if !IsOwnedBy(capsule, existingSvc) {
 		if capsuleHasLoadBalancer(capsule) {
 			log.Info("Found existing loadbalancer service not owned by capsule. Will not update it.")
+			r.recordNotOwned(capsule, existingSvc)
 			return errors.New("found existing loadbalancer service not owned by capsule")
 		}
 		log.Info("Found existing loadbalancer service not owned by capsule. Will not delete it.")
 	} else {
 		if capsuleHasLoadBalancer(capsule) {
 			return upsertIfNewer(ctx, r, existingSvc, svc, log, capsule, status, func(t1, t2 *v1.Service) bool {
-				return equality.Semantic.DeepEqual(t1.Spec, t2.Spec)
+				// The managed annotations are compared through the list of
+				// their keys.
+				return hasAnnotations(t2, t1.Annotations) && equality.Semantic.DeepEqual(t1.Spec, t2.Spec)
 			})
 		}
 		log.Info("deleting loadbalancer service")
-		if err := r.Delete(ctx, existingSvc); err != nil {
+		if err := r.deleteOwned(ctx, capsule, existingSvc); err != nil {
 			return fmt.Errorf("could not delete loadbalancer service: %w", err)
 		}
 	}
@@ -1379,12 +2036,32 @@ func createLoadBalancer(
 	}
 
 	for _, inf := range capsule.Spec.Interfaces {
-		if inf.Public != nil && inf.Public.LoadBalancer != nil {
-			svc.Spec.Ports = append(svc.Spec.Ports, v1.ServicePort{
-				Name:       inf.Name,
-				Port:       inf.Public.LoadBalancer.Port,
-				TargetPort: intstr.FromString(inf.Name),
-			})
+		if inf.Public == nil || inf.Public.LoadBalancer == nil {
+			continue
+		}
+		lb := inf.Public.LoadBalancer
+		svc.Spec.Ports = append(svc.Spec.Ports, v1.ServicePort{
+			Name:        inf.Name,
+			Protocol:    interfaceProtocol(inf),
+			AppProtocol: interfaceAppProtocol(inf),
+			Port:        lb.Port,
+			TargetPort:  intstr.FromString(inf.Name),
+			NodePort:    lb.NodePort,
+		})
+
+		// The webhook ensures the interfaces configure the Service the same
+		// way.
+		if len(lb.SourceRanges) > 0 {
+			svc.Spec.LoadBalancerSourceRanges = lb.SourceRanges
+		}
+		if lb.ExternalTrafficPolicy != "" {
+			svc.Spec.ExternalTrafficPolicy = lb.ExternalTrafficPolicy
+		}
+		for k, v := range lb.Annotations {
+			if svc.Annotations == nil {
+				svc.Annotations = map[string]string{}
+			}
+			svc.Annotations[k] = v
 		}
 	}
 
@@ -1406,12 +2083,15 @@ func (r *CapsuleReconciler) reconcileHorizontalPodAutoscaler(
 	if err != nil {
 		return err
 	}
+	if err := r.applyOverrides(capsule, "HorizontalPodAutoscaler", hpa); err != nil {
+		return err
+	}
 	existingHPA := &autoscalingv2.HorizontalPodAutoscaler{}
 	if err = r.Get(ctx, client.ObjectKeyFromObject(hpa), existingHPA); err != nil {
 		if kerrors.IsNotFound(err) {
 			if shouldHaveHPA {
 				log.Info("creating horizontal pod autoscaler")
-				if err := r.Create(ctx, hpa); err != nil {
+				if err := r.createOwned(ctx, capsule, hpa); err != nil {
 					return fmt.Errorf("could not create horizontal pod autoscaler: %w", err)
 				}
 			}
@@ -1421,10 +2101,19 @@ func (r *CapsuleReconciler) reconcileHorizontalPodAutoscaler(
 		}
 	}
 
-	if !shouldHaveHPA {
-		if err := r.Delete(ctx, existingHPA); err != nil {
-			return err
+	if !IsOwnedBy(capsule, existingHPA) {
+		if shouldHaveHPA {
+			log.Info("Found existing horizontal pod autoscaler not owned by capsule. Will not update it.")
+			r.recordNotOwned(capsule, existingHPA)
+			return errors.New("found existing horizontal pod autoscaler not owned by capsule")
 		}
+		log.Info("Found existing horizontal pod autoscaler not owned by capsule. Will not delete it.")
+		return nil
+	}
+
+	if !shouldHaveHPA {
+		log.Info("deleting horizontal pod autoscaler")
+		return r.deleteOwned(ctx, capsule, existingHPA)
 	}
 
 	return upsertIfNewer(
@@ -1561,6 +2250,130 @@ func createHPA(
 	return hpa, true, nil
 }
 
+func (r *CapsuleReconciler) reconcilePodDisruptionBudget(
+	ctx context.Context,
+	_ ctrl.Request,
+	log logr.Logger,
+	capsule *v1alpha2.Capsule,
+	status *v1alpha2.CapsuleStatus,
+) error {
+	pdb, shouldHavePDB, err := r.createPodDisruptionBudget(capsule, r.Scheme)
+	if err != nil {
+		return err
+	}
+
+	existingPDB := &policyv1.PodDisruptionBudget{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(pdb), existingPDB); err != nil {
+		if kerrors.IsNotFound(err) {
+			if !shouldHavePDB {
+				return nil
+			}
+
+			log.Info("creating pod disruption budget")
+			if err := r.createOwned(ctx, capsule, pdb); err != nil {
+				return fmt.Errorf("could not create pod disruption budget: %w", err)
+			}
+			existingPDB = pdb
+		} else {
+			return fmt.Errorf("could not fetch pod disruption budget: %w", err)
+		}
+	}
+
+	if !IsOwnedBy(capsule, existingPDB) {
+		if shouldHavePDB {
+			log.Info("Found existing pod disruption budget not owned by capsule. Will not update it.")
+			r.recordNotOwned(capsule, existingPDB)
+			return errors.New("found existing pod disruption budget not owned by capsule")
+		}
+		log.Info("Found existing pod disruption budget not owned by capsule. Will not delete it.")
+	} else {
+		if shouldHavePDB {
+			return upsertIfNewer(
+				ctx, r,
+				existingPDB,
+				pdb,
+				log, capsule, status,
+				func(t1, t2 *policyv1.PodDisruptionBudget) bool {
+					return equality.Semantic.DeepEqual(t1.Spec, t2.Spec)
+				},
+			)
+		}
+		log.Info("deleting pod disruption budget")
+		if err := r.deleteOwned(ctx, capsule, existingPDB); err != nil {
+			return fmt.Errorf("could not delete pod disruption budget: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// createPodDisruptionBudget creates the PodDisruptionBudget of the capsule and
+// reports wether it should exist. A budget is only kept if it would still
+// allow at least one instance to be disrupted.
+func (r *CapsuleReconciler) createPodDisruptionBudget(
+	capsule *v1alpha2.Capsule,
+	scheme *runtime.Scheme,
+) (*policyv1.PodDisruptionBudget, bool, error) {
+	pdb := &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      capsule.Name,
+			Namespace: capsule.Namespace,
+		},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					LabelCapsule: capsule.Name,
+				},
+			},
+		},
+	}
+	if err := controllerutil.SetControllerReference(capsule, pdb, scheme); err != nil {
+		return nil, false, fmt.Errorf("could not set owner reference on pod disruption budget: %w", err)
+	}
+
+	if b := capsule.Spec.Scale.Horizontal.DisruptionBudget; b != nil {
+		if b.Disabled {
+			return pdb, false, nil
+		}
+		pdb.Spec.MinAvailable = b.MinAvailable
+		pdb.Spec.MaxUnavailable = b.MaxUnavailable
+	} else if c := r.Config.Get().PodDisruptionBudget; c != nil {
+		pdb.Spec.MinAvailable = c.MinAvailable
+		pdb.Spec.MaxUnavailable = c.MaxUnavailable
+	}
+
+	if pdb.Spec.MinAvailable == nil && pdb.Spec.MaxUnavailable == nil {
+		return pdb, false, nil
+	}
+
+	instances := int(capsule.Spec.Scale.Horizontal.Instances.Min)
+	if instances <= 1 {
+		return pdb, false, nil
+	}
+
+	if pdb.Spec.MinAvailable != nil {
+		minAvailable, err := intstr.GetScaledValueFromIntOrPercent(pdb.Spec.MinAvailable, instances, true)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid minAvailable of pod disruption budget: %w", err)
+		}
+		if minAvailable >= instances {
+			return pdb, false, nil
+		}
+	}
+
+	if pdb.Spec.MaxUnavailable != nil {
+		maxUnavailable, err := intstr.GetScaledValueFromIntOrPercent(pdb.Spec.MaxUnavailable, instances, false)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid maxUnavailable of pod disruption budget: %w", err)
+		}
+		if maxUnavailable < 1 {
+			return pdb, false, nil
+		}
+	}
+
+	return pdb, true, nil
+}
+
 func (r *CapsuleReconciler) reconcileServiceAccount(
 	ctx context.Context,
 	_ ctrl.Request,
@@ -1568,16 +2381,24 @@ func (r *CapsuleReconciler) reconcileServiceAccount(
 	capsule *v1alpha2.Capsule,
 	status *v1alpha2.CapsuleStatus,
 ) error {
-	sa, err := createServiceAccount(capsule, r.Scheme)
+	pullSecrets, err := imagePullSecrets(ctx, r.Client, capsule.Namespace)
+	if err != nil {
+		return fmt.Errorf("could not list image pull secrets: %w", err)
+	}
+
+	sa, err := createServiceAccount(capsule, r.Scheme, pullSecrets)
 	if err != nil {
 		return err
 	}
+	if err := r.applyOverrides(capsule, "ServiceAccount", sa); err != nil {
+		return err
+	}
 
 	existingSA := &v1.ServiceAccount{}
 	if err = r.Get(ctx, client.ObjectKeyFromObject(sa), existingSA); err != nil {
 		if kerrors.IsNotFound(err) {
 			log.Info("creating service account")
-			if err := r.Create(ctx, sa); err != nil {
+			if err := r.createOwned(ctx, capsule, sa); err != nil {
 				return fmt.Errorf("could not create service account: %w", err)
 			}
 			return nil
@@ -1587,16 +2408,22 @@ func (r *CapsuleReconciler) reconcileServiceAccount(
 	}
 
 	return upsertIfNewer(ctx, r, existingSA, sa, log, capsule, status, func(t1, t2 *v1.ServiceAccount) bool {
-		return equality.Semantic.DeepEqual(t1.Annotations, t2.Annotations)
+		return equality.Semantic.DeepEqual(t1.Annotations, t2.Annotations) &&
+			equality.Semantic.DeepEqual(t1.ImagePullSecrets, t2.ImagePullSecrets)
 	})
 }
 
-func createServiceAccount(capsule *v1alpha2.Capsule, scheme *runtime.Scheme) (*v1.ServiceAccount, error) {
+func createServiceAccount(
+	capsule *v1alpha2.Capsule,
+	scheme *runtime.Scheme,
+	pullSecrets []v1.LocalObjectReference,
+) (*v1.ServiceAccount, error) {
 	sa := &v1.ServiceAccount{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      capsule.Name,
 			Namespace: capsule.Namespace,
 		},
+		ImagePullSecrets: pullSecrets,
 	}
 	if err := controllerutil.SetControllerReference(capsule, sa, scheme); err != nil {
 		return nil, err
@@ -1605,6 +2432,78 @@ func createServiceAccount(capsule *v1alpha2.Capsule, scheme *runtime.Scheme) (*v
 	return sa, nil
 }
 
+// hasAnnotations returns true if the object has all the annotations. Other
+// annotations of the object are ignored, so objects must record their
+// managed annotations with setManagedAnnotations for removed annotations to
+// be detected.
+func hasAnnotations(obj client.Object, annotations map[string]string) bool {
+	for k, v := range annotations {
+		if a, ok := obj.GetAnnotations()[k]; !ok || a != v {
+			return false
+		}
+	}
+	return true
+}
+
+// setManagedAnnotations records the annotations of the object in its
+// AnnotationManagedAnnotations annotation.
+func setManagedAnnotations(obj client.Object) {
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	delete(annotations, AnnotationManagedAnnotations)
+	keys := maps.Keys(annotations)
+	slices.Sort(keys)
+	annotations[AnnotationManagedAnnotations] = strings.Join(keys, ",")
+	obj.SetAnnotations(annotations)
+}
+
+// mergeManagedAnnotations returns the current annotations with those
+// managed by the operator replaced by the desired annotations.
+func mergeManagedAnnotations(current, desired map[string]string) map[string]string {
+	res := map[string]string{}
+	maps.Copy(res, current)
+	for _, k := range strings.Split(current[AnnotationManagedAnnotations], ",") {
+		delete(res, k)
+	}
+	maps.Copy(res, desired)
+	return res
+}
+
+// patchManaged patches the current object to the spec of the materialized
+// object and the labels and annotations of the desired object, with the
+// annotations as recorded in AnnotationManagedAnnotations. Labels and
+// annotations set by others are kept. The patched object is returned.
+func patchManaged[T client.Object](ctx context.Context, c client.Client, current, materialized, desired T) (T, error) {
+	var target T
+	cur, err := runtime.DefaultUnstructuredConverter.ToUnstructured(current)
+	if err != nil {
+		return target, err
+	}
+	mat, err := runtime.DefaultUnstructuredConverter.ToUnstructured(materialized)
+	if err != nil {
+		return target, err
+	}
+	if spec, ok := mat["spec"]; ok {
+		cur["spec"] = spec
+	} else {
+		delete(cur, "spec")
+	}
+
+	target = reflect.New(reflect.TypeOf(current).Elem()).Interface().(T)
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(cur, target); err != nil {
+		return target, err
+	}
+	labels := map[string]string{}
+	maps.Copy(labels, current.GetLabels())
+	maps.Copy(labels, desired.GetLabels())
+	target.SetLabels(labels)
+	target.SetAnnotations(mergeManagedAnnotations(current.GetAnnotations(), desired.GetAnnotations()))
+
+	return target, c.Patch(ctx, target, client.MergeFrom(current))
+}
+
 func upsertIfNewer[T client.Object](
 	ctx context.Context,
 	r *CapsuleReconciler,
@@ -1641,101 +2540,54 @@ func upsertIfNewer[T client.Object](
 
 	if !IsOwnedBy(capsule, newObj) {
 		log.Info("Found existing resource not owned by capsule. Will not update it.")
+		r.recordNotOwned(capsule, currentObj)
 		res.State = "failed"
 		res.Message = "found existing resource not owned by capsule"
 		return fmt.Errorf("found existing %s not owned by capsule", gvk.Kind)
 	}
 
-	orig := newObj.DeepCopyObject().(client.Object)
+	orig := newObj.DeepCopyObject().(T)
 
 	// Dry run to fully materialize the new spec.
 	newObj.SetResourceVersion(currentObj.GetResourceVersion())
 	if err := r.Update(ctx, newObj, client.DryRunAll); err != nil {
 		res.State = "failed"
 		res.Message = err.Error()
+		r.Recorder.Eventf(
+			capsule, v1.EventTypeWarning, EventReasonFailed, "Could not update %s %s: %v", gvk.Kind, newObj.GetName(), err,
+		)
 		return fmt.Errorf("could not update %s: %w", gvk.Kind, err)
 	}
 	newObj.SetResourceVersion("")
 
 	if !equal(newObj, currentObj) {
 		log.Info("updating resource")
-		if err := r.Update(ctx, orig); err != nil {
+		// Objects recording their managed annotations are patched, keeping
+		// the labels and annotations set by others.
+		if _, ok := orig.GetAnnotations()[AnnotationManagedAnnotations]; ok {
+			var patched T
+			if patched, err = patchManaged(ctx, r.Client, currentObj, newObj, orig); err == nil {
+				orig = patched
+			}
+		} else {
+			err = r.Update(ctx, orig)
+		}
+		if err != nil {
 			res.State = "failed"
 			res.Message = err.Error()
+			r.Recorder.Eventf(
+				capsule, v1.EventTypeWarning, EventReasonFailed, "Could not update %s %s: %v", gvk.Kind, orig.GetName(), err,
+			)
 			return fmt.Errorf("could not update %s: %w", gvk.Kind, err)
 		}
+		// Reflect the update, e.g. a new generation, in the current object.
+		if o, ok := any(orig).(interface{ DeepCopyInto(T) }); ok {
+			o.DeepCopyInto(currentObj)
+		}
+		r.Recorder.Eventf(capsule, v1.EventTypeNormal, EventReasonUpdated, "Updated %s %s", gvk.Kind, orig.GetName())
 		return nil
 	}
 
 	log.Info("resource is up-to-date")
 	return nil
 }
-
-func (r *CapsuleReconciler) reconcilePrometheusServiceMonitor(
-	ctx context.Context,
-	_ ctrl.Request,
-	log logr.Logger,
-	capsule *v1alpha2.Capsule,
-	status *v1alpha2.CapsuleStatus,
-) error {
-	if r.Config.PrometheusServiceMonitor == nil || r.Config.PrometheusServiceMonitor.PortName == "" {
-		return nil
-	}
-
-	serviceMonitor, err := r.createPrometheusServiceMonitor(capsule, r.Scheme)
-	if err != nil {
-		return err
-	}
-
-	existingServiceMonitor := &monitorv1.ServiceMonitor{}
-	if err = r.Get(ctx, client.ObjectKeyFromObject(serviceMonitor), existingServiceMonitor); err != nil {
-		if kerrors.IsNotFound(err) {
-			log.Info("creating prometheus service monitor")
-			if err := r.Create(ctx, serviceMonitor); err != nil {
-				return fmt.Errorf("could not create prometheus service monitor: %w", err)
-			}
-			return nil
-		} else if err != nil {
-			return fmt.Errorf("could not fetch prometheus service monitor: %w", err)
-		}
-	}
-
-	return upsertIfNewer(
-		ctx, r,
-		existingServiceMonitor,
-		serviceMonitor,
-		log, capsule, status,
-		func(t1, t2 *monitorv1.ServiceMonitor) bool {
-			return equality.Semantic.DeepEqual(t1.Annotations, t2.Annotations)
-		},
-	)
-}
-
-func (r *CapsuleReconciler) createPrometheusServiceMonitor(
-	capsule *v1alpha2.Capsule,
-	scheme *runtime.Scheme,
-) (*monitorv1.ServiceMonitor, error) {
-	s := &monitorv1.ServiceMonitor{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:            capsule.Name,
-			Namespace:       capsule.Namespace,
-			ResourceVersion: "",
-		},
-		Spec: monitorv1.ServiceMonitorSpec{
-			Selector: metav1.LabelSelector{
-				MatchLabels: map[string]string{
-					LabelCapsule: capsule.Name,
-				},
-			},
-			Endpoints: []monitorv1.Endpoint{{
-				Port: r.Config.PrometheusServiceMonitor.PortName,
-				Path: r.Config.PrometheusServiceMonitor.Path,
-			}},
-		},
-	}
-	if err := controllerutil.SetControllerReference(capsule, s, scheme); err != nil {
-		return nil, err
-	}
-
-	return s, nil
-}
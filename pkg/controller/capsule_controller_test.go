@@ -0,0 +1,834 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cmv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	cmmetav1 "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
+	"github.com/go-logr/logr"
+	configv1alpha1 "github.com/rigdev/rig/pkg/api/config/v1alpha1"
+	"github.com/rigdev/rig/pkg/api/v1alpha2"
+	"github.com/rigdev/rig/pkg/ptr"
+	"github.com/rigdev/rig/pkg/service/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func Test_applyScheduling(t *testing.T) {
+	t.Parallel()
+	selector := &metav1.LabelSelector{
+		MatchLabels: map[string]string{LabelCapsule: "test"},
+	}
+
+	tests := []struct {
+		name     string
+		s        *v1alpha2.Scheduling
+		expected []v1.TopologySpreadConstraint
+	}{
+		{
+			name: "no spread",
+			s:    &v1alpha2.Scheduling{},
+		},
+		{
+			name: "preferred spread across nodes and zones",
+			s: &v1alpha2.Scheduling{
+				Spread: &v1alpha2.Spread{Nodes: true, Zones: true},
+			},
+			expected: []v1.TopologySpreadConstraint{
+				{
+					MaxSkew:           1,
+					TopologyKey:       v1.LabelHostname,
+					WhenUnsatisfiable: v1.ScheduleAnyway,
+					LabelSelector:     selector,
+				},
+				{
+					MaxSkew:           1,
+					TopologyKey:       v1.LabelTopologyZone,
+					WhenUnsatisfiable: v1.ScheduleAnyway,
+					LabelSelector:     selector,
+				},
+			},
+		},
+		{
+			name: "required spread with constraint of the same key",
+			s: &v1alpha2.Scheduling{
+				Spread: &v1alpha2.Spread{Nodes: true, Zones: true, Required: true},
+				TopologySpreadConstraints: []v1.TopologySpreadConstraint{{
+					MaxSkew:           2,
+					TopologyKey:       v1.LabelTopologyZone,
+					WhenUnsatisfiable: v1.DoNotSchedule,
+				}},
+			},
+			expected: []v1.TopologySpreadConstraint{
+				{
+					MaxSkew:           2,
+					TopologyKey:       v1.LabelTopologyZone,
+					WhenUnsatisfiable: v1.DoNotSchedule,
+					LabelSelector:     selector,
+				},
+				{
+					MaxSkew:           1,
+					TopologyKey:       v1.LabelHostname,
+					WhenUnsatisfiable: v1.DoNotSchedule,
+					LabelSelector:     selector,
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			r := &CapsuleReconciler{
+				Config: config.NewServiceFromConfig(&configv1alpha1.OperatorConfig{}),
+			}
+			capsule := &v1alpha2.Capsule{
+				ObjectMeta: metav1.ObjectMeta{Name: "test"},
+				Spec:       v1alpha2.CapsuleSpec{Scheduling: tt.s},
+			}
+			var spec v1.PodSpec
+			r.applyScheduling(capsule, &spec)
+			assert.Equal(t, tt.expected, spec.TopologySpreadConstraints)
+			assert.Nil(t, spec.Affinity)
+		})
+	}
+}
+
+func Test_deploymentRolloutState(t *testing.T) {
+	t.Parallel()
+	progressing := func(status v1.ConditionStatus, reason, message string) appsv1.DeploymentCondition {
+		return appsv1.DeploymentCondition{
+			Type:    appsv1.DeploymentProgressing,
+			Status:  status,
+			Reason:  reason,
+			Message: message,
+		}
+	}
+
+	tests := []struct {
+		name               string
+		generation         int64
+		observedGeneration int64
+		conditions         []appsv1.DeploymentCondition
+		expectedState      string
+		expectedMessage    string
+	}{
+		{
+			name:               "spec not observed",
+			generation:         2,
+			observedGeneration: 1,
+			conditions: []appsv1.DeploymentCondition{
+				progressing(v1.ConditionTrue, deploymentReasonNewReplicaSetAvailable, ""),
+			},
+			expectedState: "progressing",
+		},
+		{
+			name:               "progress deadline exceeded",
+			generation:         2,
+			observedGeneration: 2,
+			conditions: []appsv1.DeploymentCondition{
+				progressing(v1.ConditionFalse, deploymentReasonProgressDeadlineExceeded, "ReplicaSet has timed out"),
+			},
+			expectedState:   "failed",
+			expectedMessage: "rollout stalled: progress deadline exceeded",
+		},
+		{
+			name:               "progressing",
+			generation:         2,
+			observedGeneration: 2,
+			conditions: []appsv1.DeploymentCondition{
+				{Type: appsv1.DeploymentAvailable, Status: v1.ConditionTrue},
+				progressing(v1.ConditionTrue, "ReplicaSetUpdated", `ReplicaSet "test-1" is progressing.`),
+			},
+			expectedState:   "progressing",
+			expectedMessage: `ReplicaSet "test-1" is progressing.`,
+		},
+		{
+			name:               "new replica set available",
+			generation:         2,
+			observedGeneration: 2,
+			conditions: []appsv1.DeploymentCondition{
+				progressing(
+					v1.ConditionTrue, deploymentReasonNewReplicaSetAvailable, `ReplicaSet "test-1" has successfully progressed.`,
+				),
+			},
+			expectedState: "created",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			deploy := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Generation: tt.generation},
+				Status: appsv1.DeploymentStatus{
+					ObservedGeneration: tt.observedGeneration,
+					Conditions:         tt.conditions,
+				},
+			}
+			state, message := deploymentRolloutState(deploy)
+			assert.Equal(t, tt.expectedState, state)
+			assert.Equal(t, tt.expectedMessage, message)
+		})
+	}
+}
+
+func Test_isRolledBack(t *testing.T) {
+	t.Parallel()
+	template := &v1.PodTemplateSpec{
+		Spec: v1.PodSpec{Containers: []v1.Container{{Name: "test", Image: "test:1"}}},
+	}
+	hash, err := podTemplateHash(template)
+	require.NoError(t, err)
+
+	template.Annotations = map[string]string{AnnotationChecksumEnv: "changed"}
+	changedHash, err := podTemplateHash(template)
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, changedHash)
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		hash        string
+		expected    bool
+	}{
+		{
+			name: "not rolled back",
+			hash: hash,
+		},
+		{
+			name:        "rolled back from the template",
+			annotations: map[string]string{AnnotationRolledBackTemplate: hash},
+			hash:        hash,
+			expected:    true,
+		},
+		{
+			name:        "template changed since the rollback",
+			annotations: map[string]string{AnnotationRolledBackTemplate: hash},
+			hash:        changedHash,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			deploy := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations}}
+			assert.Equal(t, tt.expected, isRolledBack(deploy, tt.hash))
+		})
+	}
+}
+
+func Test_podRestartedPredicate(t *testing.T) {
+	t.Parallel()
+	pod := func(labels map[string]string, restarts int32) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Labels: labels},
+			Status: v1.PodStatus{
+				ContainerStatuses: []v1.ContainerStatus{{RestartCount: restarts}},
+			},
+		}
+	}
+	capsuleLabels := map[string]string{LabelCapsule: "test"}
+
+	assert.False(t, podRestartedPredicate.Create(event.CreateEvent{Object: pod(capsuleLabels, 1)}))
+	assert.False(t, podRestartedPredicate.Delete(event.DeleteEvent{Object: pod(capsuleLabels, 1)}))
+
+	tests := []struct {
+		name     string
+		old      *v1.Pod
+		new      *v1.Pod
+		expected bool
+	}{
+		{
+			name:     "container restarted",
+			old:      pod(capsuleLabels, 0),
+			new:      pod(capsuleLabels, 1),
+			expected: true,
+		},
+		{
+			name: "no restart",
+			old:  pod(capsuleLabels, 1),
+			new:  pod(capsuleLabels, 1),
+		},
+		{
+			name: "not a capsule pod",
+			old:  pod(nil, 0),
+			new:  pod(nil, 1),
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.expected, podRestartedPredicate.Update(event.UpdateEvent{
+				ObjectOld: tt.old,
+				ObjectNew: tt.new,
+			}))
+		})
+	}
+}
+
+func newReplicaSet(name string, owner types.UID, revision string) *appsv1.ReplicaSet {
+	return &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels: map[string]string{
+				LabelCapsule:                           "test",
+				appsv1.DefaultDeploymentUniqueLabelKey: name,
+			},
+			Annotations: map[string]string{AnnotationDeploymentRevision: revision},
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "apps/v1",
+				Kind:       "Deployment",
+				Name:       "test",
+				UID:        owner,
+				Controller: ptr.New(true),
+			}},
+		},
+	}
+}
+
+func newRolloutDeployment(revision string) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test",
+			Namespace:   "default",
+			UID:         "deploy",
+			Annotations: map[string]string{AnnotationDeploymentRevision: revision},
+		},
+	}
+}
+
+func Test_getRolloutReplicaSets(t *testing.T) {
+	t.Parallel()
+	capsule := &v1alpha2.Capsule{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}}
+
+	tests := []struct {
+		name             string
+		deploy           *appsv1.Deployment
+		objs             []client.Object
+		expectedCurrent  string
+		expectedPrevious string
+	}{
+		{
+			name:   "no revision",
+			deploy: newRolloutDeployment(""),
+			objs:   []client.Object{newReplicaSet("rs1", "deploy", "1")},
+		},
+		{
+			name:            "first revision",
+			deploy:          newRolloutDeployment("1"),
+			objs:            []client.Object{newReplicaSet("rs1", "deploy", "1")},
+			expectedCurrent: "rs1",
+		},
+		{
+			name:   "previous revision",
+			deploy: newRolloutDeployment("3"),
+			objs: []client.Object{
+				newReplicaSet("rs1", "deploy", "1"),
+				newReplicaSet("rs2", "deploy", "2"),
+				newReplicaSet("rs3", "deploy", "3"),
+				newReplicaSet("other", "other", "2"),
+			},
+			expectedCurrent:  "rs3",
+			expectedPrevious: "rs2",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			r := &CapsuleReconciler{
+				Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(tt.objs...).Build(),
+			}
+			current, previous, err := r.getRolloutReplicaSets(context.Background(), capsule, tt.deploy)
+			require.NoError(t, err)
+
+			var currentName, previousName string
+			if current != nil {
+				currentName = current.Name
+			}
+			if previous != nil {
+				previousName = previous.Name
+			}
+			assert.Equal(t, tt.expectedCurrent, currentName)
+			assert.Equal(t, tt.expectedPrevious, previousName)
+		})
+	}
+}
+
+func Test_rollbackReason(t *testing.T) {
+	t.Parallel()
+	current := newReplicaSet("rs2", "deploy", "2")
+	pod := func(name, rs string, restarts int32) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels: map[string]string{
+					LabelCapsule:                           "test",
+					appsv1.DefaultDeploymentUniqueLabelKey: rs,
+				},
+			},
+			Status: v1.PodStatus{
+				ContainerStatuses: []v1.ContainerStatus{{RestartCount: restarts}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		maxRestarts *int32
+		stalled     bool
+		objs        []client.Object
+		expected    string
+	}{
+		{
+			name:     "progress deadline exceeded",
+			stalled:  true,
+			expected: "progress deadline exceeded",
+		},
+		{
+			name: "restarts below the default maximum",
+			objs: []client.Object{pod("p1", "rs2", 2)},
+		},
+		{
+			name:     "restarts at the default maximum",
+			objs:     []client.Object{pod("p1", "rs2", 3)},
+			expected: "instance p1 restarted 3 times",
+		},
+		{
+			name:        "restarts at a custom maximum",
+			maxRestarts: ptr.New(int32(1)),
+			objs:        []client.Object{pod("p1", "rs2", 1)},
+			expected:    "instance p1 restarted 1 times",
+		},
+		{
+			name: "restarts of the previous revision",
+			objs: []client.Object{pod("p1", "rs1", 5)},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			capsule := &v1alpha2.Capsule{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+				Spec: v1alpha2.CapsuleSpec{
+					Rollout: &v1alpha2.Rollout{
+						AutoRollback: &v1alpha2.AutoRollback{MaxRestarts: tt.maxRestarts},
+					},
+				},
+			}
+			deploy := newRolloutDeployment("2")
+			if tt.stalled {
+				deploy.Status.Conditions = []appsv1.DeploymentCondition{{
+					Type:   appsv1.DeploymentProgressing,
+					Status: v1.ConditionFalse,
+					Reason: deploymentReasonProgressDeadlineExceeded,
+				}}
+			}
+
+			r := &CapsuleReconciler{
+				Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(tt.objs...).Build(),
+			}
+			reason, err := r.rollbackReason(context.Background(), capsule, deploy, current)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, reason)
+		})
+	}
+}
+
+func newTestScheme(t *testing.T) *runtime.Scheme {
+	s := runtime.NewScheme()
+	require.NoError(t, scheme.AddToScheme(s))
+	require.NoError(t, v1alpha2.AddToScheme(s))
+	return s
+}
+
+func Test_reconcileLoadBalancer_managedAnnotations(t *testing.T) {
+	t.Parallel()
+	s := newTestScheme(t)
+	capsule := &v1alpha2.Capsule{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default", UID: "capsule"},
+		Spec: v1alpha2.CapsuleSpec{
+			Interfaces: []v1alpha2.CapsuleInterface{{
+				Name: "http",
+				Port: 8080,
+				Public: &v1alpha2.CapsulePublicInterface{
+					LoadBalancer: &v1alpha2.CapsuleInterfaceLoadBalancer{
+						Port:        80,
+						Annotations: map[string]string{"lb/new": "true"},
+					},
+				},
+			}},
+		},
+	}
+
+	existing, err := createLoadBalancer(capsule, s)
+	require.NoError(t, err)
+	existing.Labels = map[string]string{"team": "web"}
+	existing.Annotations = map[string]string{
+		"lb/old":                     "true",
+		"other/set-by-user":          "true",
+		AnnotationManagedAnnotations: "lb/old",
+	}
+
+	r := &CapsuleReconciler{
+		Client:   fake.NewClientBuilder().WithScheme(s).WithObjects(existing).Build(),
+		Scheme:   s,
+		Recorder: record.NewFakeRecorder(10),
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "test"}}
+	status := &v1alpha2.CapsuleStatus{}
+	require.NoError(t, r.reconcileLoadBalancer(context.Background(), req, logr.Discard(), capsule, status))
+
+	svc := &v1.Service{}
+	require.NoError(t, r.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "test-lb"}, svc))
+	assert.Equal(t, map[string]string{
+		"lb/new":                     "true",
+		"other/set-by-user":          "true",
+		AnnotationManagedAnnotations: "lb/new",
+	}, svc.Annotations)
+	assert.Equal(t, map[string]string{"team": "web"}, svc.Labels)
+}
+
+func Test_reconcileIngress_managedAnnotations(t *testing.T) {
+	t.Parallel()
+	s := newTestScheme(t)
+	capsule := &v1alpha2.Capsule{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default", UID: "capsule"},
+		Spec: v1alpha2.CapsuleSpec{
+			Interfaces: []v1alpha2.CapsuleInterface{{
+				Name:     "grpc",
+				Port:     5000,
+				Protocol: v1alpha2.InterfaceProtocolGRPC,
+				Public: &v1alpha2.CapsulePublicInterface{
+					Ingress: &v1alpha2.CapsuleInterfaceIngress{Host: "test.example.com"},
+				},
+			}},
+		},
+	}
+	r := &CapsuleReconciler{
+		Scheme:   s,
+		Recorder: record.NewFakeRecorder(10),
+		Config: config.NewServiceFromConfig(&configv1alpha1.OperatorConfig{
+			Certmanager: &configv1alpha1.CertManagerConfig{ClusterIssuer: "letsencrypt"},
+			Ingress: configv1alpha1.IngressConfig{
+				ClassName: "nginx",
+				ProtocolAnnotations: map[string]configv1alpha1.ProtocolAnnotations{
+					"nginx": {"GRPC": {"nginx.ingress.kubernetes.io/backend-protocol": "GRPC"}},
+				},
+			},
+		}),
+	}
+
+	existing, err := r.createIngress(capsule, ingressGroups(capsule)[0], s)
+	require.NoError(t, err)
+	setManagedAnnotations(existing)
+	existing.Annotations["other/set-by-user"] = "true"
+	r.Client = fake.NewClientBuilder().WithScheme(s).WithObjects(existing).Build()
+
+	capsule.Spec.Interfaces[0].Protocol = v1alpha2.InterfaceProtocolHTTP
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "test"}}
+	status := &v1alpha2.CapsuleStatus{}
+	require.NoError(t, r.reconcileIngress(context.Background(), req, logr.Discard(), capsule, status))
+
+	ing := &netv1.Ingress{}
+	require.NoError(t, r.Get(context.Background(), req.NamespacedName, ing))
+	assert.Equal(t, map[string]string{
+		"cert-manager.io/cluster-issuer": "letsencrypt",
+		"other/set-by-user":              "true",
+		AnnotationManagedAnnotations:     "cert-manager.io/cluster-issuer",
+	}, ing.Annotations)
+}
+
+func Test_reconcileIngress_perInterface(t *testing.T) {
+	t.Parallel()
+	s := newTestScheme(t)
+	capsule := newIngressCapsule(
+		&v1alpha2.CapsuleInterfaceIngress{Host: "a.example.com"},
+		&v1alpha2.CapsuleInterfaceIngress{Host: "b.example.com"},
+	)
+	r := &CapsuleReconciler{
+		Scheme:   s,
+		Recorder: record.NewFakeRecorder(10),
+		Config: config.NewServiceFromConfig(&configv1alpha1.OperatorConfig{
+			Certmanager: &configv1alpha1.CertManagerConfig{ClusterIssuer: "letsencrypt"},
+			Ingress:     configv1alpha1.IngressConfig{ClassName: "nginx"},
+		}),
+	}
+
+	// An Ingress shared by the interfaces, created before the Ingresses were
+	// labelled.
+	shared, err := r.createIngress(capsule, ingressGroups(capsule)[0], s)
+	require.NoError(t, err)
+	shared.Labels = nil
+	r.Client = fake.NewClientBuilder().WithScheme(s).WithObjects(shared).Build()
+
+	capsule.Spec.Interfaces[1].Public.Ingress.RateLimit = &v1alpha2.IngressRateLimit{RequestsPerSecond: 10}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "test"}}
+	status := &v1alpha2.CapsuleStatus{}
+	require.NoError(t, r.reconcileIngress(context.Background(), req, logr.Discard(), capsule, status))
+
+	var ings netv1.IngressList
+	require.NoError(t, r.List(context.Background(), &ings))
+	hosts := map[string][]string{}
+	for _, ing := range ings.Items {
+		assert.Equal(t, "test", ing.Labels[LabelCapsule])
+		for _, rule := range ing.Spec.Rules {
+			hosts[ing.Name] = append(hosts[ing.Name], rule.Host)
+		}
+	}
+	assert.Equal(t, map[string][]string{
+		"test.http":  {"a.example.com"},
+		"test.admin": {"b.example.com"},
+	}, hosts)
+}
+
+func Test_certificates_capsuleIssuer(t *testing.T) {
+	t.Parallel()
+	r := &CapsuleReconciler{
+		Config: config.NewServiceFromConfig(&configv1alpha1.OperatorConfig{
+			Certmanager: &configv1alpha1.CertManagerConfig{
+				ClusterIssuer:              "letsencrypt",
+				CreateCertificateResources: true,
+				Wildcards:                  []configv1alpha1.WildcardCertificate{{Domain: "example.com"}},
+			},
+		}),
+	}
+
+	tests := []struct {
+		name     string
+		issuer   *v1alpha2.IssuerReference
+		expected []*certificate
+	}{
+		{
+			name: "wildcard",
+			expected: []*certificate{{
+				name:       "wildcard-example-com",
+				secretName: "wildcard-example-com-tls",
+				issuer:     cmmetav1.ObjectReference{Kind: cmv1.ClusterIssuerKind, Name: "letsencrypt"},
+				dnsNames:   []string{"*.example.com"},
+				hosts:      []string{"test.example.com"},
+				wildcard:   true,
+			}},
+		},
+		{
+			name:   "capsule issuer over wildcard",
+			issuer: &v1alpha2.IssuerReference{Kind: cmv1.IssuerKind, Name: "team"},
+			expected: []*certificate{{
+				name:       "test",
+				secretName: "test-tls",
+				issuer:     cmmetav1.ObjectReference{Kind: cmv1.IssuerKind, Name: "team"},
+				dnsNames:   []string{"test.example.com"},
+				hosts:      []string{"test.example.com"},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			capsule := newIngressCapsule(&v1alpha2.CapsuleInterfaceIngress{Host: "test.example.com"})
+			capsule.Spec.Issuer = tt.issuer
+			assert.Equal(t, tt.expected, r.certificates(capsule))
+		})
+	}
+}
+
+func Test_certificates_interfaceIssuer(t *testing.T) {
+	t.Parallel()
+	r := &CapsuleReconciler{
+		Config: config.NewServiceFromConfig(&configv1alpha1.OperatorConfig{
+			Certmanager: &configv1alpha1.CertManagerConfig{
+				ClusterIssuer:              "letsencrypt",
+				CreateCertificateResources: true,
+			},
+		}),
+	}
+	capsule := newIngressCapsule(
+		&v1alpha2.CapsuleInterfaceIngress{Host: "a.example.com"},
+		&v1alpha2.CapsuleInterfaceIngress{
+			Host:   "b.example.com",
+			Issuer: &v1alpha2.IssuerReference{Kind: cmv1.IssuerKind, Name: "internal"},
+		},
+	)
+
+	assert.Equal(t, []*certificate{
+		{
+			name:       "test",
+			secretName: "test-tls",
+			issuer:     cmmetav1.ObjectReference{Kind: cmv1.ClusterIssuerKind, Name: "letsencrypt"},
+			dnsNames:   []string{"a.example.com"},
+			hosts:      []string{"a.example.com"},
+		},
+		{
+			name:       "test.admin",
+			secretName: "test.admin-tls",
+			issuer:     cmmetav1.ObjectReference{Kind: cmv1.IssuerKind, Name: "internal"},
+			dnsNames:   []string{"b.example.com"},
+			hosts:      []string{"b.example.com"},
+		},
+	}, r.certificates(capsule))
+}
+
+func Test_reconcileCertificate_ingressShim(t *testing.T) {
+	t.Parallel()
+	s := newTestScheme(t)
+	require.NoError(t, cmv1.AddToScheme(s))
+
+	notAfter := metav1.NewTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	crt := &cmv1.Certificate{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-tls", Namespace: "default"},
+		Spec:       cmv1.CertificateSpec{DNSNames: []string{"test.example.com"}},
+		Status: cmv1.CertificateStatus{
+			NotAfter: &notAfter,
+			Conditions: []cmv1.CertificateCondition{{
+				Type:   cmv1.CertificateConditionReady,
+				Status: cmmetav1.ConditionTrue,
+				Reason: "Ready",
+			}},
+		},
+	}
+	r := &CapsuleReconciler{
+		Client:   fake.NewClientBuilder().WithScheme(s).WithObjects(crt).Build(),
+		Scheme:   s,
+		Recorder: record.NewFakeRecorder(10),
+		Config: config.NewServiceFromConfig(&configv1alpha1.OperatorConfig{
+			Certmanager: &configv1alpha1.CertManagerConfig{ClusterIssuer: "letsencrypt"},
+		}),
+	}
+	capsule := newIngressCapsule(&v1alpha2.CapsuleInterfaceIngress{Host: "test.example.com"})
+
+	status := &v1alpha2.CapsuleStatus{}
+	require.NoError(t, r.reconcileCertificate(context.Background(), ctrl.Request{}, logr.Discard(), capsule, status))
+	assert.Equal(t, []v1alpha2.CertificateStatus{{
+		Name:     "test-tls",
+		DNSNames: []string{"test.example.com"},
+		Ready:    "True",
+		Reason:   "Ready",
+		NotAfter: &notAfter,
+	}}, status.Certificates)
+}
+
+func Test_ingressIsSupported(t *testing.T) {
+	t.Parallel()
+	issuer := &v1alpha2.IssuerReference{Name: "team"}
+
+	tests := []struct {
+		name     string
+		cm       *configv1alpha1.CertManagerConfig
+		issuer   *v1alpha2.IssuerReference
+		expected bool
+	}{
+		{
+			name:   "no cert-manager config",
+			issuer: issuer,
+		},
+		{
+			name: "no issuer",
+			cm:   &configv1alpha1.CertManagerConfig{},
+		},
+		{
+			name:     "cluster issuer",
+			cm:       &configv1alpha1.CertManagerConfig{ClusterIssuer: "letsencrypt"},
+			expected: true,
+		},
+		{
+			name:     "capsule issuer",
+			cm:       &configv1alpha1.CertManagerConfig{},
+			issuer:   issuer,
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			r := &CapsuleReconciler{
+				Config: config.NewServiceFromConfig(&configv1alpha1.OperatorConfig{Certmanager: tt.cm}),
+			}
+			capsule := newIngressCapsule(&v1alpha2.CapsuleInterfaceIngress{Host: "test.example.com"})
+			capsule.Spec.Issuer = tt.issuer
+			assert.Equal(t, tt.expected, r.ingressIsSupported(capsule))
+		})
+	}
+}
+
+func Test_getUsedSource_missing(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name          string
+		required      bool
+		expectedState string
+		expectedEvent string
+	}{
+		{
+			name:          "optional",
+			expectedState: "missing",
+		},
+		{
+			name:          "required",
+			required:      true,
+			expectedState: "error",
+			expectedEvent: "Warning MissingConfig ConfigMap test not found",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			s := newTestScheme(t)
+			recorder := record.NewFakeRecorder(10)
+			r := &CapsuleReconciler{
+				Client:   fake.NewClientBuilder().WithScheme(s).Build(),
+				Scheme:   s,
+				Recorder: recorder,
+			}
+			capsule := &v1alpha2.Capsule{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}}
+			status := &v1alpha2.CapsuleStatus{}
+			cfgs := &configs{configMaps: map[string]*v1.ConfigMap{}, secrets: map[string]*v1.Secret{}}
+
+			err := r.getUsedSource(context.Background(), capsule, status, cfgs, "ConfigMap", "test", tt.required)
+			assert.Equal(t, tt.required, err != nil)
+			require.Len(t, status.UsedResources, 1)
+			assert.Equal(t, tt.expectedState, status.UsedResources[0].State)
+
+			close(recorder.Events)
+			var events []string
+			for e := range recorder.Events {
+				events = append(events, e)
+			}
+			if tt.expectedEvent == "" {
+				assert.Empty(t, events)
+			} else {
+				assert.Equal(t, []string{tt.expectedEvent}, events)
+			}
+		})
+	}
+}
@@ -0,0 +1,462 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	cmv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	cmmetav1 "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
+	"github.com/go-logr/logr"
+	configv1alpha1 "github.com/rigdev/rig/pkg/api/config/v1alpha1"
+	"github.com/rigdev/rig/pkg/api/v1alpha2"
+	v1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// LabelWildcardCertificate labels the wildcard Certificates shared by the
+// capsules of a namespace.
+const LabelWildcardCertificate = "rig.dev/wildcard-certificate"
+
+// certificate is a cert-manager Certificate serving ingress hosts of a
+// capsule.
+type certificate struct {
+	name       string
+	secretName string
+	issuer     cmmetav1.ObjectReference
+	dnsNames   []string
+	hosts      []string
+	// wildcard is set if the Certificate is shared by the capsules of the
+	// namespace.
+	wildcard bool
+}
+
+func issuerRef(ref *v1alpha2.IssuerReference) cmmetav1.ObjectReference {
+	return cmmetav1.ObjectReference{
+		Kind: ref.GetKind(),
+		Name: ref.Name,
+	}
+}
+
+// capsuleIssuer returns the issuer of the certificates of the capsule, which
+// is the clusterIssuer of the config unless the capsule overrides it.
+func (r *CapsuleReconciler) capsuleIssuer(capsule *v1alpha2.Capsule) cmmetav1.ObjectReference {
+	if capsule.Spec.Issuer != nil {
+		return issuerRef(capsule.Spec.Issuer)
+	}
+	ref := cmmetav1.ObjectReference{Kind: cmv1.ClusterIssuerKind}
+	if cm := r.Config.Get().Certmanager; cm != nil {
+		ref.Name = cm.ClusterIssuer
+	}
+	return ref
+}
+
+// wildcardFor returns the wildcard certificate covering the host, if any.
+func wildcardFor(
+	wildcards []configv1alpha1.WildcardCertificate,
+	host string,
+) (configv1alpha1.WildcardCertificate, bool) {
+	for _, w := range wildcards {
+		label, ok := strings.CutSuffix(host, "."+w.Domain)
+		if ok && label != "" && !strings.Contains(label, ".") {
+			return w, true
+		}
+	}
+	return configv1alpha1.WildcardCertificate{}, false
+}
+
+// wildcardCertificate returns the wildcard certificate of the domain, issued
+// by the issuer of the domain or else the clusterIssuer of the config.
+func wildcardCertificate(
+	cm *configv1alpha1.CertManagerConfig,
+	w configv1alpha1.WildcardCertificate,
+) *certificate {
+	name := fmt.Sprintf("wildcard-%s", strings.ReplaceAll(w.Domain, ".", "-"))
+	issuer := w.ClusterIssuer
+	if issuer == "" {
+		issuer = cm.ClusterIssuer
+	}
+	return &certificate{
+		name:       name,
+		secretName: fmt.Sprintf("%s-tls", name),
+		issuer:     cmmetav1.ObjectReference{Kind: cmv1.ClusterIssuerKind, Name: issuer},
+		dnsNames:   []string{"*." + w.Domain},
+		wildcard:   true,
+	}
+}
+
+// certificates returns the certificates of the ingress hosts of the capsule.
+// A host with an issuer of its own gets a certificate of its own, and, unless
+// the capsule has an issuer, a host covered by a wildcard certificate of the
+// config uses the wildcard certificate. The remaining hosts share the
+// certificate of the capsule.
+func (r *CapsuleReconciler) certificates(capsule *v1alpha2.Capsule) []*certificate {
+	cm := r.Config.Get().Certmanager
+	if cm == nil {
+		return nil
+	}
+
+	var crts []*certificate
+	byName := map[string]*certificate{}
+	add := func(crt *certificate, host string) {
+		if c, ok := byName[crt.name]; ok {
+			crt = c
+		} else {
+			byName[crt.name] = crt
+			crts = append(crts, crt)
+		}
+		crt.hosts = append(crt.hosts, host)
+		if !crt.wildcard {
+			crt.dnsNames = append(crt.dnsNames, host)
+		}
+	}
+
+	for _, inf := range capsule.Spec.Interfaces {
+		if inf.Public == nil || inf.Public.Ingress == nil {
+			continue
+		}
+		ing := inf.Public.Ingress
+
+		if cm.CreateCertificateResources && ing.Issuer != nil {
+			// Capsules with interfaces have no dots in their names, as their
+			// Services are named after them, so the name can't clash with
+			// the certificate of another capsule.
+			name := fmt.Sprintf("%s.%s", capsule.Name, inf.Name)
+			add(&certificate{
+				name:       name,
+				secretName: fmt.Sprintf("%s-tls", name),
+				issuer:     issuerRef(ing.Issuer),
+			}, ing.Host)
+			continue
+		}
+
+		// The issuer of the capsule takes precedence over the wildcard
+		// certificates of the config.
+		if w, ok := wildcardFor(cm.Wildcards, ing.Host); ok && cm.CreateCertificateResources && capsule.Spec.Issuer == nil {
+			add(wildcardCertificate(cm, w), ing.Host)
+			continue
+		}
+
+		add(&certificate{
+			name:       capsule.Name,
+			secretName: fmt.Sprintf("%s-tls", capsule.Name),
+			issuer:     r.capsuleIssuer(capsule),
+		}, ing.Host)
+	}
+	return crts
+}
+
+// setIssuerAnnotation configures the issuer of the capsule on the Ingress,
+// for cert-manager to create the certificate of the Ingress.
+func (r *CapsuleReconciler) setIssuerAnnotation(capsule *v1alpha2.Capsule, ing *netv1.Ingress) error {
+	for _, inf := range capsule.Spec.Interfaces {
+		if inf.Public != nil && inf.Public.Ingress != nil && inf.Public.Ingress.Issuer != nil {
+			return fmt.Errorf(
+				"interface %s has an issuer of its own, which requires the operator to create certificate resources",
+				inf.Name,
+			)
+		}
+	}
+
+	issuer := r.capsuleIssuer(capsule)
+	if issuer.Kind == cmv1.IssuerKind {
+		ing.Annotations["cert-manager.io/issuer"] = issuer.Name
+	} else {
+		ing.Annotations["cert-manager.io/cluster-issuer"] = issuer.Name
+	}
+	return nil
+}
+
+func (r *CapsuleReconciler) reconcileCertificate(
+	ctx context.Context,
+	_ ctrl.Request,
+	log logr.Logger,
+	capsule *v1alpha2.Capsule,
+	status *v1alpha2.CapsuleStatus,
+) error {
+	var crts, shimCrts []*certificate
+	if !capsuleHasIngress(capsule) {
+		log.V(1).Info("capsule has no ingress, no certificates needed")
+	} else if !r.ingressIsSupported(capsule) {
+		log.V(1).Info("not creating certificates as ingress is not supported: cert-manager config or issuer missing")
+	} else if !r.shouldCreateCertificateRessource() {
+		log.V(1).Info("not creating certificates as operator is configured to use ingress annotations")
+		shimCrts = r.certificates(capsule)
+	} else {
+		crts = r.certificates(capsule)
+	}
+
+	desired := map[string]struct{}{}
+	var errs []error
+	for _, c := range crts {
+		desired[c.name] = struct{}{}
+
+		crt := createCertificate(capsule.Namespace, c)
+		var err error
+		if c.wildcard {
+			crt, err = r.upsertWildcardCertificate(ctx, log, capsule, crt)
+		} else {
+			crt, err = r.upsertCertificate(ctx, log, capsule, status, crt)
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		r.recordCertificateFailure(capsule, crt)
+		status.Certificates = append(status.Certificates, certificateStatus(crt))
+	}
+
+	// The ingress-shim of cert-manager names the Certificates it creates for
+	// the Ingresses after their TLS secrets.
+	for _, c := range shimCrts {
+		crt := &cmv1.Certificate{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: capsule.Namespace, Name: c.secretName}, crt); err != nil {
+			if !kerrors.IsNotFound(err) {
+				errs = append(errs, fmt.Errorf("could not fetch certificate: %w", err))
+			}
+			continue
+		}
+
+		r.recordCertificateFailure(capsule, crt)
+		status.Certificates = append(status.Certificates, certificateStatus(crt))
+	}
+
+	errs = append(errs, r.deleteStaleCertificates(ctx, log, capsule, desired))
+	return errors.Join(errs...)
+}
+
+// upsertCertificate creates or updates a Certificate controlled by the
+// capsule, and returns the existing Certificate.
+func (r *CapsuleReconciler) upsertCertificate(
+	ctx context.Context,
+	log logr.Logger,
+	capsule *v1alpha2.Capsule,
+	status *v1alpha2.CapsuleStatus,
+	crt *cmv1.Certificate,
+) (*cmv1.Certificate, error) {
+	if err := controllerutil.SetControllerReference(capsule, crt, r.Scheme); err != nil {
+		return nil, fmt.Errorf("could not set owner reference on certificate: %w", err)
+	}
+
+	log = log.WithValues("certificate", crt.Name)
+	existingCrt := &cmv1.Certificate{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(crt), existingCrt); err != nil {
+		if !kerrors.IsNotFound(err) {
+			return nil, fmt.Errorf("could not fetch certificate: %w", err)
+		}
+
+		log.Info("creating certificate")
+		if err := r.createOwned(ctx, capsule, crt); err != nil {
+			return nil, fmt.Errorf("could not create certificate: %w", err)
+		}
+		existingCrt = crt
+	}
+
+	if !IsOwnedBy(capsule, existingCrt) {
+		log.Info("Found existing certificate not owned by capsule. Will not update it.")
+		r.recordNotOwned(capsule, existingCrt)
+		return nil, fmt.Errorf("found existing certificate %s not owned by capsule", crt.Name)
+	}
+
+	if err := upsertIfNewer(
+		ctx, r, existingCrt, crt.DeepCopy(), log, capsule, status,
+		func(t1, t2 *cmv1.Certificate) bool {
+			return equality.Semantic.DeepEqual(t1.Spec, t2.Spec)
+		},
+	); err != nil {
+		return nil, err
+	}
+	return existingCrt, nil
+}
+
+// upsertWildcardCertificate creates or updates a wildcard Certificate, and
+// adds the capsule to its owners. The Certificate is deleted by the garbage
+// collector once it has no owners left.
+func (r *CapsuleReconciler) upsertWildcardCertificate(
+	ctx context.Context,
+	log logr.Logger,
+	capsule *v1alpha2.Capsule,
+	crt *cmv1.Certificate,
+) (*cmv1.Certificate, error) {
+	existingCrt, op, err := upsertWildcardCertificate(ctx, r.Client, r.Scheme, capsule, crt)
+	if errors.Is(err, errNotWildcardCertificate) {
+		r.recordNotOwned(capsule, existingCrt)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if op != controllerutil.OperationResultNone {
+		log.Info("wildcard certificate "+string(op), "certificate", crt.Name)
+	}
+	return existingCrt, nil
+}
+
+// errNotWildcardCertificate is returned when an existing Certificate has the
+// name of a wildcard certificate, but is not a wildcard certificate.
+var errNotWildcardCertificate = errors.New("not a wildcard certificate")
+
+// upsertWildcardCertificate creates or updates a wildcard Certificate, and
+// adds the owner to its owners.
+func upsertWildcardCertificate(
+	ctx context.Context,
+	c client.Client,
+	scheme *runtime.Scheme,
+	owner client.Object,
+	crt *cmv1.Certificate,
+) (*cmv1.Certificate, controllerutil.OperationResult, error) {
+	existingCrt := &cmv1.Certificate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      crt.Name,
+			Namespace: crt.Namespace,
+		},
+	}
+	op, err := controllerutil.CreateOrUpdate(ctx, c, existingCrt, func() error {
+		if existingCrt.GetUID() != "" && existingCrt.Labels[LabelWildcardCertificate] == "" {
+			return fmt.Errorf("found existing certificate %s: %w", crt.Name, errNotWildcardCertificate)
+		}
+		if existingCrt.Labels == nil {
+			existingCrt.Labels = map[string]string{}
+		}
+		existingCrt.Labels[LabelWildcardCertificate] = crt.Labels[LabelWildcardCertificate]
+		existingCrt.Spec.SecretName = crt.Spec.SecretName
+		existingCrt.Spec.IssuerRef = crt.Spec.IssuerRef
+		existingCrt.Spec.DNSNames = crt.Spec.DNSNames
+		return controllerutil.SetOwnerReference(owner, existingCrt, scheme)
+	})
+	if err != nil {
+		return existingCrt, op, fmt.Errorf("could not update wildcard certificate: %w", err)
+	}
+	return existingCrt, op, nil
+}
+
+// withoutOwner returns the owner references of the object without those of
+// the owner, and whether the owner was one of them.
+func withoutOwner(obj metav1.Object, owner metav1.Object) ([]metav1.OwnerReference, bool) {
+	var refs []metav1.OwnerReference
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID != owner.GetUID() {
+			refs = append(refs, ref)
+		}
+	}
+	return refs, len(refs) != len(obj.GetOwnerReferences())
+}
+
+// deleteStaleCertificates deletes the Certificates controlled by the capsule
+// which are no longer desired, and removes the capsule from the owners of the
+// wildcard Certificates it no longer uses.
+func (r *CapsuleReconciler) deleteStaleCertificates(
+	ctx context.Context,
+	log logr.Logger,
+	capsule *v1alpha2.Capsule,
+	desired map[string]struct{},
+) error {
+	list := &cmv1.CertificateList{}
+	if err := r.List(ctx, list, client.InNamespace(capsule.Namespace)); err != nil {
+		return fmt.Errorf("could not list certificates: %w", err)
+	}
+
+	for i := range list.Items {
+		crt := &list.Items[i]
+		if _, ok := desired[crt.Name]; ok {
+			continue
+		}
+
+		if IsOwnedBy(capsule, crt) {
+			log.Info("deleting certificate", "certificate", crt.Name)
+			if err := r.deleteOwned(ctx, capsule, crt); err != nil {
+				return fmt.Errorf("could not delete certificate: %w", err)
+			}
+			continue
+		}
+
+		if crt.Labels[LabelWildcardCertificate] == "" {
+			continue
+		}
+		refs, ok := withoutOwner(crt, capsule)
+		if !ok {
+			continue
+		}
+
+		if len(refs) == 0 {
+			log.Info("deleting unused wildcard certificate", "certificate", crt.Name)
+			if err := r.deleteOwned(ctx, capsule, crt); err != nil && !kerrors.IsNotFound(err) {
+				return fmt.Errorf("could not delete wildcard certificate: %w", err)
+			}
+			continue
+		}
+		crt.SetOwnerReferences(refs)
+		if err := r.Update(ctx, crt); err != nil {
+			return fmt.Errorf("could not update wildcard certificate: %w", err)
+		}
+	}
+	return nil
+}
+
+// certificateStatus returns the status of the Certificate, from its Ready
+// condition.
+func certificateStatus(crt *cmv1.Certificate) v1alpha2.CertificateStatus {
+	status := v1alpha2.CertificateStatus{
+		Name:     crt.Name,
+		DNSNames: crt.Spec.DNSNames,
+		Ready:    string(cmmetav1.ConditionUnknown),
+		NotAfter: crt.Status.NotAfter,
+	}
+	for _, c := range crt.Status.Conditions {
+		if c.Type == cmv1.CertificateConditionReady {
+			status.Ready = string(c.Status)
+			status.Reason = c.Reason
+			status.Message = c.Message
+		}
+	}
+	return status
+}
+
+// recordCertificateFailure records an event on the capsule if cert-manager
+// failed to issue the certificate.
+func (r *CapsuleReconciler) recordCertificateFailure(capsule *v1alpha2.Capsule, crt *cmv1.Certificate) {
+	for _, c := range crt.Status.Conditions {
+		if c.Type == cmv1.CertificateConditionIssuing &&
+			c.Status == cmmetav1.ConditionFalse &&
+			c.Reason == cmv1.CertificateRequestReasonFailed {
+			r.Recorder.Eventf(
+				capsule, v1.EventTypeWarning, EventReasonCertificateFailed,
+				"Could not issue certificate %s: %s", crt.GetName(), c.Message,
+			)
+		}
+	}
+}
+
+func (r *CapsuleReconciler) shouldCreateCertificateRessource() bool {
+	cm := r.Config.Get().Certmanager
+	return cm != nil && cm.CreateCertificateResources
+}
+
+// createCertificate returns the Certificate of the certificate in the
+// namespace.
+func createCertificate(namespace string, c *certificate) *cmv1.Certificate {
+	crt := &cmv1.Certificate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      c.name,
+			Namespace: namespace,
+		},
+		Spec: cmv1.CertificateSpec{
+			SecretName: c.secretName,
+			IssuerRef:  c.issuer,
+			DNSNames:   c.dnsNames,
+		},
+	}
+	if c.wildcard {
+		crt.Labels = map[string]string{LabelWildcardCertificate: "true"}
+	}
+	return crt
+}
@@ -0,0 +1,274 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	promapi "github.com/prometheus/client_golang/api"
+	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	configv1alpha1 "github.com/rigdev/rig/pkg/api/config/v1alpha1"
+	"github.com/rigdev/rig/pkg/api/v1alpha2"
+	"github.com/rigdev/rig/pkg/service/config"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+const (
+	// idleCheckInterval is how often the ingress traffic of capsules with an
+	// idle timeout is queried.
+	idleCheckInterval = 5 * time.Minute
+
+	expirationReasonTTL  = "ttl"
+	expirationReasonIdle = "idle"
+
+	expirationStateActive   = "active"
+	expirationStateExpiring = "expiring"
+)
+
+// CleanupReconciler deletes capsules whose TTL has expired.
+type CleanupReconciler struct {
+	client.Client
+	Config   config.Service
+	Recorder record.EventRecorder
+
+	// prometheus is the client of the Prometheus API at prometheusURL,
+	// created once per config.
+	prometheusMu  sync.Mutex
+	prometheusURL string
+	prometheus    promv1.API
+}
+
+// prometheusAPI returns the client of the Prometheus API at the URL.
+func (r *CleanupReconciler) prometheusAPI(url string) (promv1.API, error) {
+	r.prometheusMu.Lock()
+	defer r.prometheusMu.Unlock()
+
+	if r.prometheus == nil || r.prometheusURL != url {
+		c, err := promapi.NewClient(promapi.Config{Address: url})
+		if err != nil {
+			return nil, fmt.Errorf("could not create prometheus client: %w", err)
+		}
+		r.prometheusURL = url
+		r.prometheus = promv1.NewAPI(c)
+	}
+	return r.prometheus, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *CleanupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	hasTTL := predicate.NewPredicateFuncs(func(o client.Object) bool {
+		return o.(*v1alpha2.Capsule).Spec.TTL != nil
+	})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("capsule-cleanup").
+		For(&v1alpha2.Capsule{}, builder.WithPredicates(
+			hasTTL,
+			// The status of capsules is updated by the capsule controller.
+			predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{}),
+		)).
+		Complete(r)
+}
+
+// Reconcile deletes the Capsule if it has expired. Otherwise the expiration
+// of the Capsule is recorded in its status, and it is reconciled again when
+// it expires or should be reported as expiring.
+func (r *CleanupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	capsule := &v1alpha2.Capsule{}
+	if err := r.Get(ctx, req.NamespacedName, capsule); err != nil {
+		if kerrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, fmt.Errorf("could not fetch Capsule: %w", err)
+	}
+	if capsule.Spec.TTL == nil || !capsule.GetDeletionTimestamp().IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	cfg := r.Config.Get().Cleanup
+	// Status times have second precision.
+	now := time.Now().Truncate(time.Second)
+
+	var previous *v1alpha2.ExpirationStatus
+	if capsule.Status != nil {
+		previous = capsule.Status.Expiration
+	}
+	expiration, err := r.getExpiration(ctx, cfg, capsule, previous, now)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if expiration == nil {
+		r.Recorder.Event(capsule, v1.EventTypeWarning, EventReasonIdleTimeoutNotEnforced,
+			"The idle timeout is not enforced, as the operator has no Prometheus to query the traffic")
+		return ctrl.Result{}, nil
+	}
+
+	expiresAt := expiration.ExpiresAt.Time
+	if !now.Before(expiresAt) {
+		log.Info("deleting expired capsule", "reason", expiration.Reason, "expiresAt", expiresAt)
+		r.Recorder.Eventf(capsule, v1.EventTypeNormal, EventReasonExpired, "Capsule expired at %s, deleting it",
+			expiresAt.Format(time.RFC3339))
+		if err := r.Delete(
+			ctx, capsule, client.PropagationPolicy(metav1.DeletePropagationForeground),
+		); client.IgnoreNotFound(err) != nil {
+			return ctrl.Result{}, fmt.Errorf("could not delete expired capsule: %w", err)
+		}
+		return ctrl.Result{}, nil
+	}
+
+	requeueAt := expiresAt
+	if expiration.LastActivity != nil && now.Add(idleCheckInterval).Before(requeueAt) {
+		requeueAt = now.Add(idleCheckInterval)
+	}
+
+	var warningPeriod time.Duration
+	if cfg.WarningPeriod != nil {
+		warningPeriod = cfg.WarningPeriod.Duration
+	}
+	if warnAt := expiresAt.Add(-warningPeriod); now.Before(warnAt) {
+		expiration.State = expirationStateActive
+		if warnAt.Before(requeueAt) {
+			requeueAt = warnAt
+		}
+	} else {
+		expiration.State = expirationStateExpiring
+		if previous == nil || previous.State != expirationStateExpiring {
+			r.Recorder.Eventf(capsule, v1.EventTypeWarning, EventReasonExpiring,
+				"Capsule expires at %s, unless its lease is extended with the %s annotation",
+				expiresAt.Format(time.RFC3339), v1alpha2.AnnotationLeaseExtendedUntil)
+		}
+	}
+
+	if !equality.Semantic.DeepEqual(previous, expiration) {
+		orig := capsule.DeepCopy()
+		if capsule.Status == nil {
+			capsule.Status = &v1alpha2.CapsuleStatus{}
+		}
+		capsule.Status.Expiration = expiration
+		if err := r.Status().Patch(ctx, capsule, client.MergeFrom(orig)); err != nil {
+			return ctrl.Result{}, fmt.Errorf("could not update expiration status: %w", err)
+		}
+	}
+
+	return ctrl.Result{RequeueAfter: requeueAt.Sub(now)}, nil
+}
+
+// getExpiration returns when the capsule expires. It returns nil if the
+// capsule only has an idle timeout, and no Prometheus is configured.
+func (r *CleanupReconciler) getExpiration(
+	ctx context.Context,
+	cfg configv1alpha1.CleanupConfig,
+	capsule *v1alpha2.Capsule,
+	previous *v1alpha2.ExpirationStatus,
+	now time.Time,
+) (*v1alpha2.ExpirationStatus, error) {
+	ttl := capsule.Spec.TTL
+
+	var expiration *v1alpha2.ExpirationStatus
+	if ttl.ExpiresAt != nil {
+		expiration = &v1alpha2.ExpirationStatus{
+			ExpiresAt: *ttl.ExpiresAt,
+			Reason:    expirationReasonTTL,
+		}
+	}
+
+	if ttl.IdleTimeout != nil && cfg.PrometheusURL != "" {
+		lastActivity := capsule.GetCreationTimestamp().Time
+		if previous != nil && previous.LastActivity != nil && previous.LastActivity.After(lastActivity) {
+			lastActivity = previous.LastActivity.Time
+		}
+
+		api, err := r.prometheusAPI(cfg.PrometheusURL)
+		if err != nil {
+			return nil, err
+		}
+		active, err := hasTraffic(ctx, api, cfg, capsule, now.Sub(lastActivity))
+		if err != nil {
+			return nil, err
+		}
+		if active {
+			lastActivity = now
+		}
+
+		idleAt := lastActivity.Add(ttl.IdleTimeout.Duration)
+		if expiration == nil || idleAt.Before(expiration.ExpiresAt.Time) {
+			expiration = &v1alpha2.ExpirationStatus{
+				ExpiresAt: metav1.NewTime(idleAt),
+				Reason:    expirationReasonIdle,
+			}
+		}
+		expiration.LastActivity = &metav1.Time{Time: lastActivity}
+	}
+
+	if expiration == nil {
+		return nil, nil
+	}
+
+	// The lease is validated by the webhook.
+	if a, ok := capsule.GetAnnotations()[v1alpha2.AnnotationLeaseExtendedUntil]; ok {
+		if lease, err := time.Parse(time.RFC3339, a); err == nil && lease.After(expiration.ExpiresAt.Time) {
+			expiration.ExpiresAt = metav1.NewTime(lease)
+		}
+	}
+
+	return expiration, nil
+}
+
+// hasTraffic returns true if the capsule received ingress traffic within
+// the window.
+func hasTraffic(
+	ctx context.Context,
+	api promv1.API,
+	cfg configv1alpha1.CleanupConfig,
+	capsule *v1alpha2.Capsule,
+	window time.Duration,
+) (bool, error) {
+	if window < time.Minute {
+		window = time.Minute
+	}
+
+	tmpl, err := template.New("query").Parse(cfg.TrafficQuery)
+	if err != nil {
+		return false, fmt.Errorf("invalid traffic query: %w", err)
+	}
+	var query strings.Builder
+	if err := tmpl.Execute(&query, map[string]string{
+		"Namespace": capsule.Namespace,
+		"Name":      capsule.Name,
+		"Window":    fmt.Sprintf("%ds", int64(window.Seconds())),
+	}); err != nil {
+		return false, fmt.Errorf("invalid traffic query: %w", err)
+	}
+
+	res, _, err := api.Query(ctx, query.String(), time.Now())
+	if err != nil {
+		return false, fmt.Errorf("could not query traffic of capsule: %w", err)
+	}
+
+	switch v := res.(type) {
+	case model.Vector:
+		for _, s := range v {
+			if s.Value > 0 {
+				return true, nil
+			}
+		}
+	case *model.Scalar:
+		return v.Value > 0, nil
+	}
+	return false, nil
+}
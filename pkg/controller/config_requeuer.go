@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	configv1alpha1 "github.com/rigdev/rig/pkg/api/config/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+// configRequeuer requeues all objects of a kind, e.g. capsules, when the
+// operator config is reloaded with changes which affect how they are
+// rendered. It only runs on the leader, while config changes are recorded on
+// all instances.
+type configRequeuer struct {
+	client  client.Client
+	log     logr.Logger
+	newList func() client.ObjectList
+	changed chan struct{}
+	events  chan event.GenericEvent
+}
+
+func newConfigRequeuer(c client.Client, log logr.Logger, newList func() client.ObjectList) *configRequeuer {
+	return &configRequeuer{
+		client:  c,
+		log:     log.WithName("configRequeuer"),
+		newList: newList,
+		changed: make(chan struct{}, 1),
+		events:  make(chan event.GenericEvent),
+	}
+}
+
+// onConfigChange records that the config has changed. It never blocks, and
+// multiple changes are coalesced into a single requeue.
+func (q *configRequeuer) onConfigChange(oldCfg, newCfg *configv1alpha1.OperatorConfig) {
+	if !affectsRendering(oldCfg, newCfg) {
+		return
+	}
+	select {
+	case q.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Start implements manager.Runnable.
+func (q *configRequeuer) Start(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-q.changed:
+		}
+
+		list := q.newList()
+		if err := q.client.List(ctx, list); err != nil {
+			q.log.Error(err, "could not list objects to requeue after config change")
+			continue
+		}
+		objs, err := meta.ExtractList(list)
+		if err != nil {
+			q.log.Error(err, "could not extract objects to requeue after config change")
+			continue
+		}
+
+		q.log.Info("requeueing objects after config change", "objects", len(objs))
+		for _, o := range objs {
+			obj, ok := o.(client.Object)
+			if !ok {
+				continue
+			}
+			select {
+			case q.events <- event.GenericEvent{Object: obj}:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+}
+
+// logRestartRequired returns a config change handler which logs if the
+// changes require a restart of the operator to take effect.
+func logRestartRequired(log logr.Logger) func(oldCfg, newCfg *configv1alpha1.OperatorConfig) {
+	return func(oldCfg, newCfg *configv1alpha1.OperatorConfig) {
+		if requiresRestart(oldCfg, newCfg) {
+			log.Info("config changes to webhooks, dev mode or leader election require a restart of the operator")
+		}
+	}
+}
+
+// affectsRendering returns true if the configs differ in fields used when
+// rendering the resources of capsules.
+func affectsRendering(oldCfg, newCfg *configv1alpha1.OperatorConfig) bool {
+	o, n := oldCfg.DeepCopy(), newCfg.DeepCopy()
+	o.WebhooksEnabled, n.WebhooksEnabled = nil, nil
+	o.DevModeEnabled, n.DevModeEnabled = false, false
+	o.LeaderElectionEnabled, n.LeaderElectionEnabled = nil, nil
+	return !equality.Semantic.DeepEqual(o, n)
+}
+
+// requiresRestart returns true if the configs differ in fields which are only
+// read when the operator starts.
+func requiresRestart(oldCfg, newCfg *configv1alpha1.OperatorConfig) bool {
+	return !equality.Semantic.DeepEqual(oldCfg.WebhooksEnabled, newCfg.WebhooksEnabled) ||
+		oldCfg.DevModeEnabled != newCfg.DevModeEnabled ||
+		!equality.Semantic.DeepEqual(oldCfg.LeaderElectionEnabled, newCfg.LeaderElectionEnabled)
+}
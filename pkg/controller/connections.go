@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strconv"
+
+	"github.com/rigdev/rig/pkg/api/v1alpha2"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const fieldConnectionsCapsule = ".spec.connections.capsule"
+
+func indexConnectionsCapsule(o client.Object) []string {
+	capsule := o.(*v1alpha2.Capsule)
+	var names []string
+	for _, c := range capsule.Spec.Connections {
+		names = append(names, c.Capsule)
+	}
+	return names
+}
+
+// getConnectionEnv resolves the connections of the capsule from the Services
+// of the capsules connected to, and returns their environment variables. A
+// missing Service or interface is reported as a missing used resource, and
+// its connection is left out.
+func (r *CapsuleReconciler) getConnectionEnv(
+	ctx context.Context,
+	capsule *v1alpha2.Capsule,
+	status *v1alpha2.CapsuleStatus,
+) ([]v1.EnvVar, error) {
+	var env []v1.EnvVar
+	for _, c := range capsule.Spec.Connections {
+		ref := v1alpha2.UsedResource{
+			Ref: &v1.TypedLocalObjectReference{
+				Kind: "Service",
+				Name: c.Capsule,
+			},
+			State: "found",
+		}
+
+		svc := &v1.Service{}
+		err := r.Get(ctx, types.NamespacedName{Namespace: capsule.Namespace, Name: c.Capsule}, svc)
+		if kerrors.IsNotFound(err) {
+			ref.State = "missing"
+			ref.Message = fmt.Sprintf("capsule %s has no service", c.Capsule)
+		} else if err != nil {
+			ref.State = "error"
+			ref.Message = err.Error()
+			status.UsedResources = append(status.UsedResources, ref)
+			r.Recorder.Eventf(
+				capsule, v1.EventTypeWarning, EventReasonFailed, "Could not get Service %s: %v", c.Capsule, err,
+			)
+			return nil, fmt.Errorf("could not get service of connection to %s: %w", c.Capsule, err)
+		}
+
+		var port *v1.ServicePort
+		if ref.State == "found" {
+			for _, p := range svc.Spec.Ports {
+				if p.Name == c.Interface {
+					p := p
+					port = &p
+					break
+				}
+			}
+			if port == nil {
+				ref.State = "missing"
+				ref.Message = fmt.Sprintf("capsule %s has no interface %s", c.Capsule, c.Interface)
+			}
+		}
+
+		status.UsedResources = append(status.UsedResources, ref)
+		if ref.State == "missing" {
+			r.Recorder.Eventf(capsule, v1.EventTypeWarning, EventReasonMissingConnection, "Connection %s: %s",
+				c.EnvPrefix(), ref.Message)
+			continue
+		}
+
+		scheme := c.Scheme
+		if scheme == "" {
+			scheme = "http"
+		}
+		host := fmt.Sprintf("%s.%s.svc", svc.Name, svc.Namespace)
+		prefix := c.EnvPrefix()
+		env = append(env,
+			v1.EnvVar{Name: prefix + "_HOST", Value: host},
+			v1.EnvVar{Name: prefix + "_PORT", Value: strconv.Itoa(int(port.Port))},
+			v1.EnvVar{Name: prefix + "_URL", Value: fmt.Sprintf("%s://%s:%d", scheme, host, port.Port)},
+		)
+	}
+	return env, nil
+}
+
+// connectionEnv returns the environment variables of the connections of the
+// capsule. Variables already provided by the env sources of the capsule are
+// not overwritten.
+func connectionEnv(configs *configs, envFrom []v1.EnvFromSource) []v1.EnvVar {
+	if len(configs.connectionEnv) == 0 {
+		return nil
+	}
+
+	provided := envFromKeys(envFrom, configs)
+	var res []v1.EnvVar
+	for _, e := range configs.connectionEnv {
+		if _, ok := provided[e.Name]; !ok {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+// connectionsChecksum returns a checksum of the resolved connections, so the
+// instances are restarted when the address of a connection changes.
+func connectionsChecksum(env []v1.EnvVar) string {
+	if len(env) == 0 {
+		return ""
+	}
+
+	h := sha256.New()
+	for _, e := range env {
+		fmt.Fprintf(h, "%s=%s\n", e.Name, e.Value)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
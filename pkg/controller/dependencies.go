@@ -0,0 +1,230 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/rigdev/rig/pkg/api/v1alpha2"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+)
+
+const (
+	// AnnotationAwaitingDependencies is set on a Deployment which is held at
+	// zero replicas until the dependencies of its capsule are ready.
+	AnnotationAwaitingDependencies = "rig.dev/awaiting-dependencies"
+
+	fieldDependsOnCapsule = ".spec.dependsOn.capsule"
+
+	dependenciesStateWaiting = "waiting"
+	dependenciesStateReady   = "ready"
+)
+
+func indexDependsOnCapsule(o client.Object) []string {
+	capsule := o.(*v1alpha2.Capsule)
+	var names []string
+	for _, d := range capsule.Spec.DependsOn {
+		names = append(names, d.Capsule)
+	}
+	return names
+}
+
+// findCapsulesReferencing returns the capsules which reference the capsule
+// of an object by the given field, so they are reconciled when the object
+// changes.
+func findCapsulesReferencing(mgr ctrl.Manager, fieldName string) handler.MapFunc {
+	log := mgr.GetLogger().WithName("capsuleReferenceEventHandler")
+	c := mgr.GetClient()
+
+	return func(ctx context.Context, o client.Object) []ctrl.Request {
+		var capsules v1alpha2.CapsuleList
+		if err := c.List(ctx, &capsules, &client.ListOptions{
+			Namespace:     o.GetNamespace(),
+			FieldSelector: fields.SelectorFromSet(fields.Set{fieldName: o.GetName()}),
+		}); err != nil {
+			log.Error(err, "could not list capsules referencing capsule", "field", fieldName, "capsule", o.GetName())
+			return nil
+		}
+
+		requests := make([]ctrl.Request, len(capsules.Items))
+		for i, capsule := range capsules.Items {
+			requests[i] = ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&capsule)}
+		}
+		return requests
+	}
+}
+
+func (r *CapsuleReconciler) reconcileDependencies(
+	ctx context.Context,
+	_ ctrl.Request,
+	log logr.Logger,
+	capsule *v1alpha2.Capsule,
+	status *v1alpha2.CapsuleStatus,
+) error {
+	if len(capsule.Spec.DependsOn) == 0 {
+		return nil
+	}
+
+	// Capsules depending on each other would wait for each other forever.
+	cycle, err := r.dependencyCycle(ctx, capsule)
+	if err != nil {
+		return err
+	}
+	if len(cycle) > 0 {
+		msg := fmt.Sprintf("dependency cycle %s", strings.Join(cycle, " -> "))
+		log.Info("waiting for dependency cycle to be broken", "cycle", cycle)
+		r.Recorder.Eventf(capsule, v1.EventTypeWarning, EventReasonDependencyCycle, "Found %s", msg)
+		for _, d := range capsule.Spec.DependsOn {
+			if d.Capsule == cycle[1] {
+				d := d
+				status.Dependencies = &v1alpha2.DependenciesStatus{
+					State:    dependenciesStateWaiting,
+					Blocking: &d,
+					Message:  msg,
+				}
+				break
+			}
+		}
+		return nil
+	}
+
+	for _, d := range capsule.Spec.DependsOn {
+		msg, err := r.dependencyNotReadyReason(ctx, capsule.Namespace, d)
+		if err != nil {
+			return err
+		}
+		if msg != "" {
+			log.Info("waiting for dependency", "dependency", d.Capsule, "reason", msg)
+			d := d
+			status.Dependencies = &v1alpha2.DependenciesStatus{
+				State:    dependenciesStateWaiting,
+				Blocking: &d,
+				Message:  msg,
+			}
+			return nil
+		}
+	}
+
+	status.Dependencies = &v1alpha2.DependenciesStatus{
+		State: dependenciesStateReady,
+	}
+	return nil
+}
+
+// dependencyCycle returns the names of a cycle of dependencies through the
+// capsule, starting and ending with the capsule, or nil if the dependencies of
+// the capsule don't lead back to it. The dependencies of the other capsules of
+// the namespace include those of their templates.
+func (r *CapsuleReconciler) dependencyCycle(ctx context.Context, capsule *v1alpha2.Capsule) ([]string, error) {
+	var capsules v1alpha2.CapsuleList
+	if err := r.List(ctx, &capsules, client.InNamespace(capsule.Namespace)); err != nil {
+		return nil, fmt.Errorf("could not list capsules: %w", err)
+	}
+
+	dependsOn := map[string][]v1alpha2.CapsuleDependency{}
+	for i := range capsules.Items {
+		c := &capsules.Items[i]
+		if c.Spec.Template != nil {
+			t, err := c.GetTemplate(ctx, r)
+			if err != nil && !kerrors.IsNotFound(err) {
+				return nil, fmt.Errorf("could not get template of capsule %s: %w", c.Name, err)
+			}
+			if err == nil {
+				c = c.WithTemplate(t)
+			}
+		}
+		dependsOn[c.Name] = c.Spec.DependsOn
+	}
+	dependsOn[capsule.Name] = capsule.Spec.DependsOn
+
+	visited := map[string]struct{}{}
+	var path []string
+	var visit func(name string) bool
+	visit = func(name string) bool {
+		path = append(path, name)
+		for _, d := range dependsOn[name] {
+			if d.Capsule == capsule.Name {
+				path = append(path, d.Capsule)
+				return true
+			}
+			if _, ok := visited[d.Capsule]; ok {
+				continue
+			}
+			visited[d.Capsule] = struct{}{}
+			if visit(d.Capsule) {
+				return true
+			}
+		}
+		path = path[:len(path)-1]
+		return false
+	}
+
+	if visit(capsule.Name) {
+		return path, nil
+	}
+	return nil, nil
+}
+
+// dependencyNotReadyReason returns why the dependency is not ready, or an
+// empty string if it is. A dependency is ready when its Deployment has an
+// available instance, which for an interface means it passes the readiness
+// probe of the interface.
+func (r *CapsuleReconciler) dependencyNotReadyReason(
+	ctx context.Context,
+	namespace string,
+	d v1alpha2.CapsuleDependency,
+) (string, error) {
+	key := types.NamespacedName{Namespace: namespace, Name: d.Capsule}
+
+	dep := &v1alpha2.Capsule{}
+	if err := r.Get(ctx, key, dep); err != nil {
+		if kerrors.IsNotFound(err) {
+			return fmt.Sprintf("capsule %s not found", d.Capsule), nil
+		}
+		return "", fmt.Errorf("could not fetch dependency %s: %w", d.Capsule, err)
+	}
+
+	if d.Interface != "" {
+		found := false
+		for _, i := range dep.Spec.Interfaces {
+			if i.Name == d.Interface {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Sprintf("capsule %s has no interface %s", d.Capsule, d.Interface), nil
+		}
+	}
+
+	deploy := &appsv1.Deployment{}
+	if err := r.Get(ctx, key, deploy); err != nil {
+		if kerrors.IsNotFound(err) {
+			return fmt.Sprintf("capsule %s has no deployment", d.Capsule), nil
+		}
+		return "", fmt.Errorf("could not fetch deployment of dependency %s: %w", d.Capsule, err)
+	}
+	if deploy.Status.AvailableReplicas == 0 {
+		return fmt.Sprintf("capsule %s has no available instances", d.Capsule), nil
+	}
+
+	return "", nil
+}
+
+// awaitingDependencies returns true if the capsule is waiting for its
+// dependencies.
+func awaitingDependencies(status *v1alpha2.CapsuleStatus) bool {
+	return status.Dependencies != nil && status.Dependencies.State == dependenciesStateWaiting
+}
+
+func isAwaitingDependencies(deploy *appsv1.Deployment) bool {
+	return deploy.GetAnnotations()[AnnotationAwaitingDependencies] == "true"
+}
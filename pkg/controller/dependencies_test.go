@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/rigdev/rig/pkg/api/v1alpha2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_reconcileDependencies_cycle(t *testing.T) {
+	t.Parallel()
+	newCapsule := func(name string, dependsOn ...string) *v1alpha2.Capsule {
+		c := &v1alpha2.Capsule{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+		for _, d := range dependsOn {
+			c.Spec.DependsOn = append(c.Spec.DependsOn, v1alpha2.CapsuleDependency{Capsule: d})
+		}
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		capsule  *v1alpha2.Capsule
+		objs     []client.Object
+		expected *v1alpha2.DependenciesStatus
+	}{
+		{
+			name:    "no cycle",
+			capsule: newCapsule("a", "b"),
+			objs:    []client.Object{newCapsule("b", "c"), newCapsule("c")},
+			expected: &v1alpha2.DependenciesStatus{
+				State:    dependenciesStateWaiting,
+				Blocking: &v1alpha2.CapsuleDependency{Capsule: "b"},
+				Message:  "capsule b has no deployment",
+			},
+		},
+		{
+			name:    "cycle",
+			capsule: newCapsule("a", "c", "b"),
+			objs:    []client.Object{newCapsule("b", "c", "d"), newCapsule("c"), newCapsule("d", "a")},
+			expected: &v1alpha2.DependenciesStatus{
+				State:    dependenciesStateWaiting,
+				Blocking: &v1alpha2.CapsuleDependency{Capsule: "b"},
+				Message:  "dependency cycle a -> b -> d -> a",
+			},
+		},
+		{
+			name:    "cycle not through the capsule",
+			capsule: newCapsule("a", "b"),
+			objs:    []client.Object{newCapsule("b", "c"), newCapsule("c", "b")},
+			expected: &v1alpha2.DependenciesStatus{
+				State:    dependenciesStateWaiting,
+				Blocking: &v1alpha2.CapsuleDependency{Capsule: "b"},
+				Message:  "capsule b has no deployment",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			s := newTestScheme(t)
+			r := &CapsuleReconciler{
+				Client:   fake.NewClientBuilder().WithScheme(s).WithObjects(append(tt.objs, tt.capsule)...).Build(),
+				Scheme:   s,
+				Recorder: record.NewFakeRecorder(10),
+			}
+			status := &v1alpha2.CapsuleStatus{}
+			require.NoError(t, r.reconcileDependencies(
+				context.Background(), ctrl.Request{}, logr.Discard(), tt.capsule, status,
+			))
+			assert.Equal(t, tt.expected, status.Dependencies)
+		})
+	}
+}
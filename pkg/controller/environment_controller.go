@@ -0,0 +1,444 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/rigdev/rig/pkg/api/v1alpha2"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+const (
+	// LabelEnvironment is the label of resources created for an environment,
+	// holding the name of the environment.
+	LabelEnvironment = "rig.dev/environment"
+	// LabelImagePullSecret is the label of secrets which are used by all
+	// capsules in their namespace to pull images.
+	LabelImagePullSecret = "rig.dev/image-pull-secret"
+
+	fieldImagePullSecretsFrom = ".spec.imagePullSecrets.from"
+)
+
+// EnvironmentReconciler reconciles an Environment object
+type EnvironmentReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *EnvironmentReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(
+		context.Background(),
+		&v1alpha2.Environment{},
+		fieldImagePullSecretsFrom,
+		indexImagePullSecretsFrom,
+	); err != nil {
+		return fmt.Errorf("could not setup indexer for %s: %w", fieldImagePullSecretsFrom, err)
+	}
+
+	// Usage of the environment changes with the capsules and deployments of
+	// its namespace.
+	inEnvironmentNamespace := builder.WithPredicates(predicate.NewPredicateFuncs(func(o client.Object) bool {
+		return r.environmentOfNamespace(context.Background(), o.GetNamespace()) != ""
+	}))
+	namespaceHandler := handler.EnqueueRequestsFromMapFunc(r.findEnvironmentsForNamespace)
+
+	// Rotated image pull secrets are copied into the namespaces again.
+	isImagePullSecret := builder.WithPredicates(predicate.NewPredicateFuncs(func(o client.Object) bool {
+		envs, err := r.listEnvironmentsForImagePullSecret(context.Background(), o)
+		return err == nil && len(envs.Items) > 0
+	}))
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha2.Environment{}).
+		Owns(&v1.Namespace{}).
+		Owns(&v1.ResourceQuota{}).
+		Owns(&v1.LimitRange{}).
+		Owns(&v1.ConfigMap{}).
+		Owns(&v1.Secret{}).
+		Watches(&v1alpha2.Capsule{}, namespaceHandler, inEnvironmentNamespace).
+		Watches(&appsv1.Deployment{}, namespaceHandler, inEnvironmentNamespace).
+		Watches(&v1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.findEnvironmentsForImagePullSecret),
+			isImagePullSecret).
+		Complete(r)
+}
+
+// indexImagePullSecretsFrom indexes environments by the <namespace>/<name>
+// of the secrets they copy into their namespace.
+func indexImagePullSecretsFrom(o client.Object) []string {
+	env := o.(*v1alpha2.Environment)
+	var ss []string
+	for _, ps := range env.Spec.ImagePullSecrets {
+		ss = append(ss, types.NamespacedName{Namespace: ps.From.Namespace, Name: ps.From.Name}.String())
+	}
+	return ss
+}
+
+// environmentOfNamespace returns the name of the environment the namespace
+// was created for, which it is labelled with, or "" if none.
+func (r *EnvironmentReconciler) environmentOfNamespace(ctx context.Context, namespace string) string {
+	ns := &v1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: namespace}, ns); err != nil {
+		return ""
+	}
+	return ns.GetLabels()[LabelEnvironment]
+}
+
+func (r *EnvironmentReconciler) findEnvironmentsForNamespace(ctx context.Context, o client.Object) []ctrl.Request {
+	env := r.environmentOfNamespace(ctx, o.GetNamespace())
+	if env == "" {
+		return nil
+	}
+	return []ctrl.Request{{NamespacedName: types.NamespacedName{Name: env}}}
+}
+
+// listEnvironmentsForImagePullSecret lists the environments copying the
+// secret into their namespace.
+func (r *EnvironmentReconciler) listEnvironmentsForImagePullSecret(
+	ctx context.Context,
+	o client.Object,
+) (*v1alpha2.EnvironmentList, error) {
+	envs := &v1alpha2.EnvironmentList{}
+	if err := r.List(ctx, envs, client.MatchingFields{
+		fieldImagePullSecretsFrom: client.ObjectKeyFromObject(o).String(),
+	}); err != nil {
+		return nil, err
+	}
+	return envs, nil
+}
+
+// findEnvironmentsForImagePullSecret returns the environments copying the
+// secret into their namespace.
+func (r *EnvironmentReconciler) findEnvironmentsForImagePullSecret(
+	ctx context.Context,
+	o client.Object,
+) []ctrl.Request {
+	envs, err := r.listEnvironmentsForImagePullSecret(ctx, o)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "could not list environments")
+		return nil
+	}
+
+	requests := make([]ctrl.Request, len(envs.Items))
+	for i, env := range envs.Items {
+		requests[i] = ctrl.Request{NamespacedName: types.NamespacedName{Name: env.Name}}
+	}
+	return requests
+}
+
+//+kubebuilder:rbac:groups=rig.dev,resources=environments,verbs=get;list;watch
+//+kubebuilder:rbac:groups=rig.dev,resources=environments/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups="",resources=namespaces;resourcequotas;limitranges,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=configmaps;secrets,verbs=create;update;patch;delete
+
+// Reconcile creates the namespace of the Environment and the resources
+// configuring it, and updates the status with the usage of the capsules in
+// the namespace.
+func (r *EnvironmentReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	log.Info("reconciliation started")
+
+	env := &v1alpha2.Environment{}
+	if err := r.Get(ctx, req.NamespacedName, env); err != nil {
+		if kerrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, fmt.Errorf("could not fetch Environment: %w", err)
+	}
+
+	status := &v1alpha2.EnvironmentStatus{
+		Namespace: env.NamespaceName(),
+		State:     "ready",
+	}
+
+	err := r.reconcileNamespace(ctx, env, status)
+	if err == nil {
+		// The resources of the namespace can't be created without it.
+		err = errors.Join(
+			r.reconcileResourceQuota(ctx, env, status),
+			r.reconcileLimitRange(ctx, env, status),
+			r.reconcileSharedConfig(ctx, env, status),
+			r.reconcileImagePullSecrets(ctx, env, status),
+			r.reconcileUsage(ctx, env, status),
+		)
+	}
+
+	if err != nil {
+		status.State = "failed"
+		status.Message = err.Error()
+	} else {
+		status.ObservedGeneration = env.GetGeneration()
+	}
+
+	env.Status = status
+	if err := r.Status().Update(ctx, env); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, err
+}
+
+// upsert creates or updates an object owned by the environment. mutate sets
+// the desired state on the object, which holds the existing state if the
+// object exists. An existing object not owned by the environment is not
+// updated.
+func (r *EnvironmentReconciler) upsert(
+	ctx context.Context,
+	env *v1alpha2.Environment,
+	status *v1alpha2.EnvironmentStatus,
+	obj client.Object,
+	mutate func(),
+) error {
+	return upsertOwned(ctx, r.Client, r.Scheme, env, LabelEnvironment, &status.OwnedResources, obj, mutate)
+}
+
+// deleteStale deletes the objects of the list which were created for the
+// environment, but are no longer desired.
+func (r *EnvironmentReconciler) deleteStale(
+	ctx context.Context,
+	env *v1alpha2.Environment,
+	list client.ObjectList,
+	desired map[string]struct{},
+) error {
+	return deleteStaleOwned(ctx, r.Client, env, LabelEnvironment, env.NamespaceName(), list, desired)
+}
+
+func (r *EnvironmentReconciler) reconcileNamespace(
+	ctx context.Context,
+	env *v1alpha2.Environment,
+	status *v1alpha2.EnvironmentStatus,
+) error {
+	ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: env.NamespaceName()}}
+	setLabels := func() {
+		l := ns.GetLabels()
+		if l == nil {
+			l = map[string]string{}
+		}
+		for k, v := range env.Spec.NamespaceLabels {
+			l[k] = v
+		}
+		ns.SetLabels(l)
+	}
+	if env.Spec.NamespaceDeletionPolicy != v1alpha2.NamespaceDeletionPolicyRetain {
+		return r.upsert(ctx, env, status, ns, setLabels)
+	}
+
+	// A retained namespace is only labelled with the environment, so it is
+	// not deleted with it. It was created for the environment if it has the
+	// label or is still owned by the environment.
+	res := v1alpha2.OwnedResource{
+		Ref: &v1.TypedLocalObjectReference{
+			Kind: "Namespace",
+			Name: ns.Name,
+		},
+		State: "created",
+	}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, ns, func() error {
+		if ns.GetUID() != "" && !IsOwnedBy(env, ns) && ns.GetLabels()[LabelEnvironment] != env.Name {
+			return fmt.Errorf("found existing namespace %s not owned by environment %s", ns.Name, env.Name)
+		}
+		setLabels()
+		ns.Labels[LabelEnvironment] = env.Name
+		refs := slices.DeleteFunc(ns.GetOwnerReferences(), func(ref metav1.OwnerReference) bool {
+			return ref.UID == env.GetUID()
+		})
+		ns.SetOwnerReferences(refs)
+		return nil
+	})
+	if err != nil {
+		res.State = "failed"
+		res.Message = err.Error()
+	}
+	status.OwnedResources = append(status.OwnedResources, res)
+	return err
+}
+
+func (r *EnvironmentReconciler) reconcileResourceQuota(
+	ctx context.Context,
+	env *v1alpha2.Environment,
+	status *v1alpha2.EnvironmentStatus,
+) error {
+	quota := &v1.ResourceQuota{ObjectMeta: metav1.ObjectMeta{
+		Name:      env.Name,
+		Namespace: env.NamespaceName(),
+	}}
+	if len(env.Spec.Quota) == 0 {
+		return r.deleteStale(ctx, env, &v1.ResourceQuotaList{}, nil)
+	}
+	return r.upsert(ctx, env, status, quota, func() {
+		quota.Spec.Hard = env.Spec.Quota
+	})
+}
+
+func (r *EnvironmentReconciler) reconcileLimitRange(
+	ctx context.Context,
+	env *v1alpha2.Environment,
+	status *v1alpha2.EnvironmentStatus,
+) error {
+	lr := &v1.LimitRange{ObjectMeta: metav1.ObjectMeta{
+		Name:      env.Name,
+		Namespace: env.NamespaceName(),
+	}}
+	if len(env.Spec.LimitRange) == 0 {
+		return r.deleteStale(ctx, env, &v1.LimitRangeList{}, nil)
+	}
+	return r.upsert(ctx, env, status, lr, func() {
+		lr.Spec.Limits = env.Spec.LimitRange
+	})
+}
+
+func (r *EnvironmentReconciler) reconcileSharedConfig(
+	ctx context.Context,
+	env *v1alpha2.Environment,
+	status *v1alpha2.EnvironmentStatus,
+) error {
+	desired := map[string]struct{}{}
+	var errs []error
+	for _, sc := range env.Spec.SharedConfig {
+		sc := sc
+		desired[sc.Name] = struct{}{}
+		cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Name:      sc.Name,
+			Namespace: env.NamespaceName(),
+		}}
+		errs = append(errs, r.upsert(ctx, env, status, cm, func() {
+			cm.Data = sc.Data
+			l := cm.GetLabels()
+			if l == nil {
+				l = map[string]string{}
+			}
+			l[LabelSharedConfig] = "true"
+			cm.SetLabels(l)
+		}))
+	}
+
+	errs = append(errs, r.deleteStale(ctx, env, &v1.ConfigMapList{}, desired))
+	return errors.Join(errs...)
+}
+
+func (r *EnvironmentReconciler) reconcileImagePullSecrets(
+	ctx context.Context,
+	env *v1alpha2.Environment,
+	status *v1alpha2.EnvironmentStatus,
+) error {
+	desired := map[string]struct{}{}
+	var errs []error
+	for _, ps := range env.Spec.ImagePullSecrets {
+		desired[ps.Name] = struct{}{}
+
+		key := types.NamespacedName{Namespace: ps.From.Namespace, Name: ps.From.Name}
+		from := &v1.Secret{}
+		if err := r.Get(ctx, key, from); err != nil {
+			errs = append(errs, fmt.Errorf("could not get image pull secret %s: %w", key, err))
+			continue
+		}
+		if from.Type != v1.SecretTypeDockerConfigJson {
+			errs = append(errs, fmt.Errorf("image pull secret %s is not of type %s", key, v1.SecretTypeDockerConfigJson))
+			continue
+		}
+
+		secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{
+			Name:      ps.Name,
+			Namespace: env.NamespaceName(),
+		}}
+		errs = append(errs, r.upsert(ctx, env, status, secret, func() {
+			secret.Type = from.Type
+			secret.Data = from.Data
+			l := secret.GetLabels()
+			if l == nil {
+				l = map[string]string{}
+			}
+			l[LabelImagePullSecret] = "true"
+			secret.SetLabels(l)
+		}))
+	}
+
+	errs = append(errs, r.deleteStale(ctx, env, &v1.SecretList{}, desired))
+	return errors.Join(errs...)
+}
+
+// reconcileUsage sums the instances and resources of the capsules in the
+// namespace of the environment.
+func (r *EnvironmentReconciler) reconcileUsage(
+	ctx context.Context,
+	env *v1alpha2.Environment,
+	status *v1alpha2.EnvironmentStatus,
+) error {
+	var capsules v1alpha2.CapsuleList
+	if err := r.List(ctx, &capsules, client.InNamespace(env.NamespaceName())); err != nil {
+		return fmt.Errorf("could not list capsules: %w", err)
+	}
+
+	usage := v1alpha2.EnvironmentUsage{
+		Capsules: int32(len(capsules.Items)),
+		Requests: v1.ResourceList{},
+		Limits:   v1.ResourceList{},
+	}
+	for _, c := range capsules.Items {
+		deploy := &appsv1.Deployment{}
+		if err := r.Get(ctx, client.ObjectKeyFromObject(&c), deploy); err != nil {
+			if kerrors.IsNotFound(err) {
+				continue
+			}
+			return fmt.Errorf("could not get deployment of capsule %s: %w", c.Name, err)
+		}
+
+		replicas := deploy.Status.Replicas
+		usage.Instances += replicas
+		for _, container := range deploy.Spec.Template.Spec.Containers {
+			addResources(usage.Requests, container.Resources.Requests, replicas)
+			addResources(usage.Limits, container.Resources.Limits, replicas)
+		}
+	}
+
+	status.Usage = usage
+	return nil
+}
+
+func addResources(total, resources v1.ResourceList, replicas int32) {
+	for name, q := range resources {
+		q := *resource.NewMilliQuantity(q.MilliValue()*int64(replicas), q.Format)
+		if t, ok := total[name]; ok {
+			q.Add(t)
+		}
+		total[name] = q
+	}
+}
+
+// imagePullSecrets returns the image pull secrets of the namespace, sorted by
+// name.
+func imagePullSecrets(ctx context.Context, c client.Client, namespace string) ([]v1.LocalObjectReference, error) {
+	var secrets v1.SecretList
+	if err := c.List(ctx, &secrets, &client.ListOptions{
+		Namespace:     namespace,
+		LabelSelector: labels.SelectorFromSet(labels.Set{LabelImagePullSecret: "true"}),
+	}); err != nil {
+		return nil, err
+	}
+
+	var refs []v1.LocalObjectReference
+	for _, s := range secrets.Items {
+		refs = append(refs, v1.LocalObjectReference{Name: s.Name})
+	}
+	slices.SortFunc(refs, func(a, b v1.LocalObjectReference) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return refs, nil
+}
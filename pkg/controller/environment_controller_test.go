@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rigdev/rig/pkg/api/v1alpha2"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_findEnvironments(t *testing.T) {
+	t.Parallel()
+	s := newTestScheme(t)
+	c := fake.NewClientBuilder().
+		WithScheme(s).
+		WithIndex(&v1alpha2.Environment{}, fieldImagePullSecretsFrom, indexImagePullSecretsFrom).
+		WithObjects(
+			&v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:   "prod",
+				Labels: map[string]string{LabelEnvironment: "production"},
+			}},
+			&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+			&v1alpha2.Environment{
+				ObjectMeta: metav1.ObjectMeta{Name: "production"},
+				Spec: v1alpha2.EnvironmentSpec{
+					Namespace: "prod",
+					ImagePullSecrets: []v1alpha2.ImagePullSecret{{
+						Name: "registry",
+						From: v1alpha2.SecretReference{Namespace: "rig-system", Name: "registry"},
+					}},
+				},
+			},
+			&v1alpha2.Environment{ObjectMeta: metav1.ObjectMeta{Name: "staging"}},
+		).
+		Build()
+	r := &EnvironmentReconciler{Client: c, Scheme: s}
+
+	tests := []struct {
+		name     string
+		find     func(context.Context, client.Object) []ctrl.Request
+		obj      client.Object
+		expected []ctrl.Request
+	}{
+		{
+			name: "capsule in environment namespace",
+			find: r.findEnvironmentsForNamespace,
+			obj:  &v1alpha2.Capsule{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "prod"}},
+			expected: []ctrl.Request{
+				{NamespacedName: types.NamespacedName{Name: "production"}},
+			},
+		},
+		{
+			name: "capsule in other namespace",
+			find: r.findEnvironmentsForNamespace,
+			obj:  &v1alpha2.Capsule{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}},
+		},
+		{
+			name: "referenced image pull secret",
+			find: r.findEnvironmentsForImagePullSecret,
+			obj:  &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "registry", Namespace: "rig-system"}},
+			expected: []ctrl.Request{
+				{NamespacedName: types.NamespacedName{Name: "production"}},
+			},
+		},
+		{
+			name:     "other secret",
+			find:     r.findEnvironmentsForImagePullSecret,
+			obj:      &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "registry", Namespace: "default"}},
+			expected: []ctrl.Request{},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.expected, tt.find(context.Background(), tt.obj))
+		})
+	}
+}
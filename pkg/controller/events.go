@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/rigdev/rig/pkg/api/v1alpha2"
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Reasons of the events emitted on capsules. Repeated events are aggregated
+// by the event recorder, so the events of a capsule tell what the operator
+// did to it.
+const (
+	EventReasonCreated                = "Created"
+	EventReasonUpdated                = "Updated"
+	EventReasonDeleted                = "Deleted"
+	EventReasonFailed                 = "Failed"
+	EventReasonNotOwned               = "NotOwned"
+	EventReasonMissingConfig          = "MissingConfig"
+	EventReasonMissingConnection      = "MissingConnection"
+	EventReasonMissingTemplate        = "MissingTemplate"
+	EventReasonDependencyCycle        = "DependencyCycle"
+	EventReasonCertificateFailed      = "CertificateFailed"
+	EventReasonRolledBack             = "RolledBack"
+	EventReasonExpiring               = "Expiring"
+	EventReasonExpired                = "Expired"
+	EventReasonIdleTimeoutNotEnforced = "IdleTimeoutNotEnforced"
+)
+
+// createOwned creates an object owned by the capsule and records the outcome
+// as an event on the capsule.
+func (r *CapsuleReconciler) createOwned(ctx context.Context, capsule *v1alpha2.Capsule, obj client.Object) error {
+	kind := kindOf(r.Scheme, obj)
+	if err := r.Create(ctx, obj); err != nil {
+		r.Recorder.Eventf(
+			capsule, v1.EventTypeWarning, EventReasonFailed, "Could not create %s %s: %v", kind, obj.GetName(), err,
+		)
+		return err
+	}
+	r.Recorder.Eventf(capsule, v1.EventTypeNormal, EventReasonCreated, "Created %s %s", kind, obj.GetName())
+	return nil
+}
+
+// deleteOwned deletes an object owned by the capsule and records the outcome
+// as an event on the capsule.
+func (r *CapsuleReconciler) deleteOwned(ctx context.Context, capsule *v1alpha2.Capsule, obj client.Object) error {
+	kind := kindOf(r.Scheme, obj)
+	if err := r.Delete(ctx, obj); err != nil {
+		r.Recorder.Eventf(
+			capsule, v1.EventTypeWarning, EventReasonFailed, "Could not delete %s %s: %v", kind, obj.GetName(), err,
+		)
+		return err
+	}
+	r.Recorder.Eventf(capsule, v1.EventTypeNormal, EventReasonDeleted, "Deleted %s %s", kind, obj.GetName())
+	return nil
+}
+
+// recordNotOwned records that an existing object conflicts with the capsule,
+// because it is not owned by it.
+func (r *CapsuleReconciler) recordNotOwned(capsule *v1alpha2.Capsule, obj client.Object) {
+	r.Recorder.Eventf(
+		capsule, v1.EventTypeWarning, EventReasonNotOwned,
+		"Found existing %s %s not owned by capsule", kindOf(r.Scheme, obj), obj.GetName(),
+	)
+}
@@ -0,0 +1,242 @@
+package controller
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/rigdev/rig/pkg/api/v1alpha2"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// AnnotationRotateGeneratedSecrets is the capsule annotation listing the
+// comma separated keys of generated secrets to rotate. The annotation is
+// removed once the keys are rotated.
+const AnnotationRotateGeneratedSecrets = "rig.dev/rotate-generated-secrets"
+
+const (
+	defaultGeneratedSecretLength  = 32
+	defaultGeneratedSecretCharset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	defaultGeneratedSecretRSABits = 2048
+)
+
+// generatedSecretName returns the name of the Secret holding the generated
+// secrets of the capsule.
+func generatedSecretName(capsule *v1alpha2.Capsule) string {
+	return capsule.Name + "-generated"
+}
+
+func (r *CapsuleReconciler) reconcileGeneratedSecrets(
+	ctx context.Context,
+	_ ctrl.Request,
+	log logr.Logger,
+	capsule *v1alpha2.Capsule,
+	status *v1alpha2.CapsuleStatus,
+) error {
+	existing := &v1.Secret{}
+	hasExisting := true
+	if err := r.Get(ctx, types.NamespacedName{
+		Namespace: capsule.Namespace,
+		Name:      generatedSecretName(capsule),
+	}, existing); err != nil {
+		if !kerrors.IsNotFound(err) {
+			return fmt.Errorf("could not fetch generated secret: %w", err)
+		}
+		hasExisting = false
+	}
+
+	if hasExisting && !IsOwnedBy(capsule, existing) {
+		if len(capsule.Spec.GeneratedSecrets) > 0 {
+			log.Info("Found existing generated secret not owned by capsule. Will not update it.")
+			r.recordNotOwned(capsule, existing)
+			return errors.New("found existing generated secret not owned by capsule")
+		}
+		return nil
+	}
+
+	if len(capsule.Spec.GeneratedSecrets) == 0 {
+		if hasExisting {
+			log.Info("deleting generated secret")
+			return r.deleteOwned(ctx, capsule, existing)
+		}
+		return nil
+	}
+
+	rotate := map[string]struct{}{}
+	if keys := capsule.GetAnnotations()[AnnotationRotateGeneratedSecrets]; keys != "" {
+		for _, k := range strings.Split(keys, ",") {
+			rotate[strings.TrimSpace(k)] = struct{}{}
+		}
+	}
+
+	secret, err := createGeneratedSecret(capsule, r.Scheme, existing.Data, rotate)
+	if err != nil {
+		return err
+	}
+
+	if !hasExisting {
+		log.Info("creating generated secret")
+		if err := r.createOwned(ctx, capsule, secret); err != nil {
+			return fmt.Errorf("could not create generated secret: %w", err)
+		}
+	} else if err := upsertIfNewer(ctx, r, existing, secret, log, capsule, status, func(t1, t2 *v1.Secret) bool {
+		return equality.Semantic.DeepEqual(t1.Data, t2.Data)
+	}); err != nil {
+		return err
+	}
+
+	if len(rotate) == 0 {
+		return nil
+	}
+
+	// The keys are rotated, so remove the request to not rotate them again.
+	log.Info("rotated generated secrets", "keys", capsule.GetAnnotations()[AnnotationRotateGeneratedSecrets])
+	patched := capsule.DeepCopy()
+	delete(patched.Annotations, AnnotationRotateGeneratedSecrets)
+	if err := r.Patch(ctx, patched, client.MergeFrom(capsule)); err != nil {
+		return fmt.Errorf("could not remove %s annotation: %w", AnnotationRotateGeneratedSecrets, err)
+	}
+	// The spec of the capsule may be merged onto its template, so only the
+	// metadata is taken from the patched capsule.
+	capsule.ObjectMeta = patched.ObjectMeta
+	return nil
+}
+
+// createGeneratedSecret returns the Secret of the generated secrets of the
+// capsule. Existing values are kept, unless their key is to be rotated.
+func createGeneratedSecret(
+	capsule *v1alpha2.Capsule,
+	scheme *runtime.Scheme,
+	existing map[string][]byte,
+	rotate map[string]struct{},
+) (*v1.Secret, error) {
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      generatedSecretName(capsule),
+			Namespace: capsule.Namespace,
+			Labels: map[string]string{
+				LabelCapsule: capsule.Name,
+			},
+		},
+		Type: v1.SecretTypeOpaque,
+		Data: map[string][]byte{},
+	}
+
+	for _, g := range capsule.Spec.GeneratedSecrets {
+		keys := g.Keys()
+		_, shouldRotate := rotate[g.Key]
+		if !shouldRotate && hasKeys(existing, keys) {
+			for _, k := range keys {
+				secret.Data[k] = existing[k]
+			}
+			continue
+		}
+
+		values, err := generateSecret(g)
+		if err != nil {
+			return nil, fmt.Errorf("could not generate secret %s: %w", g.Key, err)
+		}
+		for i, k := range keys {
+			secret.Data[k] = values[i]
+		}
+	}
+
+	if err := controllerutil.SetControllerReference(capsule, secret, scheme); err != nil {
+		return nil, fmt.Errorf("could not set owner reference on generated secret: %w", err)
+	}
+
+	return secret, nil
+}
+
+func hasKeys(data map[string][]byte, keys []string) bool {
+	for _, k := range keys {
+		if _, ok := data[k]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// generateSecret returns the values of the generated secret, in the order of
+// its keys.
+func generateSecret(g v1alpha2.GeneratedSecret) ([][]byte, error) {
+	switch g.Type {
+	case v1alpha2.GeneratedSecretTypeRSA:
+		bits := int(g.Bits)
+		if bits == 0 {
+			bits = defaultGeneratedSecretRSABits
+		}
+		key, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			return nil, err
+		}
+		return encodeKeyPair(key, key.Public())
+	case v1alpha2.GeneratedSecretTypeEd25519:
+		pub, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return encodeKeyPair(key, pub)
+	default:
+		length := int(g.Length)
+		if length == 0 {
+			length = defaultGeneratedSecretLength
+		}
+		charset := g.Charset
+		if charset == "" {
+			charset = defaultGeneratedSecretCharset
+		}
+		value, err := randomString(length, []rune(charset))
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{[]byte(value)}, nil
+	}
+}
+
+func randomString(length int, charset []rune) (string, error) {
+	size := big.NewInt(int64(len(charset)))
+	var b strings.Builder
+	for i := 0; i < length; i++ {
+		n, err := rand.Int(rand.Reader, size)
+		if err != nil {
+			return "", err
+		}
+		b.WriteRune(charset[n.Int64()])
+	}
+	return b.String(), nil
+}
+
+// encodeKeyPair returns the PKCS #8 encoded private key and PKIX encoded
+// public key, both PEM encoded.
+func encodeKeyPair(key crypto.PrivateKey, pub crypto.PublicKey) ([][]byte, error) {
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	return [][]byte{
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}),
+	}, nil
+}
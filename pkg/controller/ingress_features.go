@@ -0,0 +1,294 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/go-logr/logr"
+	configv1alpha1 "github.com/rigdev/rig/pkg/api/config/v1alpha1"
+	"github.com/rigdev/rig/pkg/api/v1alpha2"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const annotationTraefikMiddlewares = "traefik.ingress.kubernetes.io/router.middlewares"
+
+var traefikMiddlewareGVK = schema.GroupVersionKind{
+	Group:   "traefik.io",
+	Version: "v1alpha1",
+	Kind:    "Middleware",
+}
+
+// ingressFeatureValues are the values of the ingress features of an
+// interface, which the annotation templates of ingress profiles are executed
+// with.
+type ingressFeatureValues struct {
+	Origins           string
+	RequestsPerSecond int32
+	SourceRanges      string
+	SecretName        string
+	MaxBodySizeBytes  int64
+	TimeoutSeconds    int64
+}
+
+func newIngressFeatureValues(ing *v1alpha2.CapsuleInterfaceIngress) ingressFeatureValues {
+	var v ingressFeatureValues
+	if ing.CORS != nil {
+		v.Origins = strings.Join(ing.CORS.AllowOrigins, ",")
+	}
+	if ing.RateLimit != nil {
+		v.RequestsPerSecond = ing.RateLimit.RequestsPerSecond
+	}
+	v.SourceRanges = strings.Join(ing.SourceRanges, ",")
+	if ing.BasicAuth != nil {
+		v.SecretName = ing.BasicAuth.SecretName
+	}
+	if ing.MaxBodySize != nil {
+		v.MaxBodySizeBytes = ing.MaxBodySize.Value()
+	}
+	if ing.Timeout != nil {
+		v.TimeoutSeconds = int64(ing.Timeout.Seconds())
+	}
+	return v
+}
+
+// ingressFeatures returns the features set on the ingress, in the order of
+// configv1alpha1.IngressFeatures.
+func ingressFeatures(ing *v1alpha2.CapsuleInterfaceIngress) []string {
+	set := map[string]bool{
+		configv1alpha1.IngressFeatureCORS:         ing.CORS != nil,
+		configv1alpha1.IngressFeatureRateLimit:    ing.RateLimit != nil,
+		configv1alpha1.IngressFeatureSourceRanges: len(ing.SourceRanges) > 0,
+		configv1alpha1.IngressFeatureBasicAuth:    ing.BasicAuth != nil,
+		configv1alpha1.IngressFeatureMaxBodySize:  ing.MaxBodySize != nil,
+		configv1alpha1.IngressFeatureTimeout:      ing.Timeout != nil,
+	}
+	var features []string
+	for _, f := range configv1alpha1.IngressFeatures {
+		if set[f] {
+			features = append(features, f)
+		}
+	}
+	return features
+}
+
+// ingressGroup is the interfaces of a capsule published through the same
+// Ingress.
+type ingressGroup struct {
+	// name is the name of the Ingress.
+	name       string
+	interfaces []v1alpha2.CapsuleInterface
+}
+
+// ingress returns the ingress of the first interface of the group, which
+// has the features of the group.
+func (g ingressGroup) ingress() *v1alpha2.CapsuleInterfaceIngress {
+	return g.interfaces[0].Public.Ingress
+}
+
+// ingressGroups returns the Ingresses of the capsule. The interfaces with an
+// ingress share an Ingress named after the capsule if they have the same
+// ingress features. Otherwise each interface has an Ingress of its own,
+// named <capsule>.<interface>.
+func ingressGroups(capsule *v1alpha2.Capsule) []ingressGroup {
+	var infs []v1alpha2.CapsuleInterface
+	for _, inf := range capsule.Spec.Interfaces {
+		if inf.Public != nil && inf.Public.Ingress != nil {
+			infs = append(infs, inf)
+		}
+	}
+	if len(infs) == 0 {
+		return nil
+	}
+
+	shared := true
+	for _, inf := range infs[1:] {
+		if !inf.Public.Ingress.HasFeaturesOf(infs[0].Public.Ingress) {
+			shared = false
+			break
+		}
+	}
+	if shared {
+		return []ingressGroup{{name: capsule.Name, interfaces: infs}}
+	}
+
+	// Capsules with interfaces have no dots in their names, as their
+	// Services are named after them, so the names can't clash with the
+	// Ingress of another capsule.
+	groups := make([]ingressGroup, len(infs))
+	for i, inf := range infs {
+		groups[i] = ingressGroup{
+			name:       fmt.Sprintf("%s.%s", capsule.Name, inf.Name),
+			interfaces: infs[i : i+1],
+		}
+	}
+	return groups
+}
+
+// ingressFeatureAnnotations returns the annotations of the Ingress of the
+// group, configuring the ingress features of its interfaces using the
+// profile of the ingress class.
+func ingressFeatureAnnotations(
+	cfg *configv1alpha1.IngressConfig,
+	namespace string,
+	g ingressGroup,
+) (map[string]string, error) {
+	ing := g.ingress()
+	features := ingressFeatures(ing)
+	if len(features) == 0 {
+		return nil, nil
+	}
+
+	profile, ok := cfg.GetProfile()
+	if !ok {
+		return nil, fmt.Errorf("ingress class %q has no profile supporting the ingress features %s",
+			cfg.ClassName, strings.Join(features, ", "))
+	}
+
+	if profile.TraefikMiddlewares {
+		var refs []string
+		for _, f := range features {
+			if f == configv1alpha1.IngressFeatureTimeout {
+				return nil, fmt.Errorf("ingress feature %s is not supported by Traefik Middlewares", f)
+			}
+			refs = append(refs, fmt.Sprintf("%s-%s@kubernetescrd", namespace, traefikMiddlewareName(g, f)))
+		}
+		return map[string]string{annotationTraefikMiddlewares: strings.Join(refs, ",")}, nil
+	}
+
+	values := newIngressFeatureValues(ing)
+	annotations := map[string]string{}
+	for _, f := range features {
+		as, ok := profile.Annotations[f]
+		if !ok {
+			return nil, fmt.Errorf("ingress feature %s is not supported by the profile of ingress class %q",
+				f, cfg.ClassName)
+		}
+		for k, v := range as {
+			tmpl, err := template.New(k).Parse(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid template of annotation %s: %w", k, err)
+			}
+			var value strings.Builder
+			if err := tmpl.Execute(&value, values); err != nil {
+				return nil, fmt.Errorf("invalid template of annotation %s: %w", k, err)
+			}
+			annotations[k] = value.String()
+		}
+	}
+	return annotations, nil
+}
+
+func traefikMiddlewareName(g ingressGroup, feature string) string {
+	return fmt.Sprintf("%s-%s", g.name, strings.ToLower(feature))
+}
+
+// createTraefikMiddlewares returns a Traefik Middleware for each ingress
+// feature of each Ingress of the capsule.
+func createTraefikMiddlewares(capsule *v1alpha2.Capsule) []*unstructured.Unstructured {
+	var mws []*unstructured.Unstructured
+	for _, g := range ingressGroups(capsule) {
+		mws = append(mws, createGroupTraefikMiddlewares(capsule.Namespace, g)...)
+	}
+	return mws
+}
+
+func createGroupTraefikMiddlewares(namespace string, g ingressGroup) []*unstructured.Unstructured {
+	ing := g.ingress()
+	var mws []*unstructured.Unstructured
+	for _, f := range ingressFeatures(ing) {
+		var spec map[string]interface{}
+		switch f {
+		case configv1alpha1.IngressFeatureCORS:
+			spec = map[string]interface{}{"headers": map[string]interface{}{
+				"accessControlAllowOriginList": toInterfaces(ing.CORS.AllowOrigins),
+				"accessControlAllowMethods": []interface{}{
+					"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS",
+				},
+				"accessControlAllowHeaders": []interface{}{"*"},
+			}}
+		case configv1alpha1.IngressFeatureRateLimit:
+			spec = map[string]interface{}{"rateLimit": map[string]interface{}{
+				"average": int64(ing.RateLimit.RequestsPerSecond),
+				"burst":   int64(ing.RateLimit.RequestsPerSecond),
+				"period":  "1s",
+			}}
+		case configv1alpha1.IngressFeatureSourceRanges:
+			spec = map[string]interface{}{"ipAllowList": map[string]interface{}{
+				"sourceRange": toInterfaces(ing.SourceRanges),
+			}}
+		case configv1alpha1.IngressFeatureBasicAuth:
+			spec = map[string]interface{}{"basicAuth": map[string]interface{}{
+				"secret": ing.BasicAuth.SecretName,
+			}}
+		case configv1alpha1.IngressFeatureMaxBodySize:
+			spec = map[string]interface{}{"buffering": map[string]interface{}{
+				"maxRequestBodyBytes": ing.MaxBodySize.Value(),
+			}}
+		default:
+			continue
+		}
+
+		mw := &unstructured.Unstructured{}
+		mw.SetGroupVersionKind(traefikMiddlewareGVK)
+		mw.SetName(traefikMiddlewareName(g, f))
+		mw.SetNamespace(namespace)
+		mw.Object["spec"] = spec
+		mws = append(mws, mw)
+	}
+	return mws
+}
+
+func toInterfaces(ss []string) []interface{} {
+	res := make([]interface{}, len(ss))
+	for i, s := range ss {
+		res[i] = s
+	}
+	return res
+}
+
+//+kubebuilder:rbac:groups=traefik.io,resources=middlewares,verbs=get;list;watch;create;update;patch;delete
+
+// reconcileTraefikMiddlewares creates the Traefik Middlewares of the ingress
+// features of the capsule, if the ingress class uses Traefik Middlewares, and
+// deletes the Middlewares of the capsule which are no longer desired.
+func (r *CapsuleReconciler) reconcileTraefikMiddlewares(
+	ctx context.Context,
+	_ ctrl.Request,
+	_ logr.Logger,
+	capsule *v1alpha2.Capsule,
+	status *v1alpha2.CapsuleStatus,
+) error {
+	cfg := r.Config.Get()
+	profile, ok := cfg.Ingress.GetProfile()
+	traefik := ok && profile.TraefikMiddlewares
+
+	desired := map[string]struct{}{}
+	var errs []error
+	if traefik && r.ingressIsSupported(capsule) {
+		for _, mw := range createTraefikMiddlewares(capsule) {
+			mw := mw
+			desired[mw.GetName()] = struct{}{}
+			spec := mw.Object["spec"]
+			errs = append(errs, upsertOwned(
+				ctx, r.Client, r.Scheme, capsule, LabelCapsule, &status.OwnedResources, mw,
+				func() { mw.Object["spec"] = spec },
+			))
+		}
+	}
+
+	// Middlewares are deleted once no longer desired, also when the ingress
+	// class no longer uses them. Without the Traefik CRD there are none.
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(traefikMiddlewareGVK.GroupVersion().WithKind("MiddlewareList"))
+	err := deleteStaleOwned(ctx, r.Client, capsule, LabelCapsule, capsule.Namespace, list, desired)
+	if err != nil && !meta.IsNoMatchError(err) {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
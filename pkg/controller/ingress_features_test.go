@@ -0,0 +1,286 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	configv1alpha1 "github.com/rigdev/rig/pkg/api/config/v1alpha1"
+	"github.com/rigdev/rig/pkg/api/v1alpha2"
+	"github.com/rigdev/rig/pkg/ptr"
+	"github.com/rigdev/rig/pkg/service/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+func newIngressCapsule(ingresses ...*v1alpha2.CapsuleInterfaceIngress) *v1alpha2.Capsule {
+	capsule := &v1alpha2.Capsule{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default", UID: "capsule"},
+	}
+	for i, ing := range ingresses {
+		name := []string{"http", "admin", "metrics"}[i]
+		capsule.Spec.Interfaces = append(capsule.Spec.Interfaces, v1alpha2.CapsuleInterface{
+			Name:   name,
+			Port:   int32(8080 + i),
+			Public: &v1alpha2.CapsulePublicInterface{Ingress: ing},
+		})
+	}
+	return capsule
+}
+
+func Test_ingressGroups(t *testing.T) {
+	t.Parallel()
+	rateLimit := &v1alpha2.IngressRateLimit{RequestsPerSecond: 10}
+
+	tests := []struct {
+		name     string
+		capsule  *v1alpha2.Capsule
+		expected map[string][]string
+	}{
+		{
+			name:    "no ingress",
+			capsule: newIngressCapsule(),
+		},
+		{
+			name: "same features",
+			capsule: newIngressCapsule(
+				&v1alpha2.CapsuleInterfaceIngress{Host: "a.example.com", RateLimit: rateLimit},
+				&v1alpha2.CapsuleInterfaceIngress{Host: "b.example.com", RateLimit: rateLimit},
+			),
+			expected: map[string][]string{"test": {"http", "admin"}},
+		},
+		{
+			name: "different features",
+			capsule: newIngressCapsule(
+				&v1alpha2.CapsuleInterfaceIngress{Host: "a.example.com", RateLimit: rateLimit},
+				&v1alpha2.CapsuleInterfaceIngress{Host: "b.example.com"},
+			),
+			expected: map[string][]string{"test.http": {"http"}, "test.admin": {"admin"}},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var groups map[string][]string
+			for _, g := range ingressGroups(tt.capsule) {
+				if groups == nil {
+					groups = map[string][]string{}
+				}
+				for _, inf := range g.interfaces {
+					groups[g.name] = append(groups[g.name], inf.Name)
+				}
+			}
+			assert.Equal(t, tt.expected, groups)
+		})
+	}
+}
+
+func Test_ingressFeatureAnnotations(t *testing.T) {
+	t.Parallel()
+	ing := &v1alpha2.CapsuleInterfaceIngress{
+		Host:         "test.example.com",
+		CORS:         &v1alpha2.IngressCORS{AllowOrigins: []string{"https://a.com", "https://b.com"}},
+		RateLimit:    &v1alpha2.IngressRateLimit{RequestsPerSecond: 10},
+		SourceRanges: []string{"10.0.0.0/8", "192.168.0.0/16"},
+		BasicAuth:    &v1alpha2.IngressBasicAuth{SecretName: "users"},
+		MaxBodySize:  ptr.New(resource.MustParse("1Mi")),
+	}
+	withTimeout := ing.DeepCopy()
+	withTimeout.Timeout = &metav1.Duration{Duration: time.Minute}
+
+	tests := []struct {
+		name        string
+		cfg         configv1alpha1.IngressConfig
+		ing         *v1alpha2.CapsuleInterfaceIngress
+		expected    map[string]string
+		expectedErr string
+	}{
+		{
+			name: "no features",
+			ing:  &v1alpha2.CapsuleInterfaceIngress{Host: "test.example.com"},
+		},
+		{
+			name: "nginx",
+			cfg:  configv1alpha1.IngressConfig{ClassName: "nginx"},
+			ing:  withTimeout,
+			expected: map[string]string{
+				"nginx.ingress.kubernetes.io/enable-cors":            "true",
+				"nginx.ingress.kubernetes.io/cors-allow-origin":      "https://a.com,https://b.com",
+				"nginx.ingress.kubernetes.io/limit-rps":              "10",
+				"nginx.ingress.kubernetes.io/whitelist-source-range": "10.0.0.0/8,192.168.0.0/16",
+				"nginx.ingress.kubernetes.io/auth-type":              "basic",
+				"nginx.ingress.kubernetes.io/auth-secret":            "users",
+				"nginx.ingress.kubernetes.io/proxy-body-size":        "1048576",
+				"nginx.ingress.kubernetes.io/proxy-read-timeout":     "60",
+				"nginx.ingress.kubernetes.io/proxy-send-timeout":     "60",
+			},
+		},
+		{
+			name: "traefik",
+			cfg:  configv1alpha1.IngressConfig{ClassName: "traefik"},
+			ing:  ing,
+			expected: map[string]string{
+				annotationTraefikMiddlewares: "default-test-sourceranges@kubernetescrd,default-test-basicauth@kubernetescrd," +
+					"default-test-ratelimit@kubernetescrd,default-test-cors@kubernetescrd," +
+					"default-test-maxbodysize@kubernetescrd",
+			},
+		},
+		{
+			name:        "timeout with traefik",
+			cfg:         configv1alpha1.IngressConfig{ClassName: "traefik"},
+			ing:         withTimeout,
+			expectedErr: "ingress feature timeout is not supported by Traefik Middlewares",
+		},
+		{
+			name: "feature missing from profile",
+			cfg: configv1alpha1.IngressConfig{
+				ClassName: "custom",
+				Profiles: map[string]configv1alpha1.IngressProfile{"custom": {
+					Annotations: map[string]map[string]string{
+						configv1alpha1.IngressFeatureCORS: {"custom/cors": "{{ .Origins }}"},
+					},
+				}},
+			},
+			ing:         ing,
+			expectedErr: `ingress feature sourceRanges is not supported by the profile of ingress class "custom"`,
+		},
+		{
+			name: "no profile",
+			cfg:  configv1alpha1.IngressConfig{ClassName: "unknown"},
+			ing:  ing,
+			expectedErr: `ingress class "unknown" has no profile supporting the ingress features ` +
+				"sourceRanges, basicAuth, rateLimit, cors, maxBodySize",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			g := ingressGroups(newIngressCapsule(tt.ing))[0]
+			annotations, err := ingressFeatureAnnotations(&tt.cfg, "default", g)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, annotations)
+		})
+	}
+}
+
+func Test_createTraefikMiddlewares(t *testing.T) {
+	t.Parallel()
+	capsule := newIngressCapsule(
+		&v1alpha2.CapsuleInterfaceIngress{
+			Host:         "a.example.com",
+			CORS:         &v1alpha2.IngressCORS{AllowOrigins: []string{"*"}},
+			SourceRanges: []string{"10.0.0.0/8"},
+		},
+		&v1alpha2.CapsuleInterfaceIngress{
+			Host:        "b.example.com",
+			RateLimit:   &v1alpha2.IngressRateLimit{RequestsPerSecond: 5},
+			BasicAuth:   &v1alpha2.IngressBasicAuth{SecretName: "users"},
+			MaxBodySize: ptr.New(resource.MustParse("1k")),
+		},
+	)
+
+	specs := map[string]interface{}{}
+	for _, mw := range createTraefikMiddlewares(capsule) {
+		assert.Equal(t, traefikMiddlewareGVK, mw.GroupVersionKind())
+		assert.Equal(t, "default", mw.GetNamespace())
+		specs[mw.GetName()] = mw.Object["spec"]
+	}
+
+	assert.Equal(t, map[string]interface{}{
+		"test.http-cors": map[string]interface{}{"headers": map[string]interface{}{
+			"accessControlAllowOriginList": []interface{}{"*"},
+			"accessControlAllowMethods": []interface{}{
+				"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS",
+			},
+			"accessControlAllowHeaders": []interface{}{"*"},
+		}},
+		"test.http-sourceranges": map[string]interface{}{"ipAllowList": map[string]interface{}{
+			"sourceRange": []interface{}{"10.0.0.0/8"},
+		}},
+		"test.admin-ratelimit": map[string]interface{}{"rateLimit": map[string]interface{}{
+			"average": int64(5),
+			"burst":   int64(5),
+			"period":  "1s",
+		}},
+		"test.admin-basicauth": map[string]interface{}{"basicAuth": map[string]interface{}{
+			"secret": "users",
+		}},
+		"test.admin-maxbodysize": map[string]interface{}{"buffering": map[string]interface{}{
+			"maxRequestBodyBytes": int64(1000),
+		}},
+	}, specs)
+}
+
+func Test_reconcileTraefikMiddlewares_deletesStale(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		crd  bool
+	}{
+		{name: "class without middlewares", crd: true},
+		{name: "no traefik crd"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			s := newTestScheme(t)
+			builder := fake.NewClientBuilder().WithScheme(s)
+
+			capsule := newIngressCapsule(&v1alpha2.CapsuleInterfaceIngress{
+				Host: "a.example.com",
+				CORS: &v1alpha2.IngressCORS{AllowOrigins: []string{"*"}},
+			})
+			if tt.crd {
+				s.AddKnownTypeWithName(traefikMiddlewareGVK, &unstructured.Unstructured{})
+				s.AddKnownTypeWithName(
+					traefikMiddlewareGVK.GroupVersion().WithKind("MiddlewareList"), &unstructured.UnstructuredList{},
+				)
+				mws := createTraefikMiddlewares(capsule)
+				require.Len(t, mws, 1)
+				mws[0].SetLabels(map[string]string{LabelCapsule: capsule.Name})
+				require.NoError(t, controllerutil.SetControllerReference(capsule, mws[0], s))
+				builder = builder.WithObjects(mws[0])
+			}
+
+			r := &CapsuleReconciler{
+				Client: builder.Build(),
+				Scheme: s,
+				Config: config.NewServiceFromConfig(&configv1alpha1.OperatorConfig{
+					Ingress: configv1alpha1.IngressConfig{ClassName: "nginx"},
+					Certmanager: &configv1alpha1.CertManagerConfig{
+						ClusterIssuer: "letsencrypt",
+					},
+				}),
+			}
+			status := &v1alpha2.CapsuleStatus{}
+			require.NoError(t, r.reconcileTraefikMiddlewares(
+				context.Background(), ctrl.Request{}, logr.Discard(), capsule, status,
+			))
+			assert.Empty(t, status.OwnedResources)
+
+			if tt.crd {
+				list := &unstructured.UnstructuredList{}
+				list.SetGroupVersionKind(traefikMiddlewareGVK.GroupVersion().WithKind("MiddlewareList"))
+				require.NoError(t, r.List(context.Background(), list))
+				assert.Empty(t, list.Items)
+			}
+		})
+	}
+}
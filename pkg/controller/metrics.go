@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rigdev/rig/pkg/api/v1alpha2"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	reconcileStepDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "rig",
+		Subsystem: "capsule",
+		Name:      "reconcile_step_duration_seconds",
+		Help:      "Duration of each step of a capsule reconciliation.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 12),
+	}, []string{"step"})
+
+	reconcileStepErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "rig",
+		Subsystem: "capsule",
+		Name:      "reconcile_step_errors_total",
+		Help:      "Number of errors returned by each step of a capsule reconciliation.",
+	}, []string{"step"})
+
+	capsuleDeploymentState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "rig",
+		Subsystem: "capsule",
+		Name:      "deployment_state",
+		Help:      "Deployment state of a capsule. The series of the current state is 1, the others are 0.",
+	}, []string{"namespace", "capsule", "state"})
+
+	capsuleOwnedResources = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "rig",
+		Subsystem: "capsule",
+		Name:      "owned_resources",
+		Help:      "Number of resources owned by a capsule by kind and state.",
+	}, []string{"namespace", "capsule", "kind", "state"})
+
+	capsuleUsedResourcesMissing = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "rig",
+		Subsystem: "capsule",
+		Name:      "used_resources_missing",
+		Help:      "Number of resources used by a capsule which could not be found.",
+	}, []string{"namespace", "capsule"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		reconcileStepDuration,
+		reconcileStepErrors,
+		capsuleDeploymentState,
+		capsuleOwnedResources,
+		capsuleUsedResourcesMissing,
+	)
+}
+
+var deploymentStates = []string{"created", "progressing", "failed"}
+
+// ownedResourceSeries holds the kind and state labels of the owned resources
+// series of each capsule. Series are updated in place and only those no
+// longer present are deleted, so a scrape never misses the series of a
+// capsule.
+var (
+	ownedResourceSeriesMu sync.Mutex
+	ownedResourceSeries   = map[types.NamespacedName]map[[2]string]struct{}{}
+)
+
+// observeCapsuleStatus updates the capsule gauges from the status computed by
+// a reconciliation.
+func observeCapsuleStatus(capsule *v1alpha2.Capsule, status *v1alpha2.CapsuleStatus) {
+	if status.Deployment != nil {
+		for _, state := range deploymentStates {
+			var v float64
+			if status.Deployment.State == state {
+				v = 1
+			}
+			capsuleDeploymentState.WithLabelValues(capsule.Namespace, capsule.Name, state).Set(v)
+		}
+	} else {
+		capsuleDeploymentState.DeletePartialMatch(prometheus.Labels{
+			"namespace": capsule.Namespace,
+			"capsule":   capsule.Name,
+		})
+	}
+
+	owned := map[[2]string]float64{}
+	for _, res := range status.OwnedResources {
+		var kind string
+		if res.Ref != nil {
+			kind = res.Ref.Kind
+		}
+		owned[[2]string{kind, res.State}]++
+	}
+
+	key := types.NamespacedName{Namespace: capsule.Namespace, Name: capsule.Name}
+	ownedResourceSeriesMu.Lock()
+	for series, v := range owned {
+		capsuleOwnedResources.WithLabelValues(capsule.Namespace, capsule.Name, series[0], series[1]).Set(v)
+	}
+	for series := range ownedResourceSeries[key] {
+		if _, ok := owned[series]; !ok {
+			capsuleOwnedResources.DeleteLabelValues(capsule.Namespace, capsule.Name, series[0], series[1])
+		}
+	}
+	current := make(map[[2]string]struct{}, len(owned))
+	for series := range owned {
+		current[series] = struct{}{}
+	}
+	ownedResourceSeries[key] = current
+	ownedResourceSeriesMu.Unlock()
+
+	var missing float64
+	for _, res := range status.UsedResources {
+		if res.State == "missing" {
+			missing++
+		}
+	}
+	capsuleUsedResourcesMissing.WithLabelValues(capsule.Namespace, capsule.Name).Set(missing)
+}
+
+// deleteCapsuleMetrics removes all capsule gauges of the given capsule.
+func deleteCapsuleMetrics(namespace, name string) {
+	ownedResourceSeriesMu.Lock()
+	delete(ownedResourceSeries, types.NamespacedName{Namespace: namespace, Name: name})
+	ownedResourceSeriesMu.Unlock()
+
+	labels := prometheus.Labels{"namespace": namespace, "capsule": name}
+	capsuleDeploymentState.DeletePartialMatch(labels)
+	capsuleOwnedResources.DeletePartialMatch(labels)
+	capsuleUsedResourcesMissing.DeletePartialMatch(labels)
+}
@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rigdev/rig/pkg/api/v1alpha2"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Test_capsuleMetrics is not parallel, as the capsule gauges are global.
+func Test_capsuleMetrics(t *testing.T) {
+	capsule := &v1alpha2.Capsule{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}}
+	owned := func(kind, state string) v1alpha2.OwnedResource {
+		return v1alpha2.OwnedResource{Ref: &v1.TypedLocalObjectReference{Kind: kind}, State: state}
+	}
+	used := func(state string) v1alpha2.UsedResource {
+		return v1alpha2.UsedResource{Ref: &v1.TypedLocalObjectReference{Kind: "ConfigMap"}, State: state}
+	}
+
+	const (
+		deploymentStateHeader = `
+# HELP rig_capsule_deployment_state Deployment state of a capsule. ` +
+			`The series of the current state is 1, the others are 0.
+# TYPE rig_capsule_deployment_state gauge
+`
+		ownedResourcesHeader = `
+# HELP rig_capsule_owned_resources Number of resources owned by a capsule by kind and state.
+# TYPE rig_capsule_owned_resources gauge
+`
+		usedResourcesMissingHeader = `
+# HELP rig_capsule_used_resources_missing Number of resources used by a capsule which could not be found.
+# TYPE rig_capsule_used_resources_missing gauge
+`
+	)
+
+	tests := []struct {
+		name string
+		// status is the status of a reconciliation, or nil if the capsule
+		// was deleted.
+		status                       *v1alpha2.CapsuleStatus
+		expectedDeploymentState      string
+		expectedOwnedResources       string
+		expectedUsedResourcesMissing string
+	}{
+		{
+			name: "progressing",
+			status: &v1alpha2.CapsuleStatus{
+				Deployment: &v1alpha2.DeploymentStatus{State: "progressing"},
+				OwnedResources: []v1alpha2.OwnedResource{
+					owned("Deployment", "created"),
+					owned("Service", "created"),
+					owned("Ingress", "failed"),
+				},
+				UsedResources: []v1alpha2.UsedResource{used("missing"), used("found"), used("missing")},
+			},
+			expectedDeploymentState: deploymentStateHeader + `
+rig_capsule_deployment_state{capsule="test",namespace="default",state="created"} 0
+rig_capsule_deployment_state{capsule="test",namespace="default",state="failed"} 0
+rig_capsule_deployment_state{capsule="test",namespace="default",state="progressing"} 1
+`,
+			expectedOwnedResources: ownedResourcesHeader + `
+rig_capsule_owned_resources{capsule="test",kind="Deployment",namespace="default",state="created"} 1
+rig_capsule_owned_resources{capsule="test",kind="Ingress",namespace="default",state="failed"} 1
+rig_capsule_owned_resources{capsule="test",kind="Service",namespace="default",state="created"} 1
+`,
+			expectedUsedResourcesMissing: usedResourcesMissingHeader + `
+rig_capsule_used_resources_missing{capsule="test",namespace="default"} 2
+`,
+		},
+		{
+			name: "created",
+			status: &v1alpha2.CapsuleStatus{
+				Deployment: &v1alpha2.DeploymentStatus{State: "created"},
+				OwnedResources: []v1alpha2.OwnedResource{
+					owned("Deployment", "created"),
+					owned("Service", "created"),
+					owned("Service", "created"),
+				},
+			},
+			expectedDeploymentState: deploymentStateHeader + `
+rig_capsule_deployment_state{capsule="test",namespace="default",state="created"} 1
+rig_capsule_deployment_state{capsule="test",namespace="default",state="failed"} 0
+rig_capsule_deployment_state{capsule="test",namespace="default",state="progressing"} 0
+`,
+			expectedOwnedResources: ownedResourcesHeader + `
+rig_capsule_owned_resources{capsule="test",kind="Deployment",namespace="default",state="created"} 1
+rig_capsule_owned_resources{capsule="test",kind="Service",namespace="default",state="created"} 2
+`,
+			expectedUsedResourcesMissing: usedResourcesMissingHeader + `
+rig_capsule_used_resources_missing{capsule="test",namespace="default"} 0
+`,
+		},
+		{
+			name: "no deployment",
+			status: &v1alpha2.CapsuleStatus{
+				OwnedResources: []v1alpha2.OwnedResource{owned("Service", "created")},
+			},
+			expectedOwnedResources: ownedResourcesHeader + `
+rig_capsule_owned_resources{capsule="test",kind="Service",namespace="default",state="created"} 1
+`,
+			expectedUsedResourcesMissing: usedResourcesMissingHeader + `
+rig_capsule_used_resources_missing{capsule="test",namespace="default"} 0
+`,
+		},
+		{
+			name: "deleted",
+		},
+	}
+
+	capsuleDeploymentState.Reset()
+	capsuleOwnedResources.Reset()
+	capsuleUsedResourcesMissing.Reset()
+
+	for _, tt := range tests {
+		if tt.status != nil {
+			observeCapsuleStatus(capsule, tt.status)
+		} else {
+			deleteCapsuleMetrics(capsule.Namespace, capsule.Name)
+		}
+
+		require.NoError(t, testutil.CollectAndCompare(
+			capsuleDeploymentState, strings.NewReader(tt.expectedDeploymentState),
+		), tt.name)
+		require.NoError(t, testutil.CollectAndCompare(
+			capsuleOwnedResources, strings.NewReader(tt.expectedOwnedResources),
+		), tt.name)
+		require.NoError(t, testutil.CollectAndCompare(
+			capsuleUsedResourcesMissing, strings.NewReader(tt.expectedUsedResourcesMissing),
+		), tt.name)
+	}
+}
@@ -0,0 +1,418 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	monitorv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/rigdev/rig/pkg/api/v1alpha2"
+	"github.com/rigdev/rig/pkg/ptr"
+	"k8s.io/apimachinery/pkg/api/equality"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	monitorKindServiceMonitor = "ServiceMonitor"
+	monitorKindPodMonitor     = "PodMonitor"
+
+	// metricsPortName is the name of the container port added for a
+	// PodMonitor scraping a port which is not an interface of the capsule.
+	metricsPortName = "rig-metrics"
+)
+
+// metricsEndpoint is the resolved scrape configuration of a capsule.
+type metricsEndpoint struct {
+	kind                 string
+	port                 string
+	path                 string
+	interval             monitorv1.Duration
+	relabelConfigs       []*monitorv1.RelabelConfig
+	metricRelabelConfigs []*monitorv1.RelabelConfig
+}
+
+// getMetricsEndpoint resolves how the metrics of the capsule should be
+// scraped, from the capsule and the operator config. It returns nil if the
+// metrics of the capsule should not be scraped.
+func (r *CapsuleReconciler) getMetricsEndpoint(capsule *v1alpha2.Capsule) *metricsEndpoint {
+	cfg := r.Config.Get().PrometheusServiceMonitor
+	m := capsule.Spec.Monitoring
+	if m == nil {
+		if cfg == nil || cfg.PortName == "" {
+			return nil
+		}
+		return &metricsEndpoint{
+			kind: monitorKindServiceMonitor,
+			port: cfg.PortName,
+			path: cfg.Path,
+		}
+	}
+	if m.Disabled {
+		return nil
+	}
+
+	e := &metricsEndpoint{
+		kind: m.Kind,
+	}
+	if e.kind == "" {
+		e.kind = monitorKindServiceMonitor
+	}
+
+	if cfg != nil {
+		e.port = cfg.PortName
+		e.path = cfg.Path
+	}
+	if m.Port != nil {
+		e.port = metricsPortFor(capsule, *m.Port)
+	}
+	if m.Path != "" {
+		e.path = m.Path
+	}
+	if e.port == "" {
+		return nil
+	}
+
+	if m.Interval != nil {
+		e.interval = prometheusDuration(m.Interval.Duration)
+	}
+	for _, rc := range m.RelabelConfigs {
+		e.relabelConfigs = append(e.relabelConfigs, rc.DeepCopy())
+	}
+	for _, rc := range m.MetricRelabelConfigs {
+		e.metricRelabelConfigs = append(e.metricRelabelConfigs, rc.DeepCopy())
+	}
+
+	return e
+}
+
+// metricsPortFor returns the name of the container port to scrape for the
+// given port name or number.
+func metricsPortFor(capsule *v1alpha2.Capsule, port intstr.IntOrString) string {
+	if port.Type == intstr.String {
+		return port.StrVal
+	}
+	for _, inf := range capsule.Spec.Interfaces {
+		if inf.Port == port.IntVal {
+			return inf.Name
+		}
+	}
+	return metricsPortName
+}
+
+// extraMetricsPort returns the port number of the container port which
+// should be added for a PodMonitor, or 0 if none is needed.
+func extraMetricsPort(capsule *v1alpha2.Capsule) int32 {
+	m := capsule.Spec.Monitoring
+	if m == nil || m.Disabled || m.Kind != monitorKindPodMonitor || m.Port == nil {
+		return 0
+	}
+	if metricsPortFor(capsule, *m.Port) != metricsPortName {
+		return 0
+	}
+	return m.Port.IntVal
+}
+
+func (r *CapsuleReconciler) reconcilePrometheusServiceMonitor(
+	ctx context.Context,
+	_ ctrl.Request,
+	log logr.Logger,
+	capsule *v1alpha2.Capsule,
+	status *v1alpha2.CapsuleStatus,
+) error {
+	endpoint := r.getMetricsEndpoint(capsule)
+	shouldHaveServiceMonitor := endpoint != nil && endpoint.kind == monitorKindServiceMonitor
+
+	existingServiceMonitor := &monitorv1.ServiceMonitor{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(capsule), existingServiceMonitor); err != nil {
+		if !shouldHaveServiceMonitor && (kerrors.IsNotFound(err) || meta.IsNoMatchError(err)) {
+			return nil
+		}
+		if !kerrors.IsNotFound(err) {
+			return fmt.Errorf("could not fetch prometheus service monitor: %w", err)
+		}
+
+		serviceMonitor, err := r.createPrometheusServiceMonitor(capsule, r.Scheme, endpoint)
+		if err != nil {
+			return err
+		}
+		log.Info("creating prometheus service monitor")
+		if err := r.createOwned(ctx, capsule, serviceMonitor); err != nil {
+			return fmt.Errorf("could not create prometheus service monitor: %w", err)
+		}
+		return nil
+	}
+
+	if !IsOwnedBy(capsule, existingServiceMonitor) {
+		if shouldHaveServiceMonitor {
+			log.Info("Found existing prometheus service monitor not owned by capsule. Will not update it.")
+			r.recordNotOwned(capsule, existingServiceMonitor)
+			return errors.New("found existing prometheus service monitor not owned by capsule")
+		}
+		log.Info("Found existing prometheus service monitor not owned by capsule. Will not delete it.")
+		return nil
+	}
+
+	if !shouldHaveServiceMonitor {
+		log.Info("deleting prometheus service monitor")
+		if err := r.deleteOwned(ctx, capsule, existingServiceMonitor); err != nil {
+			return fmt.Errorf("could not delete prometheus service monitor: %w", err)
+		}
+		return nil
+	}
+
+	serviceMonitor, err := r.createPrometheusServiceMonitor(capsule, r.Scheme, endpoint)
+	if err != nil {
+		return err
+	}
+	return upsertIfNewer(
+		ctx, r,
+		existingServiceMonitor,
+		serviceMonitor,
+		log, capsule, status,
+		func(t1, t2 *monitorv1.ServiceMonitor) bool {
+			return equality.Semantic.DeepEqual(t1.Spec, t2.Spec)
+		},
+	)
+}
+
+func (r *CapsuleReconciler) createPrometheusServiceMonitor(
+	capsule *v1alpha2.Capsule,
+	scheme *runtime.Scheme,
+	endpoint *metricsEndpoint,
+) (*monitorv1.ServiceMonitor, error) {
+	s := &monitorv1.ServiceMonitor{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      capsule.Name,
+			Namespace: capsule.Namespace,
+		},
+		Spec: monitorv1.ServiceMonitorSpec{
+			Selector: metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					LabelCapsule: capsule.Name,
+				},
+			},
+			Endpoints: []monitorv1.Endpoint{{
+				Port:                 endpoint.port,
+				Path:                 endpoint.path,
+				Interval:             endpoint.interval,
+				RelabelConfigs:       endpoint.relabelConfigs,
+				MetricRelabelConfigs: endpoint.metricRelabelConfigs,
+			}},
+		},
+	}
+	if err := controllerutil.SetControllerReference(capsule, s, scheme); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (r *CapsuleReconciler) reconcilePrometheusPodMonitor(
+	ctx context.Context,
+	_ ctrl.Request,
+	log logr.Logger,
+	capsule *v1alpha2.Capsule,
+	status *v1alpha2.CapsuleStatus,
+) error {
+	endpoint := r.getMetricsEndpoint(capsule)
+	shouldHavePodMonitor := endpoint != nil && endpoint.kind == monitorKindPodMonitor
+
+	existingPodMonitor := &monitorv1.PodMonitor{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(capsule), existingPodMonitor); err != nil {
+		if !shouldHavePodMonitor && (kerrors.IsNotFound(err) || meta.IsNoMatchError(err)) {
+			return nil
+		}
+		if !kerrors.IsNotFound(err) {
+			return fmt.Errorf("could not fetch prometheus pod monitor: %w", err)
+		}
+
+		podMonitor, err := createPrometheusPodMonitor(capsule, r.Scheme, endpoint)
+		if err != nil {
+			return err
+		}
+		log.Info("creating prometheus pod monitor")
+		if err := r.createOwned(ctx, capsule, podMonitor); err != nil {
+			return fmt.Errorf("could not create prometheus pod monitor: %w", err)
+		}
+		return nil
+	}
+
+	if !IsOwnedBy(capsule, existingPodMonitor) {
+		if shouldHavePodMonitor {
+			log.Info("Found existing prometheus pod monitor not owned by capsule. Will not update it.")
+			r.recordNotOwned(capsule, existingPodMonitor)
+			return errors.New("found existing prometheus pod monitor not owned by capsule")
+		}
+		log.Info("Found existing prometheus pod monitor not owned by capsule. Will not delete it.")
+		return nil
+	}
+
+	if !shouldHavePodMonitor {
+		log.Info("deleting prometheus pod monitor")
+		if err := r.deleteOwned(ctx, capsule, existingPodMonitor); err != nil {
+			return fmt.Errorf("could not delete prometheus pod monitor: %w", err)
+		}
+		return nil
+	}
+
+	podMonitor, err := createPrometheusPodMonitor(capsule, r.Scheme, endpoint)
+	if err != nil {
+		return err
+	}
+	return upsertIfNewer(
+		ctx, r,
+		existingPodMonitor,
+		podMonitor,
+		log, capsule, status,
+		func(t1, t2 *monitorv1.PodMonitor) bool {
+			return equality.Semantic.DeepEqual(t1.Spec, t2.Spec)
+		},
+	)
+}
+
+func createPrometheusPodMonitor(
+	capsule *v1alpha2.Capsule,
+	scheme *runtime.Scheme,
+	endpoint *metricsEndpoint,
+) (*monitorv1.PodMonitor, error) {
+	pm := &monitorv1.PodMonitor{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      capsule.Name,
+			Namespace: capsule.Namespace,
+		},
+		Spec: monitorv1.PodMonitorSpec{
+			Selector: metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					LabelCapsule: capsule.Name,
+				},
+			},
+			PodMetricsEndpoints: []monitorv1.PodMetricsEndpoint{{
+				Port:                 endpoint.port,
+				Path:                 endpoint.path,
+				Interval:             endpoint.interval,
+				RelabelConfigs:       endpoint.relabelConfigs,
+				MetricRelabelConfigs: endpoint.metricRelabelConfigs,
+			}},
+		},
+	}
+	if err := controllerutil.SetControllerReference(capsule, pm, scheme); err != nil {
+		return nil, err
+	}
+
+	return pm, nil
+}
+
+func (r *CapsuleReconciler) reconcilePrometheusRule(
+	ctx context.Context,
+	_ ctrl.Request,
+	log logr.Logger,
+	capsule *v1alpha2.Capsule,
+	status *v1alpha2.CapsuleStatus,
+) error {
+	shouldHaveRule := capsule.Spec.Monitoring != nil && len(capsule.Spec.Monitoring.AlertRules) > 0
+
+	existingRule := &monitorv1.PrometheusRule{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(capsule), existingRule); err != nil {
+		if !shouldHaveRule && (kerrors.IsNotFound(err) || meta.IsNoMatchError(err)) {
+			return nil
+		}
+		if !kerrors.IsNotFound(err) {
+			return fmt.Errorf("could not fetch prometheus rule: %w", err)
+		}
+
+		rule, err := createPrometheusRule(capsule, r.Scheme)
+		if err != nil {
+			return err
+		}
+		log.Info("creating prometheus rule")
+		if err := r.createOwned(ctx, capsule, rule); err != nil {
+			return fmt.Errorf("could not create prometheus rule: %w", err)
+		}
+		return nil
+	}
+
+	if !IsOwnedBy(capsule, existingRule) {
+		if shouldHaveRule {
+			log.Info("Found existing prometheus rule not owned by capsule. Will not update it.")
+			r.recordNotOwned(capsule, existingRule)
+			return errors.New("found existing prometheus rule not owned by capsule")
+		}
+		log.Info("Found existing prometheus rule not owned by capsule. Will not delete it.")
+		return nil
+	}
+
+	if !shouldHaveRule {
+		log.Info("deleting prometheus rule")
+		if err := r.deleteOwned(ctx, capsule, existingRule); err != nil {
+			return fmt.Errorf("could not delete prometheus rule: %w", err)
+		}
+		return nil
+	}
+
+	rule, err := createPrometheusRule(capsule, r.Scheme)
+	if err != nil {
+		return err
+	}
+	return upsertIfNewer(
+		ctx, r,
+		existingRule,
+		rule,
+		log, capsule, status,
+		func(t1, t2 *monitorv1.PrometheusRule) bool {
+			return equality.Semantic.DeepEqual(t1.Spec, t2.Spec)
+		},
+	)
+}
+
+func createPrometheusRule(capsule *v1alpha2.Capsule, scheme *runtime.Scheme) (*monitorv1.PrometheusRule, error) {
+	var rules []monitorv1.Rule
+	for _, ar := range capsule.Spec.Monitoring.AlertRules {
+		rule := monitorv1.Rule{
+			Alert:       ar.Name,
+			Expr:        intstr.FromString(ar.Expr),
+			Labels:      ar.Labels,
+			Annotations: ar.Annotations,
+		}
+		if ar.For != nil {
+			rule.For = ptr.New(prometheusDuration(ar.For.Duration))
+		}
+		rules = append(rules, rule)
+	}
+
+	pr := &monitorv1.PrometheusRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      capsule.Name,
+			Namespace: capsule.Namespace,
+			Labels: map[string]string{
+				LabelCapsule: capsule.Name,
+			},
+		},
+		Spec: monitorv1.PrometheusRuleSpec{
+			Groups: []monitorv1.RuleGroup{{
+				Name:  capsule.Name,
+				Rules: rules,
+			}},
+		},
+	}
+	if err := controllerutil.SetControllerReference(capsule, pr, scheme); err != nil {
+		return nil, err
+	}
+
+	return pr, nil
+}
+
+// prometheusDuration formats the duration as whole seconds, as Prometheus
+// does not support fractional durations or durations of mixed units such as
+// 1h0m0s.
+func prometheusDuration(d time.Duration) monitorv1.Duration {
+	return monitorv1.Duration(fmt.Sprintf("%ds", int64(d/time.Second)))
+}
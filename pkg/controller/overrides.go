@@ -0,0 +1,27 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/rigdev/rig/pkg/api/v1alpha2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// applyOverrides applies the overrides of the capsule of the given kind to
+// the object built for the capsule. The overrides are checked against the
+// operator config, as the webhook may be disabled.
+func (r *CapsuleReconciler) applyOverrides(capsule *v1alpha2.Capsule, kind string, obj client.Object) error {
+	cfg := r.Config.Get().Overrides
+	for i, o := range capsule.Spec.Overrides {
+		if o.Kind != kind {
+			continue
+		}
+		if err := o.CheckAllowed(cfg); err != nil {
+			return fmt.Errorf("override %d: %w", i, err)
+		}
+		if err := o.Apply(obj); err != nil {
+			return fmt.Errorf("override %d: %w", i, err)
+		}
+	}
+	return nil
+}
@@ -1,7 +1,18 @@
 package controller
 
 import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rigdev/rig/pkg/api/v1alpha2"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
 func IsOwnedBy(owner metav1.Object, obj metav1.Object) bool {
@@ -14,3 +25,99 @@ func IsOwnedBy(owner metav1.Object, obj metav1.Object) bool {
 	}
 	return false
 }
+
+// kindOf returns the kind of the object, as registered in the scheme.
+func kindOf(scheme *runtime.Scheme, obj client.Object) string {
+	gvks, _, err := scheme.ObjectKinds(obj)
+	if err != nil || len(gvks) == 0 {
+		return fmt.Sprintf("%T", obj)
+	}
+	return gvks[0].Kind
+}
+
+// upsertOwned creates or updates an object controlled by the owner, and
+// records it in owned. mutate sets the desired state on the object, which
+// holds the existing state if the object exists. The object is labelled with
+// the label holding the name of the owner. An existing object not owned by
+// the owner is not updated.
+func upsertOwned(
+	ctx context.Context,
+	c client.Client,
+	scheme *runtime.Scheme,
+	owner client.Object,
+	label string,
+	owned *[]v1alpha2.OwnedResource,
+	obj client.Object,
+	mutate func(),
+) error {
+	kind := kindOf(scheme, obj)
+	res := v1alpha2.OwnedResource{
+		Ref: &v1.TypedLocalObjectReference{
+			Kind: kind,
+			Name: obj.GetName(),
+		},
+		State: "created",
+	}
+	defer func() {
+		*owned = append(*owned, res)
+	}()
+
+	_, err := controllerutil.CreateOrUpdate(ctx, c, obj, func() error {
+		if obj.GetUID() != "" && !IsOwnedBy(owner, obj) {
+			return fmt.Errorf("found existing %s %s not owned by %s %s",
+				kind, obj.GetName(), strings.ToLower(kindOf(scheme, owner)), owner.GetName())
+		}
+		mutate()
+		l := obj.GetLabels()
+		if l == nil {
+			l = map[string]string{}
+		}
+		l[label] = owner.GetName()
+		obj.SetLabels(l)
+		return controllerutil.SetControllerReference(owner, obj, scheme)
+	})
+	if err != nil {
+		res.State = "failed"
+		res.Message = err.Error()
+		return err
+	}
+	return nil
+}
+
+// deleteStaleOwned deletes the objects of the list in the namespace which
+// are labelled with the name of the owner and controlled by it, but are no
+// longer desired.
+func deleteStaleOwned(
+	ctx context.Context,
+	c client.Client,
+	owner client.Object,
+	label string,
+	namespace string,
+	list client.ObjectList,
+	desired map[string]struct{},
+) error {
+	if err := c.List(ctx, list,
+		client.InNamespace(namespace),
+		client.MatchingLabels{label: owner.GetName()},
+	); err != nil {
+		return err
+	}
+
+	objs, err := meta.ExtractList(list)
+	if err != nil {
+		return err
+	}
+	for _, o := range objs {
+		obj, ok := o.(client.Object)
+		if !ok || !IsOwnedBy(owner, obj) {
+			continue
+		}
+		if _, ok := desired[obj.GetName()]; ok {
+			continue
+		}
+		if err := c.Delete(ctx, obj); err != nil && !kerrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
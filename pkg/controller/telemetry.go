@@ -0,0 +1,127 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/distribution/reference"
+	configv1alpha1 "github.com/rigdev/rig/pkg/api/config/v1alpha1"
+	"github.com/rigdev/rig/pkg/api/v1alpha2"
+	v1 "k8s.io/api/core/v1"
+)
+
+// AnnotationRolloutID is the capsule annotation holding the ID of the rollout
+// which created the current version of the capsule.
+const AnnotationRolloutID = "rig.dev/rollout-id"
+
+// telemetryEnv returns the OTEL_* environment variables injected into the
+// container of the capsule. Variables already provided by the env sources of
+// the capsule are not overwritten.
+func telemetryEnv(
+	capsule *v1alpha2.Capsule,
+	cfg *configv1alpha1.TelemetryConfig,
+	envFrom []v1.EnvFromSource,
+	configs *configs,
+) []v1.EnvVar {
+	if cfg == nil || (capsule.Spec.Env != nil && capsule.Spec.Env.DisableTelemetry) {
+		return nil
+	}
+
+	attributes := map[string]string{}
+	for k, v := range cfg.ResourceAttributes {
+		attributes[k] = v
+	}
+	attributes["service.namespace"] = capsule.Namespace
+	if version := imageVersion(capsule.Spec.Image); version != "" {
+		attributes["service.version"] = version
+	}
+	if id := capsule.GetAnnotations()[AnnotationRolloutID]; id != "" {
+		attributes["rig.rollout.id"] = id
+	}
+
+	keys := make([]string, 0, len(attributes))
+	for k := range attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + encodeAttributeValue(attributes[k])
+	}
+
+	env := []v1.EnvVar{
+		{Name: "OTEL_SERVICE_NAME", Value: capsule.Name},
+		{Name: "OTEL_EXPORTER_OTLP_ENDPOINT", Value: cfg.Endpoint},
+		{Name: "OTEL_EXPORTER_OTLP_PROTOCOL", Value: cfg.Protocol},
+		{Name: "OTEL_RESOURCE_ATTRIBUTES", Value: strings.Join(pairs, ",")},
+	}
+	if cfg.SamplingRatio != nil {
+		env = append(env,
+			v1.EnvVar{Name: "OTEL_TRACES_SAMPLER", Value: "parentbased_traceidratio"},
+			v1.EnvVar{Name: "OTEL_TRACES_SAMPLER_ARG", Value: strconv.FormatFloat(*cfg.SamplingRatio, 'f', -1, 64)},
+		)
+	}
+
+	provided := envFromKeys(envFrom, configs)
+	var res []v1.EnvVar
+	for _, e := range env {
+		if _, ok := provided[e.Name]; !ok {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+// encodeAttributeValue percent-encodes the characters of the value which are
+// not allowed in the values of OTEL_RESOURCE_ATTRIBUTES, which follow the W3C
+// Baggage format.
+func encodeAttributeValue(v string) string {
+	var b strings.Builder
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		if c > ' ' && c < 0x7f && !strings.ContainsRune(`",;\%`, rune(c)) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// imageVersion returns the tag of the image, or an empty string if the image
+// has no tag.
+func imageVersion(image string) string {
+	ref, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return ""
+	}
+	if tagged, ok := ref.(reference.Tagged); ok {
+		return tagged.Tag()
+	}
+	return ""
+}
+
+// envFromKeys returns the names of the environment variables provided by the
+// given env sources.
+func envFromKeys(envFrom []v1.EnvFromSource, configs *configs) map[string]struct{} {
+	keys := map[string]struct{}{}
+	for _, e := range envFrom {
+		switch {
+		case e.ConfigMapRef != nil:
+			if cm, ok := configs.configMaps[e.ConfigMapRef.Name]; ok {
+				for k := range cm.Data {
+					keys[e.Prefix+k] = struct{}{}
+				}
+			}
+		case e.SecretRef != nil:
+			if s, ok := configs.secrets[e.SecretRef.Name]; ok {
+				for k := range s.Data {
+					keys[e.Prefix+k] = struct{}{}
+				}
+			}
+		}
+	}
+	return keys
+}
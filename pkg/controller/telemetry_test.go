@@ -0,0 +1,161 @@
+package controller
+
+import (
+	"testing"
+
+	configv1alpha1 "github.com/rigdev/rig/pkg/api/config/v1alpha1"
+	"github.com/rigdev/rig/pkg/api/v1alpha2"
+	"github.com/rigdev/rig/pkg/ptr"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_telemetryEnv(t *testing.T) {
+	t.Parallel()
+	cfg := &configv1alpha1.TelemetryConfig{
+		Endpoint: "http://otel-collector:4317",
+		Protocol: "grpc",
+		ResourceAttributes: map[string]string{
+			"deployment.environment": "prod, eu",
+		},
+	}
+	configs := &configs{
+		configMaps: map[string]*v1.ConfigMap{
+			"env": {Data: map[string]string{"OTEL_SERVICE_NAME": "custom"}},
+		},
+		secrets: map[string]*v1.Secret{
+			"secret": {Data: map[string][]byte{"ENDPOINT": []byte("http://custom:4317")}},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		capsule  *v1alpha2.Capsule
+		cfg      *configv1alpha1.TelemetryConfig
+		envFrom  []v1.EnvFromSource
+		expected []v1.EnvVar
+	}{
+		{
+			name:    "no telemetry config",
+			capsule: &v1alpha2.Capsule{},
+		},
+		{
+			name: "telemetry disabled",
+			capsule: &v1alpha2.Capsule{
+				Spec: v1alpha2.CapsuleSpec{Env: &v1alpha2.Env{DisableTelemetry: true}},
+			},
+			cfg: cfg,
+		},
+		{
+			name: "all variables",
+			capsule: &v1alpha2.Capsule{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test",
+					Namespace:   "default",
+					Annotations: map[string]string{AnnotationRolloutID: "42"},
+				},
+				Spec: v1alpha2.CapsuleSpec{Image: "nginx:1.25.1"},
+			},
+			cfg: &configv1alpha1.TelemetryConfig{
+				Endpoint:           cfg.Endpoint,
+				Protocol:           cfg.Protocol,
+				SamplingRatio:      ptr.New(0.25),
+				ResourceAttributes: cfg.ResourceAttributes,
+			},
+			expected: []v1.EnvVar{
+				{Name: "OTEL_SERVICE_NAME", Value: "test"},
+				{Name: "OTEL_EXPORTER_OTLP_ENDPOINT", Value: "http://otel-collector:4317"},
+				{Name: "OTEL_EXPORTER_OTLP_PROTOCOL", Value: "grpc"},
+				{
+					Name: "OTEL_RESOURCE_ATTRIBUTES",
+					Value: "deployment.environment=prod%2C%20eu,rig.rollout.id=42," +
+						"service.namespace=default,service.version=1.25.1",
+				},
+				{Name: "OTEL_TRACES_SAMPLER", Value: "parentbased_traceidratio"},
+				{Name: "OTEL_TRACES_SAMPLER_ARG", Value: "0.25"},
+			},
+		},
+		{
+			name: "variables provided by env sources take precedence",
+			capsule: &v1alpha2.Capsule{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+				Spec:       v1alpha2.CapsuleSpec{Image: "nginx"},
+			},
+			cfg: cfg,
+			envFrom: []v1.EnvFromSource{
+				{ConfigMapRef: &v1.ConfigMapEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: "env"}}},
+				{
+					Prefix:    "OTEL_EXPORTER_OTLP_",
+					SecretRef: &v1.SecretEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: "secret"}},
+				},
+			},
+			expected: []v1.EnvVar{
+				{Name: "OTEL_EXPORTER_OTLP_PROTOCOL", Value: "grpc"},
+				{
+					Name:  "OTEL_RESOURCE_ATTRIBUTES",
+					Value: "deployment.environment=prod%2C%20eu,service.namespace=default",
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.expected, telemetryEnv(tt.capsule, tt.cfg, tt.envFrom, configs))
+		})
+	}
+}
+
+func Test_encodeAttributeValue(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "plain-value_1.0", encodeAttributeValue("plain-value_1.0"))
+	assert.Equal(t, "a%2Cb%3Bc%20d%25e%22f%5Cg", encodeAttributeValue(`a,b;c d%e"f\g`))
+	assert.Equal(t, "%C3%A6", encodeAttributeValue("æ"))
+}
+
+func Test_imageVersion(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		image    string
+		expected string
+	}{
+		{image: "nginx"},
+		{image: "nginx:1.25.1", expected: "1.25.1"},
+		{image: "registry.example.com:5000/team/app:v2", expected: "v2"},
+		{image: "nginx@sha256:" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"},
+		{image: "Invalid Image"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.image, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.expected, imageVersion(tt.image))
+		})
+	}
+}
+
+func Test_envFromKeys(t *testing.T) {
+	t.Parallel()
+	configs := &configs{
+		configMaps: map[string]*v1.ConfigMap{
+			"cm": {Data: map[string]string{"A": "a"}},
+		},
+		secrets: map[string]*v1.Secret{
+			"secret": {Data: map[string][]byte{"B": []byte("b")}},
+		},
+	}
+	envFrom := []v1.EnvFromSource{
+		{ConfigMapRef: &v1.ConfigMapEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: "cm"}}},
+		{
+			Prefix:    "P_",
+			SecretRef: &v1.SecretEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: "secret"}},
+		},
+		{ConfigMapRef: &v1.ConfigMapEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: "missing"}}},
+	}
+
+	assert.Equal(t, map[string]struct{}{"A": {}, "P_B": {}}, envFromKeys(envFrom, configs))
+}
@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rigdev/rig/pkg/api/v1alpha2"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	fieldTemplateName        = ".spec.template.name"
+	fieldClusterTemplateName = ".spec.template.clusterName"
+)
+
+func indexTemplateName(o client.Object) []string {
+	capsule := o.(*v1alpha2.Capsule)
+	if t := capsule.Spec.Template; t != nil && t.GetKind() == v1alpha2.TemplateKindCapsuleTemplate {
+		return []string{t.Name}
+	}
+	return nil
+}
+
+func indexClusterTemplateName(o client.Object) []string {
+	capsule := o.(*v1alpha2.Capsule)
+	if t := capsule.Spec.Template; t != nil && t.GetKind() == v1alpha2.TemplateKindClusterCapsuleTemplate {
+		return []string{t.Name}
+	}
+	return nil
+}
+
+// applyTemplate returns the capsule merged onto its template, with the
+// capsule defaults of the operator config applied. A capsule without a
+// template is returned as is. A missing template is reported as a missing
+// used resource, and the capsule is not reconciled until it is created.
+func (r *CapsuleReconciler) applyTemplate(
+	ctx context.Context,
+	capsule *v1alpha2.Capsule,
+	status *v1alpha2.CapsuleStatus,
+) (*v1alpha2.Capsule, error) {
+	ref := capsule.Spec.Template
+	if ref == nil {
+		return capsule, nil
+	}
+
+	used := v1alpha2.UsedResource{
+		Ref: &v1.TypedLocalObjectReference{
+			Kind: ref.GetKind(),
+			Name: ref.Name,
+		},
+		State: "found",
+	}
+
+	t, err := capsule.GetTemplate(ctx, r)
+	if kerrors.IsNotFound(err) {
+		used.State = "missing"
+		status.UsedResources = append(status.UsedResources, used)
+		r.Recorder.Eventf(capsule, v1.EventTypeWarning, EventReasonMissingTemplate, "%s %s not found",
+			ref.GetKind(), ref.Name)
+		return nil, fmt.Errorf("%s %s not found", ref.GetKind(), ref.Name)
+	} else if err != nil {
+		used.State = "error"
+		used.Message = err.Error()
+		status.UsedResources = append(status.UsedResources, used)
+		r.Recorder.Eventf(capsule, v1.EventTypeWarning, EventReasonFailed, "Could not get %s %s: %v",
+			ref.GetKind(), ref.Name, err)
+		return nil, fmt.Errorf("could not fetch %s %s: %w", ref.GetKind(), ref.Name, err)
+	}
+	status.UsedResources = append(status.UsedResources, used)
+
+	merged := capsule.WithTemplate(t)
+	if err := merged.Default(&r.Config.Get().Defaults); err != nil {
+		return nil, fmt.Errorf("could not apply capsule defaults: %w", err)
+	}
+	return merged, nil
+}
@@ -0,0 +1,573 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	cmv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	cmmetav1 "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
+	"github.com/rigdev/rig/pkg/api/v1alpha2"
+	"github.com/rigdev/rig/pkg/ptr"
+	"github.com/rigdev/rig/pkg/service/config"
+	"golang.org/x/exp/maps"
+	v1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	gwv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+const (
+	// LabelTrafficSplit is the label of resources created for a traffic
+	// split, holding the name of the traffic split.
+	LabelTrafficSplit = "rig.dev/traffic-split"
+
+	fieldTrafficSplitCapsule = ".spec.backends.capsule"
+
+	annotationNginxCanary       = "nginx.ingress.kubernetes.io/canary"
+	annotationNginxCanaryWeight = "nginx.ingress.kubernetes.io/canary-weight"
+)
+
+// TrafficSplitReconciler reconciles a TrafficSplit object
+type TrafficSplitReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	Config config.Service
+
+	// gatewaySupported is true if the Gateway API is installed in the
+	// cluster.
+	gatewaySupported bool
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *TrafficSplitReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(
+		context.Background(),
+		&v1alpha2.TrafficSplit{},
+		fieldTrafficSplitCapsule,
+		func(o client.Object) []string {
+			split := o.(*v1alpha2.TrafficSplit)
+			var capsules []string
+			for _, b := range split.Spec.Backends {
+				capsules = append(capsules, b.Capsule)
+			}
+			return capsules
+		},
+	); err != nil {
+		return fmt.Errorf("could not setup indexer for %s: %w", fieldTrafficSplitCapsule, err)
+	}
+
+	_, err := mgr.GetRESTMapper().RESTMapping(
+		schema.GroupKind{Group: gwv1beta1.GroupName, Kind: "HTTPRoute"},
+		gwv1beta1.GroupVersion.Version,
+	)
+	r.gatewaySupported = err == nil
+
+	// The ports of the interfaces are resolved from the services of the
+	// capsules.
+	serviceHandler := handler.EnqueueRequestsFromMapFunc(r.findTrafficSplitsForCapsule)
+
+	// Ingresses of capsules may publish the host of a traffic split.
+	ingressHandler := handler.EnqueueRequestsFromMapFunc(r.findTrafficSplitsForCapsuleIngress)
+
+	// Traffic splits are rendered from the ingress and cert-manager config.
+	requeuer := newConfigRequeuer(mgr.GetClient(), mgr.GetLogger(), func() client.ObjectList {
+		return &v1alpha2.TrafficSplitList{}
+	})
+	r.Config.OnChange(requeuer.onConfigChange)
+	if err := mgr.Add(requeuer); err != nil {
+		return fmt.Errorf("could not add config requeuer: %w", err)
+	}
+
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha2.TrafficSplit{}).
+		Owns(&netv1.Ingress{}).
+		Watches(&v1.Service{}, serviceHandler).
+		Watches(&netv1.Ingress{}, ingressHandler).
+		// Certificates enqueue all their owners, as wildcard certificates are
+		// shared without a controller.
+		Watches(
+			&cmv1.Certificate{},
+			handler.EnqueueRequestForOwner(mgr.GetScheme(), mgr.GetRESTMapper(), &v1alpha2.TrafficSplit{}),
+		).
+		WatchesRawSource(
+			&source.Channel{Source: requeuer.events},
+			&handler.EnqueueRequestForObject{},
+		)
+	if r.gatewaySupported {
+		b = b.Owns(&gwv1beta1.HTTPRoute{})
+	}
+	return b.Complete(r)
+}
+
+func (r *TrafficSplitReconciler) findTrafficSplitsForCapsule(ctx context.Context, o client.Object) []ctrl.Request {
+	var splits v1alpha2.TrafficSplitList
+	if err := r.List(ctx, &splits, &client.ListOptions{
+		Namespace:     o.GetNamespace(),
+		FieldSelector: fields.SelectorFromSet(fields.Set{fieldTrafficSplitCapsule: o.GetName()}),
+	}); err != nil {
+		log.FromContext(ctx).Error(err, "could not list traffic splits referencing capsule", "capsule", o.GetName())
+		return nil
+	}
+
+	requests := make([]ctrl.Request, len(splits.Items))
+	for i, split := range splits.Items {
+		requests[i] = ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&split)}
+	}
+	return requests
+}
+
+// findTrafficSplitsForCapsuleIngress returns all traffic splits in the
+// namespace of an Ingress of a capsule, as the hosts of the Ingress before an
+// update are not known.
+func (r *TrafficSplitReconciler) findTrafficSplitsForCapsuleIngress(
+	ctx context.Context,
+	o client.Object,
+) []ctrl.Request {
+	if !isCapsuleIngress(o) {
+		return nil
+	}
+
+	var splits v1alpha2.TrafficSplitList
+	if err := r.List(ctx, &splits, client.InNamespace(o.GetNamespace())); err != nil {
+		log.FromContext(ctx).Error(err, "could not list traffic splits", "namespace", o.GetNamespace())
+		return nil
+	}
+
+	requests := make([]ctrl.Request, len(splits.Items))
+	for i, split := range splits.Items {
+		requests[i] = ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&split)}
+	}
+	return requests
+}
+
+// isCapsuleIngress returns true if the object is controlled by a capsule.
+func isCapsuleIngress(o client.Object) bool {
+	ref := metav1.GetControllerOf(o)
+	return ref != nil && ref.Kind == "Capsule" && ref.APIVersion == v1alpha2.GroupVersion.String()
+}
+
+//+kubebuilder:rbac:groups=rig.dev,resources=trafficsplits,verbs=get;list;watch
+//+kubebuilder:rbac:groups=rig.dev,resources=trafficsplits/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=cert-manager.io,resources=certificates,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes,verbs=get;list;watch;create;update;patch;delete
+
+// Reconcile renders the TrafficSplit as ingress-nginx canary Ingresses or a
+// Gateway API HTTPRoute, depending on its provider, and deletes the resources
+// of the other provider.
+func (r *TrafficSplitReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	log.Info("reconciliation started")
+
+	split := &v1alpha2.TrafficSplit{}
+	if err := r.Get(ctx, req.NamespacedName, split); err != nil {
+		if kerrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, fmt.Errorf("could not fetch TrafficSplit: %w", err)
+	}
+
+	status := &v1alpha2.TrafficSplitStatus{State: "ready"}
+
+	var err error
+	switch split.GetProvider() {
+	case v1alpha2.TrafficSplitProviderNginx:
+		err = errors.Join(
+			r.reconcileIngresses(ctx, split, status),
+			r.reconcileCertificate(ctx, split, status, true),
+			r.deleteStaleHTTPRoutes(ctx, split, nil),
+		)
+	case v1alpha2.TrafficSplitProviderGateway:
+		err = errors.Join(
+			r.reconcileHTTPRoute(ctx, split, status),
+			r.reconcileCertificate(ctx, split, status, false),
+			r.deleteStale(ctx, split, &netv1.IngressList{}, nil),
+		)
+	default:
+		err = fmt.Errorf("unsupported provider %s", split.Spec.Provider)
+	}
+
+	warnings, wErr := r.warnings(ctx, split)
+	err = errors.Join(err, wErr)
+	status.Warnings = warnings
+
+	if err != nil {
+		status.State = "failed"
+		status.Message = err.Error()
+	} else {
+		status.ObservedGeneration = split.GetGeneration()
+	}
+
+	split.Status = status
+	if err := r.Status().Update(ctx, split); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, err
+}
+
+// warnings returns the problems which may keep the traffic split from
+// splitting the traffic of its host.
+func (r *TrafficSplitReconciler) warnings(ctx context.Context, split *v1alpha2.TrafficSplit) ([]string, error) {
+	var warnings []string
+
+	className := r.Config.Get().Ingress.ClassName
+	if split.GetProvider() == v1alpha2.TrafficSplitProviderNginx &&
+		className != "" && !strings.Contains(className, "nginx") {
+		warnings = append(warnings, fmt.Sprintf(
+			"the Nginx provider requires ingress-nginx, but Ingresses have the class %s", className,
+		))
+	}
+
+	var ingresses netv1.IngressList
+	if err := r.List(ctx, &ingresses, client.InNamespace(split.Namespace)); err != nil {
+		return warnings, fmt.Errorf("could not list ingresses: %w", err)
+	}
+	for _, ing := range ingresses.Items {
+		if !isCapsuleIngress(&ing) {
+			continue
+		}
+		for _, rule := range ing.Spec.Rules {
+			if rule.Host == split.Spec.Host {
+				warnings = append(warnings, fmt.Sprintf(
+					"the Ingress %s of capsule %s also publishes the host %s",
+					ing.Name, metav1.GetControllerOf(&ing).Name, split.Spec.Host,
+				))
+				break
+			}
+		}
+	}
+
+	return warnings, nil
+}
+
+// upsert creates or updates an object owned by the traffic split.
+func (r *TrafficSplitReconciler) upsert(
+	ctx context.Context,
+	split *v1alpha2.TrafficSplit,
+	status *v1alpha2.TrafficSplitStatus,
+	obj client.Object,
+	mutate func(),
+) error {
+	return upsertOwned(ctx, r.Client, r.Scheme, split, LabelTrafficSplit, &status.OwnedResources, obj, mutate)
+}
+
+// deleteStale deletes the objects of the list which were created for the
+// traffic split, but are no longer desired.
+func (r *TrafficSplitReconciler) deleteStale(
+	ctx context.Context,
+	split *v1alpha2.TrafficSplit,
+	list client.ObjectList,
+	desired map[string]struct{},
+) error {
+	return deleteStaleOwned(ctx, r.Client, split, LabelTrafficSplit, split.Namespace, list, desired)
+}
+
+func (r *TrafficSplitReconciler) deleteStaleHTTPRoutes(
+	ctx context.Context,
+	split *v1alpha2.TrafficSplit,
+	desired map[string]struct{},
+) error {
+	if !r.gatewaySupported {
+		return nil
+	}
+	return r.deleteStale(ctx, split, &gwv1beta1.HTTPRouteList{}, desired)
+}
+
+// getBackendPort returns the port of the interface of the backend, as
+// exposed by the service of its capsule.
+func (r *TrafficSplitReconciler) getBackendPort(
+	ctx context.Context,
+	split *v1alpha2.TrafficSplit,
+	backend v1alpha2.TrafficSplitBackend,
+) (int32, error) {
+	svc := &v1.Service{}
+	key := types.NamespacedName{Namespace: split.Namespace, Name: backend.Capsule}
+	if err := r.Get(ctx, key, svc); err != nil {
+		if kerrors.IsNotFound(err) {
+			return 0, fmt.Errorf("capsule %s has no interfaces", backend.Capsule)
+		}
+		return 0, fmt.Errorf("could not get service of capsule %s: %w", backend.Capsule, err)
+	}
+	for _, p := range svc.Spec.Ports {
+		if p.Name == backend.Interface {
+			return p.Port, nil
+		}
+	}
+	return 0, fmt.Errorf("capsule %s has no interface %s", backend.Capsule, backend.Interface)
+}
+
+func (r *TrafficSplitReconciler) reconcileIngresses(
+	ctx context.Context,
+	split *v1alpha2.TrafficSplit,
+	status *v1alpha2.TrafficSplitStatus,
+) error {
+	// The webhook ensures there are exactly two backends.
+	if len(split.Spec.Backends) != 2 {
+		return fmt.Errorf("the Nginx provider requires exactly two backends")
+	}
+	for _, b := range split.Spec.Backends {
+		if _, err := r.getBackendPort(ctx, split, b); err != nil {
+			return err
+		}
+	}
+
+	primary := &netv1.Ingress{ObjectMeta: metav1.ObjectMeta{
+		Name:      split.Name,
+		Namespace: split.Namespace,
+	}}
+	canary := &netv1.Ingress{ObjectMeta: metav1.ObjectMeta{
+		Name:      fmt.Sprintf("%s-canary", split.Name),
+		Namespace: split.Namespace,
+	}}
+
+	err := errors.Join(
+		r.upsert(ctx, split, status, primary, func() {
+			r.setIngress(split, split.Spec.Backends[0], primary, false)
+		}),
+		r.upsert(ctx, split, status, canary, func() {
+			r.setIngress(split, split.Spec.Backends[1], canary, true)
+		}),
+	)
+	if err != nil {
+		return err
+	}
+
+	return r.deleteStale(ctx, split, &netv1.IngressList{}, map[string]struct{}{
+		primary.Name: {},
+		canary.Name:  {},
+	})
+}
+
+// setIngress sets the desired state of an Ingress routing the host of the
+// traffic split to the backend. A canary Ingress receives the weight of the
+// backend of the traffic of the host, and the rest goes to the primary.
+func (r *TrafficSplitReconciler) setIngress(
+	split *v1alpha2.TrafficSplit,
+	backend v1alpha2.TrafficSplitBackend,
+	ing *netv1.Ingress,
+	canary bool,
+) {
+	cfg := r.Config.Get()
+
+	// Copy the annotations, as the config is shared between reconciliations.
+	annotations := map[string]string{}
+	maps.Copy(annotations, cfg.Ingress.Annotations)
+	if canary {
+		annotations[annotationNginxCanary] = "true"
+		annotations[annotationNginxCanaryWeight] = strconv.Itoa(int(backend.Weight))
+	}
+
+	ing.Spec = netv1.IngressSpec{
+		Rules: []netv1.IngressRule{{
+			Host: split.Spec.Host,
+			IngressRuleValue: netv1.IngressRuleValue{
+				HTTP: &netv1.HTTPIngressRuleValue{
+					Paths: []netv1.HTTPIngressPath{{
+						PathType: ptr.New(netv1.PathTypePrefix),
+						Path:     "/",
+						Backend: netv1.IngressBackend{
+							Service: &netv1.IngressServiceBackend{
+								Name: backend.Capsule,
+								Port: netv1.ServiceBackendPort{Name: backend.Interface},
+							},
+						},
+					}},
+				},
+			},
+		}},
+	}
+	if cfg.Ingress.ClassName != "" {
+		ing.Spec.IngressClassName = ptr.New(cfg.Ingress.ClassName)
+	}
+
+	// ingress-nginx uses the TLS configuration of the primary Ingress for the
+	// canary.
+	if crt := r.certificate(split); crt != nil && !canary {
+		if !cfg.Certmanager.CreateCertificateResources {
+			annotations["cert-manager.io/cluster-issuer"] = crt.issuer.Name
+		}
+		ing.Spec.TLS = []netv1.IngressTLS{{
+			Hosts:      []string{split.Spec.Host},
+			SecretName: crt.secretName,
+		}}
+	}
+
+	// Annotations set by others on an existing Ingress are kept.
+	desired := &netv1.Ingress{ObjectMeta: metav1.ObjectMeta{Annotations: annotations}}
+	setManagedAnnotations(desired)
+	ing.SetAnnotations(mergeManagedAnnotations(ing.GetAnnotations(), desired.GetAnnotations()))
+}
+
+// certificate returns the certificate of the host of the traffic split, if
+// cert-manager is configured. Like for capsules, a host covered by a wildcard
+// certificate of the config uses the wildcard certificate.
+func (r *TrafficSplitReconciler) certificate(split *v1alpha2.TrafficSplit) *certificate {
+	cm := r.Config.Get().Certmanager
+	if cm == nil || cm.ClusterIssuer == "" {
+		return nil
+	}
+	if w, ok := wildcardFor(cm.Wildcards, split.Spec.Host); ok && cm.CreateCertificateResources {
+		return wildcardCertificate(cm, w)
+	}
+	return &certificate{
+		name:       split.Name,
+		secretName: fmt.Sprintf("%s-tls", split.Name),
+		issuer:     cmmetav1.ObjectReference{Kind: cmv1.ClusterIssuerKind, Name: cm.ClusterIssuer},
+		dnsNames:   []string{split.Spec.Host},
+	}
+}
+
+// reconcileCertificate creates the Certificate of the host of the traffic
+// split if the operator is configured to create certificate resources instead
+// of relying on the ingress-shim of cert-manager, and deletes Certificates
+// which are no longer used. tls is false if the provider doesn't terminate
+// TLS using the Certificate.
+func (r *TrafficSplitReconciler) reconcileCertificate(
+	ctx context.Context,
+	split *v1alpha2.TrafficSplit,
+	status *v1alpha2.TrafficSplitStatus,
+	tls bool,
+) error {
+	var crt *certificate
+	if cm := r.Config.Get().Certmanager; tls && cm != nil && cm.CreateCertificateResources {
+		crt = r.certificate(split)
+	}
+
+	desired := map[string]struct{}{}
+	var wildcard string
+	if crt != nil {
+		obj := createCertificate(split.Namespace, crt)
+		if crt.wildcard {
+			wildcard = crt.name
+			if _, _, err := upsertWildcardCertificate(ctx, r.Client, r.Scheme, split, obj); err != nil {
+				return err
+			}
+		} else {
+			desired[crt.name] = struct{}{}
+			spec := obj.Spec
+			if err := r.upsert(ctx, split, status, obj, func() {
+				obj.Spec = spec
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return errors.Join(
+		r.deleteStale(ctx, split, &cmv1.CertificateList{}, desired),
+		r.releaseWildcardCertificates(ctx, split, wildcard),
+	)
+}
+
+// releaseWildcardCertificates removes the traffic split from the owners of
+// the wildcard Certificates of its namespace, except the one it uses. A
+// wildcard Certificate without owners left is deleted.
+func (r *TrafficSplitReconciler) releaseWildcardCertificates(
+	ctx context.Context,
+	split *v1alpha2.TrafficSplit,
+	used string,
+) error {
+	list := &cmv1.CertificateList{}
+	if err := r.List(ctx, list,
+		client.InNamespace(split.Namespace),
+		client.HasLabels{LabelWildcardCertificate},
+	); err != nil {
+		return fmt.Errorf("could not list wildcard certificates: %w", err)
+	}
+
+	for i := range list.Items {
+		crt := &list.Items[i]
+		if crt.Name == used {
+			continue
+		}
+		refs, ok := withoutOwner(crt, split)
+		if !ok {
+			continue
+		}
+		if len(refs) == 0 {
+			if err := r.Delete(ctx, crt); err != nil && !kerrors.IsNotFound(err) {
+				return fmt.Errorf("could not delete wildcard certificate: %w", err)
+			}
+			continue
+		}
+		crt.SetOwnerReferences(refs)
+		if err := r.Update(ctx, crt); err != nil {
+			return fmt.Errorf("could not update wildcard certificate: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *TrafficSplitReconciler) reconcileHTTPRoute(
+	ctx context.Context,
+	split *v1alpha2.TrafficSplit,
+	status *v1alpha2.TrafficSplitStatus,
+) error {
+	if !r.gatewaySupported {
+		return fmt.Errorf("the Gateway provider requires the Gateway API to be installed in the cluster")
+	}
+	if split.Spec.Gateway == nil {
+		return fmt.Errorf("the Gateway provider requires a gateway")
+	}
+
+	var backendRefs []gwv1beta1.HTTPBackendRef
+	for _, b := range split.Spec.Backends {
+		port, err := r.getBackendPort(ctx, split, b)
+		if err != nil {
+			return err
+		}
+		backendRefs = append(backendRefs, gwv1beta1.HTTPBackendRef{
+			BackendRef: gwv1beta1.BackendRef{
+				BackendObjectReference: gwv1beta1.BackendObjectReference{
+					Name: gwv1beta1.ObjectName(b.Capsule),
+					Port: ptr.New(gwv1beta1.PortNumber(port)),
+				},
+				Weight: ptr.New(b.Weight),
+			},
+		})
+	}
+
+	parentRef := gwv1beta1.ParentReference{
+		Name: gwv1beta1.ObjectName(split.Spec.Gateway.Name),
+	}
+	if ns := split.Spec.Gateway.Namespace; ns != "" {
+		parentRef.Namespace = ptr.New(gwv1beta1.Namespace(ns))
+	}
+	if sn := split.Spec.Gateway.SectionName; sn != "" {
+		parentRef.SectionName = ptr.New(gwv1beta1.SectionName(sn))
+	}
+
+	route := &gwv1beta1.HTTPRoute{ObjectMeta: metav1.ObjectMeta{
+		Name:      split.Name,
+		Namespace: split.Namespace,
+	}}
+	if err := r.upsert(ctx, split, status, route, func() {
+		route.Spec = gwv1beta1.HTTPRouteSpec{
+			CommonRouteSpec: gwv1beta1.CommonRouteSpec{
+				ParentRefs: []gwv1beta1.ParentReference{parentRef},
+			},
+			Hostnames: []gwv1beta1.Hostname{gwv1beta1.Hostname(split.Spec.Host)},
+			Rules: []gwv1beta1.HTTPRouteRule{{
+				BackendRefs: backendRefs,
+			}},
+		}
+	}); err != nil {
+		return err
+	}
+
+	return r.deleteStaleHTTPRoutes(ctx, split, map[string]struct{}{route.Name: {}})
+}
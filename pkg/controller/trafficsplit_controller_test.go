@@ -0,0 +1,220 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	cmv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	cmmetav1 "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
+	configv1alpha1 "github.com/rigdev/rig/pkg/api/config/v1alpha1"
+	"github.com/rigdev/rig/pkg/api/v1alpha2"
+	"github.com/rigdev/rig/pkg/ptr"
+	"github.com/rigdev/rig/pkg/service/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_TrafficSplitWarnings(t *testing.T) {
+	t.Parallel()
+	ingress := func(name, owner, host string) *netv1.Ingress {
+		ing := &netv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       netv1.IngressSpec{Rules: []netv1.IngressRule{{Host: host}}},
+		}
+		if owner != "" {
+			ing.OwnerReferences = []metav1.OwnerReference{{
+				APIVersion: v1alpha2.GroupVersion.String(),
+				Kind:       "Capsule",
+				Name:       owner,
+				Controller: ptr.New(true),
+			}}
+		}
+		return ing
+	}
+
+	tests := []struct {
+		name      string
+		className string
+		provider  v1alpha2.TrafficSplitProvider
+		objs      []client.Object
+		expected  []string
+	}{
+		{
+			name:      "no conflicts",
+			className: "nginx",
+			objs: []client.Object{
+				ingress("api", "api", "other.example.com"),
+				ingress("manual", "", "api.example.com"),
+			},
+		},
+		{
+			name: "capsule ingress with the same host",
+			objs: []client.Object{ingress("api-v2", "api-v2", "api.example.com")},
+			expected: []string{
+				"the Ingress api-v2 of capsule api-v2 also publishes the host api.example.com",
+			},
+		},
+		{
+			name:      "nginx provider with another ingress class",
+			className: "traefik",
+			expected: []string{
+				"the Nginx provider requires ingress-nginx, but Ingresses have the class traefik",
+			},
+		},
+		{
+			name:      "gateway provider with another ingress class",
+			className: "traefik",
+			provider:  v1alpha2.TrafficSplitProviderGateway,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			r := &TrafficSplitReconciler{
+				Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(tt.objs...).Build(),
+				Config: config.NewServiceFromConfig(&configv1alpha1.OperatorConfig{
+					Ingress: configv1alpha1.IngressConfig{ClassName: tt.className},
+				}),
+			}
+			split := &v1alpha2.TrafficSplit{
+				ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"},
+				Spec:       v1alpha2.TrafficSplitSpec{Host: "api.example.com", Provider: tt.provider},
+			}
+			warnings, err := r.warnings(context.Background(), split)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, warnings)
+		})
+	}
+}
+
+func Test_TrafficSplitSetIngressKeepsAnnotations(t *testing.T) {
+	t.Parallel()
+	r := &TrafficSplitReconciler{
+		Config: config.NewServiceFromConfig(&configv1alpha1.OperatorConfig{}),
+	}
+	split := &v1alpha2.TrafficSplit{
+		ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"},
+		Spec: v1alpha2.TrafficSplitSpec{
+			Host: "api.example.com",
+			Backends: []v1alpha2.TrafficSplitBackend{
+				{Capsule: "api", Interface: "http", Weight: 80},
+				{Capsule: "api-v2", Interface: "http", Weight: 20},
+			},
+		},
+	}
+	ing := &netv1.Ingress{ObjectMeta: metav1.ObjectMeta{
+		Name:      "api-canary",
+		Namespace: "default",
+		Annotations: map[string]string{
+			annotationNginxCanaryWeight:  "50",
+			"stale":                      "true",
+			AnnotationManagedAnnotations: annotationNginxCanaryWeight + ",stale",
+			"other/set-by-user":          "true",
+		},
+	}}
+
+	r.setIngress(split, split.Spec.Backends[1], ing, true)
+
+	assert.Equal(t, map[string]string{
+		annotationNginxCanary:        "true",
+		annotationNginxCanaryWeight:  "20",
+		AnnotationManagedAnnotations: annotationNginxCanary + "," + annotationNginxCanaryWeight,
+		"other/set-by-user":          "true",
+	}, ing.Annotations)
+}
+
+func Test_TrafficSplitCertificate(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name               string
+		certmanager        *configv1alpha1.CertManagerConfig
+		expected           *certificate
+		expectedAnnotation string
+	}{
+		{
+			name: "no cert-manager",
+		},
+		{
+			name:               "ingress annotation",
+			certmanager:        &configv1alpha1.CertManagerConfig{ClusterIssuer: "letsencrypt"},
+			expectedAnnotation: "letsencrypt",
+			expected: &certificate{
+				name:       "api",
+				secretName: "api-tls",
+				issuer:     cmmetav1.ObjectReference{Kind: cmv1.ClusterIssuerKind, Name: "letsencrypt"},
+				dnsNames:   []string{"api.example.com"},
+			},
+		},
+		{
+			name: "certificate resource",
+			certmanager: &configv1alpha1.CertManagerConfig{
+				ClusterIssuer:              "letsencrypt",
+				CreateCertificateResources: true,
+			},
+			expected: &certificate{
+				name:       "api",
+				secretName: "api-tls",
+				issuer:     cmmetav1.ObjectReference{Kind: cmv1.ClusterIssuerKind, Name: "letsencrypt"},
+				dnsNames:   []string{"api.example.com"},
+			},
+		},
+		{
+			name: "wildcard",
+			certmanager: &configv1alpha1.CertManagerConfig{
+				ClusterIssuer:              "letsencrypt",
+				CreateCertificateResources: true,
+				Wildcards: []configv1alpha1.WildcardCertificate{{
+					Domain:        "example.com",
+					ClusterIssuer: "letsencrypt-dns",
+				}},
+			},
+			expected: &certificate{
+				name:       "wildcard-example-com",
+				secretName: "wildcard-example-com-tls",
+				issuer:     cmmetav1.ObjectReference{Kind: cmv1.ClusterIssuerKind, Name: "letsencrypt-dns"},
+				dnsNames:   []string{"*.example.com"},
+				wildcard:   true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			r := &TrafficSplitReconciler{
+				Config: config.NewServiceFromConfig(&configv1alpha1.OperatorConfig{
+					Certmanager: tt.certmanager,
+				}),
+			}
+			split := &v1alpha2.TrafficSplit{
+				ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"},
+				Spec: v1alpha2.TrafficSplitSpec{
+					Host: "api.example.com",
+					Backends: []v1alpha2.TrafficSplitBackend{
+						{Capsule: "api", Interface: "http", Weight: 80},
+						{Capsule: "api-v2", Interface: "http", Weight: 20},
+					},
+				},
+			}
+			assert.Equal(t, tt.expected, r.certificate(split))
+
+			ing := &netv1.Ingress{}
+			r.setIngress(split, split.Spec.Backends[0], ing, false)
+			assert.Equal(t, tt.expectedAnnotation, ing.Annotations["cert-manager.io/cluster-issuer"])
+			if tt.expected == nil {
+				assert.Empty(t, ing.Spec.TLS)
+			} else {
+				require.Len(t, ing.Spec.TLS, 1)
+				assert.Equal(t, tt.expected.secretName, ing.Spec.TLS[0].SecretName)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+// Package jsonschema generates JSON Schemas for Go types from their json
+// struct tags, for use by editors when writing config files.
+package jsonschema
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Draft is the JSON Schema dialect of the generated schemas.
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is a JSON Schema.
+type Schema struct {
+	Schema     string             `json:"$schema,omitempty"`
+	Ref        string             `json:"$ref,omitempty"`
+	Defs       map[string]*Schema `json:"$defs,omitempty"`
+	Title      string             `json:"title,omitempty"`
+	Type       string             `json:"type,omitempty"`
+	Format     string             `json:"format,omitempty"`
+	AnyOf      []*Schema          `json:"anyOf,omitempty"`
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	Items      *Schema            `json:"items,omitempty"`
+
+	// AdditionalProperties is either a *Schema or false.
+	AdditionalProperties any `json:"additionalProperties,omitempty"`
+}
+
+var (
+	jsonUnmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+	intOrString = &Schema{AnyOf: []*Schema{{Type: "integer"}, {Type: "string"}}}
+
+	// known holds the schemas of types which decode from JSON differently
+	// than their Go representation.
+	known = map[reflect.Type]*Schema{
+		reflect.TypeOf(intstr.IntOrString{}): intOrString,
+		reflect.TypeOf(resource.Quantity{}):  intOrString,
+		reflect.TypeOf(metav1.Duration{}):    {Type: "string"},
+		reflect.TypeOf(metav1.Time{}):        {Type: "string", Format: "date-time"},
+	}
+)
+
+// For returns the schema of the type of the given value. The schemas of
+// named struct types are placed in $defs, so recursive types are supported.
+// Unknown fields of structs are not allowed.
+func For(v any) *Schema {
+	r := &reflector{defs: map[string]*Schema{}}
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	var s *Schema
+	if t.Kind() == reflect.Struct {
+		s = r.reflectStruct(t)
+	} else {
+		c := *r.reflect(t)
+		s = &c
+	}
+	s.Schema = Draft
+	s.Title = t.Name()
+	if len(r.defs) > 0 {
+		s.Defs = r.defs
+	}
+	return s
+}
+
+type reflector struct {
+	defs map[string]*Schema
+}
+
+func (r *reflector) reflect(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if s, ok := known[t]; ok {
+		return s
+	}
+
+	if t.Kind() != reflect.String && (t.Implements(jsonUnmarshaler) ||
+		reflect.PointerTo(t).Implements(jsonUnmarshaler)) {
+		// Custom JSON decoding, so nothing can be assumed about the format.
+		return &Schema{}
+	}
+	if reflect.PointerTo(t).Implements(textUnmarshaler) {
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// Byte slices are base64 encoded strings.
+			return &Schema{Type: "string"}
+		}
+		return &Schema{Type: "array", Items: r.reflect(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: r.reflect(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return r.reflectStruct(t)
+		}
+		name := defName(t)
+		if _, ok := r.defs[name]; !ok {
+			// Reserve the name before reflecting the fields, to stop
+			// recursion.
+			r.defs[name] = nil
+			r.defs[name] = r.reflectStruct(t)
+		}
+		return &Schema{Ref: "#/$defs/" + name}
+	default:
+		return &Schema{}
+	}
+}
+
+func (r *reflector) reflectStruct(t reflect.Type) *Schema {
+	s := &Schema{
+		Type:       "object",
+		Properties: map[string]*Schema{},
+		// Unknown fields are rejected by strict decoding.
+		AdditionalProperties: false,
+	}
+	r.addFields(s, t)
+	return s
+}
+
+func (r *reflector) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		tag, ok := f.Tag.Lookup("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if (f.Anonymous && !ok && ft.Kind() == reflect.Struct) || strings.Contains(opts, "inline") {
+			r.addFields(s, ft)
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = r.reflect(f.Type)
+	}
+}
+
+func defName(t reflect.Type) string {
+	return strings.ReplaceAll(t.PkgPath(), "/", ".") + "." + t.Name()
+}
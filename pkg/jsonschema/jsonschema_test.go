@@ -0,0 +1,60 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+type testNode struct {
+	Name     string      `json:"name"`
+	Children []*testNode `json:"children,omitempty"`
+}
+
+type testConfig struct {
+	metav1.TypeMeta `json:",inline"`
+
+	Port     int                 `json:"port,omitempty"`
+	Ratio    *float64            `json:"ratio,omitempty"`
+	Labels   map[string]string   `json:"labels,omitempty"`
+	Budget   *intstr.IntOrString `json:"budget,omitempty"`
+	Root     testNode            `json:"root"`
+	internal string
+	Ignored  string `json:"-"`
+}
+
+func TestFor(t *testing.T) {
+	bs, err := json.Marshal(For(&testConfig{}))
+	require.NoError(t, err)
+
+	expected := `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"$defs": {
+		"github.com.rigdev.rig.pkg.jsonschema.testNode": {
+			"type": "object",
+			"properties": {
+				"children": {"type": "array", "items": {"$ref": "#/$defs/github.com.rigdev.rig.pkg.jsonschema.testNode"}},
+				"name": {"type": "string"}
+			},
+			"additionalProperties": false
+		}
+	},
+	"title": "testConfig",
+	"type": "object",
+	"properties": {
+		"apiVersion": {"type": "string"},
+		"kind": {"type": "string"},
+		"port": {"type": "integer"},
+		"ratio": {"type": "number"},
+		"labels": {"type": "object", "additionalProperties": {"type": "string"}},
+		"budget": {"anyOf": [{"type": "integer"}, {"type": "string"}]},
+		"root": {"$ref": "#/$defs/github.com.rigdev.rig.pkg.jsonschema.testNode"}
+	},
+	"additionalProperties": false
+}`
+	assert.JSONEq(t, expected, string(bs))
+}
@@ -10,14 +10,20 @@ import (
 	"github.com/rigdev/rig/pkg/api/v1alpha2"
 	"github.com/rigdev/rig/pkg/controller"
 	"github.com/rigdev/rig/pkg/service/config"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/selection"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientsetscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	gwv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
 )
 
 func NewScheme() *runtime.Scheme {
@@ -28,6 +34,7 @@ func NewScheme() *runtime.Scheme {
 	utilruntime.Must(v1alpha2.AddToScheme(s))
 	utilruntime.Must(certv1.AddToScheme(s))
 	utilruntime.Must(monitorv1.AddToScheme(s))
+	utilruntime.Must(gwv1beta1.AddToScheme(s))
 	return s
 }
 
@@ -39,6 +46,12 @@ func getEnvWithDefault(env, def string) string {
 	return v
 }
 
+func capsulePodSelector() labels.Selector {
+	req, err := labels.NewRequirement(controller.LabelCapsule, selection.Exists, nil)
+	utilruntime.Must(err)
+	return labels.NewSelector().Add(*req)
+}
+
 func NewManager(cfgS config.Service, scheme *runtime.Scheme) (manager.Manager, error) {
 	cfg := cfgS.Get()
 
@@ -53,18 +66,62 @@ func NewManager(cfgS config.Service, scheme *runtime.Scheme) (manager.Manager, e
 		LeaderElectionID:              "3d9f417a.rig.dev",
 		LeaderElectionNamespace:       getEnvWithDefault("POD_NAMESPACE", "rig-system"),
 		LeaderElectionReleaseOnCancel: true,
+		Cache: cache.Options{
+			ByObject: map[client.Object]cache.ByObject{
+				// Only the pods of capsules are watched, to detect failing
+				// rollouts.
+				&corev1.Pod{}: {Label: capsulePodSelector()},
+			},
+		},
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	// Reload the config on changes to the config file.
+	if r, ok := cfgS.(manager.Runnable); ok {
+		if err := mgr.Add(r); err != nil {
+			return nil, err
+		}
+	}
+
 	cr := &controller.CapsuleReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Config:   cfgS,
+		Recorder: mgr.GetEventRecorderFor("rig-operator"),
+	}
+
+	if err := cr.SetupWithManager(mgr); err != nil {
+		return nil, err
+	}
+
+	clr := &controller.CleanupReconciler{
+		Client:   mgr.GetClient(),
+		Config:   cfgS,
+		Recorder: mgr.GetEventRecorderFor("rig-operator"),
+	}
+
+	if err := clr.SetupWithManager(mgr); err != nil {
+		return nil, err
+	}
+
+	er := &controller.EnvironmentReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
-		Config: cfg,
 	}
 
-	if err := cr.SetupWithManager(mgr); err != nil {
+	if err := er.SetupWithManager(mgr); err != nil {
+		return nil, err
+	}
+
+	tsr := &controller.TrafficSplitReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+		Config: cfgS,
+	}
+
+	if err := tsr.SetupWithManager(mgr); err != nil {
 		return nil, err
 	}
 
@@ -72,7 +129,16 @@ func NewManager(cfgS config.Service, scheme *runtime.Scheme) (manager.Manager, e
 		if err := (&v1alpha1.Capsule{}).SetupWebhookWithManager(mgr); err != nil {
 			return nil, err
 		}
-		if err := (&v1alpha2.Capsule{}).SetupWebhookWithManager(mgr); err != nil {
+		if err := (&v1alpha2.Capsule{}).SetupWebhookWithManager(mgr, cfgS.Get); err != nil {
+			return nil, err
+		}
+		if err := (&v1alpha2.TrafficSplit{}).SetupWebhookWithManager(mgr); err != nil {
+			return nil, err
+		}
+		if err := (&v1alpha2.CapsuleTemplate{}).SetupWebhookWithManager(mgr); err != nil {
+			return nil, err
+		}
+		if err := (&v1alpha2.ClusterCapsuleTemplate{}).SetupWebhookWithManager(mgr); err != nil {
 			return nil, err
 		}
 		//+kubebuilder:scaffold:builder
@@ -23,6 +23,8 @@ func (c *mockConfig) Get() *v1alpha1.OperatorConfig {
 	return c.cfg
 }
 
+func (c *mockConfig) OnChange(func(oldCfg, newCfg *v1alpha1.OperatorConfig)) {}
+
 func TestGet(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
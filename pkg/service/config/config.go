@@ -1,17 +1,42 @@
 package config
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 
+	"github.com/fsnotify/fsnotify"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rigdev/rig/pkg/api/config/v1alpha1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
+var reloads = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "rig",
+	Subsystem: "operator",
+	Name:      "config_reloads_total",
+	Help:      "Number of reloads of the operator config file by result.",
+}, []string{"result"})
+
+func init() {
+	metrics.Registry.MustRegister(reloads)
+}
+
 type Service interface {
 	Get() *v1alpha1.OperatorConfig
+
+	// OnChange registers a function which is called with the previous and
+	// the new config every time the config is reloaded. The function must
+	// not block.
+	OnChange(func(oldCfg, newCfg *v1alpha1.OperatorConfig))
 }
 
 func NewService(path string, scheme *runtime.Scheme) (Service, error) {
@@ -19,26 +44,151 @@ func NewService(path string, scheme *runtime.Scheme) (Service, error) {
 	if err != nil {
 		return nil, fmt.Errorf("could not read config file: %w", err)
 	}
-	cfg, err := deserialize(bs, scheme)
+	cfg, err := load(bs, scheme)
 	if err != nil {
 		return nil, err
 	}
-	cfg.Default()
 
-	return &service{cfg: cfg}, nil
+	s := &service{
+		path:   path,
+		scheme: scheme,
+		data:   bs,
+	}
+	s.cfg.Store(cfg)
+	return s, nil
+}
+
+// NewServiceFromConfig returns a Service which always returns the given
+// config. It is never reloaded.
+func NewServiceFromConfig(cfg *v1alpha1.OperatorConfig) Service {
+	s := &service{}
+	s.cfg.Store(cfg)
+	return s
 }
 
 type service struct {
-	cfg *v1alpha1.OperatorConfig
+	path   string
+	scheme *runtime.Scheme
+
+	cfg  atomic.Pointer[v1alpha1.OperatorConfig]
+	data []byte
+
+	lock      sync.Mutex
+	listeners []func(oldCfg, newCfg *v1alpha1.OperatorConfig)
 }
 
 // Get implements Service.
 func (s *service) Get() *v1alpha1.OperatorConfig {
-	return s.cfg
+	return s.cfg.Load()
+}
+
+// OnChange implements Service.
+func (s *service) OnChange(f func(oldCfg, newCfg *v1alpha1.OperatorConfig)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.listeners = append(s.listeners, f)
+}
+
+// NeedLeaderElection makes the config reload on all instances of the
+// operator, not only the leader.
+func (s *service) NeedLeaderElection() bool {
+	return false
+}
+
+// Start watches the config file and reloads the config when it changes,
+// until the context is cancelled. The directory of the file is watched, as
+// files mounted from a ConfigMap are replaced by swapping a symlink. An
+// invalid config is rejected and the last good config is kept.
+func (s *service) Start(ctx context.Context) error {
+	if s.path == "" {
+		<-ctx.Done()
+		return nil
+	}
+
+	log := logf.FromContext(ctx).WithName("config").WithValues("path", s.path)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("could not create config file watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(filepath.Dir(s.path)); err != nil {
+		return fmt.Errorf("could not watch config file: %w", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Error(err, "error watching config file")
+		case _, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			changed, err := s.reload()
+			if err != nil {
+				reloads.WithLabelValues("failure").Inc()
+				log.Error(err, "could not reload config, keeping the current config")
+				continue
+			}
+			if changed {
+				reloads.WithLabelValues("success").Inc()
+				log.Info("config reloaded")
+			}
+		}
+	}
+}
+
+// reload reads the config file and swaps the config if the file has
+// changed. It returns true if the config was swapped.
+func (s *service) reload() (bool, error) {
+	bs, err := os.ReadFile(s.path)
+	if err != nil {
+		return false, fmt.Errorf("could not read config file: %w", err)
+	}
+	if bytes.Equal(bs, s.data) {
+		return false, nil
+	}
+
+	cfg, err := load(bs, s.scheme)
+	if err != nil {
+		return false, err
+	}
+
+	s.data = bs
+	oldCfg := s.cfg.Swap(cfg)
+
+	s.lock.Lock()
+	listeners := s.listeners
+	s.lock.Unlock()
+	for _, f := range listeners {
+		f(oldCfg, cfg)
+	}
+
+	return true, nil
+}
+
+func load(data []byte, scheme *runtime.Scheme) (*v1alpha1.OperatorConfig, error) {
+	cfg, err := deserialize(data, scheme)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Default()
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	return cfg, nil
 }
 
 func deserialize(data []byte, scheme *runtime.Scheme) (*v1alpha1.OperatorConfig, error) {
-	decoder := serializer.NewCodecFactory(scheme).UniversalDeserializer()
+	// Decode strictly, so unknown and duplicate fields are reported instead
+	// of silently ignored.
+	decoder := serializer.NewCodecFactory(scheme, serializer.EnableStrict).UniversalDeserializer()
 	_, gvk, err := decoder.Decode(data, nil, nil)
 	if err != nil {
 		return nil, fmt.Errorf("could not decode config: %w", err)
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rigdev/rig/pkg/api/config/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestReload(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(data string) {
+		require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+	}
+
+	write(`apiVersion: config.rig.dev/v1alpha1
+kind: OperatorConfig
+ingress:
+  className: nginx
+`)
+	s, err := NewService(path, scheme)
+	require.NoError(t, err)
+
+	var calls int
+	s.OnChange(func(oldCfg, newCfg *v1alpha1.OperatorConfig) {
+		calls++
+		assert.Equal(t, "nginx", oldCfg.Ingress.ClassName)
+		assert.Equal(t, "traefik", newCfg.Ingress.ClassName)
+	})
+
+	changed, err := s.(*service).reload()
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	write(`kind: Unknown`)
+	_, err = s.(*service).reload()
+	assert.Error(t, err)
+	assert.Equal(t, "nginx", s.Get().Ingress.ClassName)
+
+	write(`apiVersion: config.rig.dev/v1alpha1
+kind: OperatorConfig
+ingress:
+  className: traefik
+`)
+	changed, err = s.(*service).reload()
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, "traefik", s.Get().Ingress.ClassName)
+	assert.True(t, *s.Get().WebhooksEnabled)
+	assert.Equal(t, 1, calls)
+}
+
+func TestLoad(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	tests := []struct {
+		name string
+		data string
+		err  string
+	}{
+		{
+			name: "valid config",
+			data: `certManager:
+  clusterIssuer: letsencrypt
+  createCertificateResources: true`,
+		},
+		{
+			name: "unknown fields are rejected",
+			data: `ingres:
+  className: nginx`,
+			err: `unknown field "ingres"`,
+		},
+		{
+			name: "cluster issuer is required to create certificates",
+			data: `certManager:
+  createCertificateResources: true`,
+			err: "certManager.clusterIssuer: Required value",
+		},
+		{
+			name: "wildcard certificates require certificate resources",
+			data: `certManager:
+  clusterIssuer: letsencrypt
+  wildcards:
+  - domain: example.com`,
+			err: "certManager.wildcards[0]: Forbidden: requires createCertificateResources",
+		},
+		{
+			name: "telemetry protocol must be supported",
+			data: `telemetry:
+  endpoint: http://otel-collector:4317
+  protocol: udp`,
+			err: `telemetry.protocol: Unsupported value: "udp"`,
+		},
+		{
+			name: "default request cannot exceed default limit",
+			data: `defaults:
+  cpu:
+    request: "2"
+    limit: "1"`,
+			err: "defaults.cpu.request: Invalid value",
+		},
+		{
+			name: "override paths must be JSON pointers",
+			data: `overrides:
+  allowed:
+  - kind: Deployment
+    paths:
+    - spec.template.spec.hostAliases`,
+			err: "overrides.allowed[0].paths[0]: Invalid value",
+		},
+		{
+			name: "cleanup traffic query must be a template",
+			data: `cleanup:
+  prometheusURL: http://prometheus:9090
+  trafficQuery: sum(up{namespace="{{ .Namespace }"})`,
+			err: "cleanup.trafficQuery: Invalid value",
+		},
+		{
+			name: "ingress protocol annotations must be for supported protocols",
+			data: `ingress:
+  className: traefik
+  protocolAnnotations:
+    traefik:
+      UDP:
+        example.com/protocol: udp`,
+			err: `ingress.protocolAnnotations[traefik][UDP]: Unsupported value: "UDP"`,
+		},
+		{
+			name: "ingress profiles must be for supported features",
+			data: `ingress:
+  className: haproxy
+  profiles:
+    haproxy:
+      annotations:
+        retries:
+          haproxy.org/retries: "3"`,
+			err: `ingress.profiles[haproxy].annotations[retries]: Unsupported value: "retries"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := "apiVersion: config.rig.dev/v1alpha1\nkind: OperatorConfig\n" + test.data
+			cfg, err := load([]byte(data), scheme)
+			if test.err != "" {
+				assert.ErrorContains(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotNil(t, cfg)
+		})
+	}
+}
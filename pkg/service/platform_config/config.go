@@ -63,6 +63,10 @@ func NewService(path, secretPath string, scheme *runtime.Scheme) (Service, error
 		return nil, fmt.Errorf("could not merge secret into config: %w", err)
 	}
 
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
 	return &service{cfg: cfg}, nil
 }
 
@@ -75,7 +79,9 @@ func (s *service) Get() *v1alpha1.PlatformConfig {
 }
 
 func deserialize(data []byte, scheme *runtime.Scheme) (*v1alpha1.PlatformConfig, error) {
-	decoder := serializer.NewCodecFactory(scheme).UniversalDeserializer()
+	// Decode strictly, so unknown and duplicate fields are reported instead
+	// of silently ignored.
+	decoder := serializer.NewCodecFactory(scheme, serializer.EnableStrict).UniversalDeserializer()
 	_, gvk, err := decoder.Decode(data, nil, nil)
 	if err != nil {
 		return nil, fmt.Errorf("could not decode config: %w", err)
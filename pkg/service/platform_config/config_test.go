@@ -16,7 +16,7 @@ func TestNew(t *testing.T) {
 		fileConfig       []string
 		secretFileConfig []string
 		envVars          map[string]string
-		err              error
+		err              string
 		expected         func() *v1alpha1.PlatformConfig
 	}{
 		{
@@ -87,6 +87,52 @@ func TestNew(t *testing.T) {
 				return c
 			},
 		},
+		{
+			name: "unknown fields are rejected",
+			fileConfig: []string{
+				`prot: 4242`,
+			},
+			err: `unknown field "prot"`,
+		},
+		{
+			name: "invalid store is rejected",
+			fileConfig: []string{
+				`repository:
+    store: mysql`,
+			},
+			err: `repository.store: Unsupported value: "mysql"`,
+		},
+		{
+			name: "email type requires client config",
+			fileConfig: []string{
+				`email:
+    from: rig@example.com
+    type: smtp`,
+			},
+			err: "client.smtp.host: Required value",
+		},
+		{
+			name: "email type with client config",
+			fileConfig: []string{
+				`email:
+    from: rig@example.com
+    type: mailjet`,
+			},
+			secretFileConfig: []string{
+				`client:
+    mailjet:
+        apiKey: key
+        secretKey: secret`,
+			},
+			expected: func() *v1alpha1.PlatformConfig {
+				c := v1alpha1.NewDefaultPlatform()
+				c.Email.From = "rig@example.com"
+				c.Email.Type = v1alpha1.EmailTypeMailjet
+				c.Client.Mailjet.APIKey = "key"
+				c.Client.Mailjet.SecretKey = "secret"
+				return c
+			},
+		},
 	}
 
 	for i := range tests {
@@ -149,15 +195,12 @@ func TestNew(t *testing.T) {
 			}
 
 			serv, err := NewService(cfgPath, secretPath, manager.NewScheme())
-			assert.NoError(t, err)
-			c := serv.Get()
-
-			if test.err == nil {
-				assert.NoError(t, err)
-			} else {
-				assert.ErrorAs(t, err, &test.err)
+			if test.err != "" {
+				assert.ErrorContains(t, err, test.err)
+				return
 			}
-			assert.Equal(t, test.expected(), c)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected(), serv.Get())
 		})
 	}
 }
@@ -21,10 +21,12 @@ import (
 
 	cmv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
 	cmmeta "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
+	monitorv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 	"github.com/rigdev/rig/pkg/api/v1alpha2"
 	"github.com/rigdev/rig/pkg/controller"
 	"github.com/rigdev/rig/pkg/hash"
 	netv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 
 	//+kubebuilder:scaffold:imports
@@ -469,6 +471,43 @@ func (s *K8sTestSuite) TestController() {
 
 	assert.NoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(&capsule), &capsule))
 
+	by(t, "Using an issuer for the interface")
+
+	capsule.Spec.Interfaces[0].Public.Ingress.Issuer = &v1alpha2.IssuerReference{
+		Kind: cmv1.IssuerKind,
+		Name: "test",
+	}
+	assert.NoError(t, k8sClient.Update(ctx, &capsule))
+
+	expectResources(ctx, t, k8sClient, []client.Object{
+		&cmv1.Certificate{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("%s-http", nsName.Name),
+				Namespace: ns.Name,
+				OwnerReferences: []metav1.OwnerReference{
+					capsuleOwnerRef,
+				},
+			},
+			Spec: cmv1.CertificateSpec{
+				SecretName: fmt.Sprintf("%s-http-tls", nsName.Name),
+				IssuerRef: cmmeta.ObjectReference{
+					Kind: cmv1.IssuerKind,
+					Name: "test",
+				},
+				DNSNames: []string{
+					"test.com",
+				},
+			},
+		},
+	})
+
+	assert.Eventually(t, func() bool {
+		err := k8sClient.Get(ctx, nsName, &cmv1.Certificate{})
+		return kerrors.IsNotFound(err)
+	}, waitFor, tick)
+
+	assert.NoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(&capsule), &capsule))
+
 	by(t, "Changing ingress to loadbalancer")
 
 	capsule.Spec.Interfaces[0].Public = &v1alpha2.CapsulePublicInterface{
@@ -666,6 +705,599 @@ func (s *K8sTestSuite) TestController() {
 	}, waitFor, tick)
 }
 
+func (s *K8sTestSuite) TestControllerPodDisruptionBudget() {
+	k8sClient := s.Client
+	t := s.Suite.T()
+	ctx := context.Background()
+	nsName := types.NamespacedName{
+		Name:      uuid.NewString(),
+		Namespace: "default",
+	}
+
+	by(t, "Creating a capsule with multiple instances")
+
+	capsule := v1alpha2.Capsule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      nsName.Name,
+			Namespace: nsName.Namespace,
+		},
+		Spec: v1alpha2.CapsuleSpec{
+			Image: "nginx:1.25.1",
+			Scale: v1alpha2.CapsuleScale{
+				Horizontal: v1alpha2.HorizontalScale{
+					Instances: v1alpha2.Instances{
+						Min: uint32(3),
+					},
+					DisruptionBudget: &v1alpha2.DisruptionBudget{
+						MaxUnavailable: ptr.New(intstr.FromInt32(1)),
+					},
+				},
+			},
+		},
+	}
+
+	require.NoError(t, k8sClient.Create(ctx, &capsule))
+	expectResources(ctx, t, k8sClient, []client.Object{
+		&policyv1.PodDisruptionBudget{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      nsName.Name,
+				Namespace: nsName.Namespace,
+			},
+			Spec: policyv1.PodDisruptionBudgetSpec{
+				MaxUnavailable: ptr.New(intstr.FromInt32(1)),
+				Selector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{
+						controller.LabelCapsule: nsName.Name,
+					},
+				},
+			},
+		},
+	})
+
+	by(t, "Scaling down to a single instance")
+
+	require.NoError(t, k8sClient.Get(ctx, nsName, &capsule))
+	capsule.Spec.Scale.Horizontal.Instances.Min = 1
+	require.NoError(t, k8sClient.Update(ctx, &capsule))
+
+	require.Eventually(t, func() bool {
+		return kerrors.IsNotFound(k8sClient.Get(ctx, nsName, &policyv1.PodDisruptionBudget{}))
+	}, waitFor, tick)
+
+	by(t, "Expecting events for the pod disruption budget on the capsule")
+
+	require.Eventually(t, func() bool {
+		var events v1.EventList
+		if err := k8sClient.List(
+			ctx,
+			&events,
+			client.InNamespace(nsName.Namespace),
+			client.MatchingFields{"involvedObject.name": nsName.Name},
+		); err != nil {
+			return false
+		}
+		reasons := map[string]bool{}
+		for _, e := range events.Items {
+			reasons[e.Reason+" "+e.Message] = true
+		}
+		return reasons[controller.EventReasonCreated+" Created PodDisruptionBudget "+nsName.Name] &&
+			reasons[controller.EventReasonDeleted+" Deleted PodDisruptionBudget "+nsName.Name]
+	}, waitFor, tick)
+}
+
+func (s *K8sTestSuite) TestControllerMonitoring() {
+	k8sClient := s.Client
+	t := s.Suite.T()
+	ctx := context.Background()
+	nsName := types.NamespacedName{
+		Name:      uuid.NewString(),
+		Namespace: "default",
+	}
+
+	by(t, "Creating a capsule with a pod monitor and an alert rule")
+
+	capsule := v1alpha2.Capsule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      nsName.Name,
+			Namespace: nsName.Namespace,
+		},
+		Spec: v1alpha2.CapsuleSpec{
+			Image: "nginx:1.25.1",
+			Monitoring: &v1alpha2.Monitoring{
+				Kind:     "PodMonitor",
+				Port:     ptr.New(intstr.FromInt32(9090)),
+				Path:     "/metrics",
+				Interval: &metav1.Duration{Duration: 30 * time.Second},
+				AlertRules: []v1alpha2.AlertRule{{
+					Name: "HighErrorRate",
+					Expr: `sum(rate(http_requests_total{code=~"5.."}[5m])) > 1`,
+					For:  &metav1.Duration{Duration: 5 * time.Minute},
+					Labels: map[string]string{
+						"severity": "critical",
+					},
+				}},
+			},
+		},
+	}
+
+	require.NoError(t, k8sClient.Create(ctx, &capsule))
+	expectResources(ctx, t, k8sClient, []client.Object{
+		&monitorv1.PodMonitor{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      nsName.Name,
+				Namespace: nsName.Namespace,
+			},
+			Spec: monitorv1.PodMonitorSpec{
+				Selector: metav1.LabelSelector{
+					MatchLabels: map[string]string{
+						controller.LabelCapsule: nsName.Name,
+					},
+				},
+				PodMetricsEndpoints: []monitorv1.PodMetricsEndpoint{{
+					Port:     "rig-metrics",
+					Path:     "/metrics",
+					Interval: "30s",
+				}},
+			},
+		},
+		&monitorv1.PrometheusRule{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      nsName.Name,
+				Namespace: nsName.Namespace,
+			},
+			Spec: monitorv1.PrometheusRuleSpec{
+				Groups: []monitorv1.RuleGroup{{
+					Name: nsName.Name,
+					Rules: []monitorv1.Rule{{
+						Alert: "HighErrorRate",
+						Expr:  intstr.FromString(`sum(rate(http_requests_total{code=~"5.."}[5m])) > 1`),
+						For:   ptr.New(monitorv1.Duration("300s")),
+						Labels: map[string]string{
+							"severity": "critical",
+						},
+					}},
+				}},
+			},
+		},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      nsName.Name,
+				Namespace: nsName.Namespace,
+			},
+			Spec: appsv1.DeploymentSpec{
+				Template: v1.PodTemplateSpec{
+					Spec: v1.PodSpec{
+						Containers: []v1.Container{{
+							Name: nsName.Name,
+							Ports: []v1.ContainerPort{{
+								Name:          "rig-metrics",
+								ContainerPort: 9090,
+							}},
+						}},
+					},
+				},
+			},
+		},
+	})
+
+	by(t, "Removing monitoring from the capsule")
+
+	require.NoError(t, k8sClient.Get(ctx, nsName, &capsule))
+	capsule.Spec.Monitoring = nil
+	require.NoError(t, k8sClient.Update(ctx, &capsule))
+
+	require.Eventually(t, func() bool {
+		return kerrors.IsNotFound(k8sClient.Get(ctx, nsName, &monitorv1.PodMonitor{})) &&
+			kerrors.IsNotFound(k8sClient.Get(ctx, nsName, &monitorv1.PrometheusRule{}))
+	}, waitFor, tick)
+}
+
+func (s *K8sTestSuite) TestControllerDependsOn() {
+	k8sClient := s.Client
+	t := s.Suite.T()
+	ctx := context.Background()
+	nsName := types.NamespacedName{
+		Name:      uuid.NewString(),
+		Namespace: "default",
+	}
+
+	by(t, "Creating a capsule depending on a missing capsule")
+
+	capsule := v1alpha2.Capsule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      nsName.Name,
+			Namespace: nsName.Namespace,
+		},
+		Spec: v1alpha2.CapsuleSpec{
+			Image: "nginx:1.25.1",
+			Scale: v1alpha2.CapsuleScale{
+				Horizontal: v1alpha2.HorizontalScale{
+					Instances: v1alpha2.Instances{
+						Min: uint32(2),
+					},
+				},
+			},
+			DependsOn: []v1alpha2.CapsuleDependency{{
+				Capsule: "missing",
+			}},
+		},
+	}
+
+	require.NoError(t, k8sClient.Create(ctx, &capsule))
+	expectResources(ctx, t, k8sClient, []client.Object{
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      nsName.Name,
+				Namespace: nsName.Namespace,
+				Annotations: map[string]string{
+					controller.AnnotationAwaitingDependencies: "true",
+				},
+			},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: ptr.New(int32(0)),
+			},
+		},
+	})
+
+	require.Eventually(t, func() bool {
+		if err := k8sClient.Get(ctx, nsName, &capsule); err != nil {
+			return false
+		}
+		deps := capsule.Status.Dependencies
+		return deps != nil && deps.State == "waiting" &&
+			deps.Blocking != nil && deps.Blocking.Capsule == "missing"
+	}, waitFor, tick)
+
+	by(t, "Removing the dependency")
+
+	capsule.Spec.DependsOn = nil
+	require.NoError(t, k8sClient.Update(ctx, &capsule))
+	expectResources(ctx, t, k8sClient, []client.Object{
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      nsName.Name,
+				Namespace: nsName.Namespace,
+			},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: ptr.New(int32(2)),
+			},
+		},
+	})
+}
+
+func (s *K8sTestSuite) TestControllerConnections() {
+	k8sClient := s.Client
+	t := s.Suite.T()
+	ctx := context.Background()
+	target := "conn-" + uuid.NewString()[:8]
+	nsName := types.NamespacedName{
+		Name:      uuid.NewString(),
+		Namespace: "default",
+	}
+
+	by(t, "Creating a capsule connecting to a missing capsule")
+
+	capsule := v1alpha2.Capsule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      nsName.Name,
+			Namespace: nsName.Namespace,
+		},
+		Spec: v1alpha2.CapsuleSpec{
+			Image: "nginx:1.25.1",
+			Connections: []v1alpha2.Connection{{
+				Name:      "backend",
+				Capsule:   target,
+				Interface: "http",
+			}},
+		},
+	}
+
+	require.NoError(t, k8sClient.Create(ctx, &capsule))
+	require.Eventually(t, func() bool {
+		if err := k8sClient.Get(ctx, nsName, &capsule); err != nil || capsule.Status == nil {
+			return false
+		}
+		for _, res := range capsule.Status.UsedResources {
+			if res.Ref.Kind == "Service" && res.Ref.Name == target && res.State == "missing" {
+				return true
+			}
+		}
+		return false
+	}, waitFor, tick)
+
+	by(t, "Creating the capsule connected to")
+
+	require.NoError(t, k8sClient.Create(ctx, &v1alpha2.Capsule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      target,
+			Namespace: nsName.Namespace,
+		},
+		Spec: v1alpha2.CapsuleSpec{
+			Image: "nginx:1.25.1",
+			Interfaces: []v1alpha2.CapsuleInterface{{
+				Name: "http",
+				Port: 8080,
+			}},
+		},
+	}))
+
+	host := fmt.Sprintf("%s.%s.svc", target, nsName.Namespace)
+	expectResources(ctx, t, k8sClient, []client.Object{
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      nsName.Name,
+				Namespace: nsName.Namespace,
+			},
+			Spec: appsv1.DeploymentSpec{
+				Template: v1.PodTemplateSpec{
+					Spec: v1.PodSpec{
+						Containers: []v1.Container{{
+							Name: nsName.Name,
+							Env: []v1.EnvVar{
+								{Name: "BACKEND_HOST", Value: host},
+								{Name: "BACKEND_PORT", Value: "8080"},
+								{Name: "BACKEND_URL", Value: "http://" + host + ":8080"},
+							},
+						}},
+					},
+				},
+			},
+		},
+	})
+}
+
+func (s *K8sTestSuite) TestControllerGeneratedSecrets() {
+	k8sClient := s.Client
+	t := s.Suite.T()
+	ctx := context.Background()
+	nsName := types.NamespacedName{
+		Name:      uuid.NewString(),
+		Namespace: "default",
+	}
+	secretName := types.NamespacedName{
+		Name:      nsName.Name + "-generated",
+		Namespace: nsName.Namespace,
+	}
+
+	by(t, "Creating a capsule with generated secrets")
+
+	capsule := v1alpha2.Capsule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      nsName.Name,
+			Namespace: nsName.Namespace,
+		},
+		Spec: v1alpha2.CapsuleSpec{
+			Image: "nginx:1.25.1",
+			Env: &v1alpha2.Env{
+				From: []v1alpha2.EnvReference{{
+					Kind: "Secret",
+					Name: secretName.Name,
+				}},
+			},
+			GeneratedSecrets: []v1alpha2.GeneratedSecret{
+				{Key: "PASSWORD", Length: 16},
+				{Key: "SIGNING_KEY", Type: v1alpha2.GeneratedSecretTypeEd25519},
+			},
+		},
+	}
+
+	require.NoError(t, k8sClient.Create(ctx, &capsule))
+
+	var secret v1.Secret
+	require.Eventually(t, func() bool {
+		return k8sClient.Get(ctx, secretName, &secret) == nil
+	}, waitFor, tick)
+	assert.Len(t, secret.Data["PASSWORD"], 16)
+	assert.Contains(t, string(secret.Data["SIGNING_KEY"]), "PRIVATE KEY")
+	assert.Contains(t, string(secret.Data["SIGNING_KEY_PUB"]), "PUBLIC KEY")
+
+	expectResources(ctx, t, k8sClient, []client.Object{
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      nsName.Name,
+				Namespace: nsName.Namespace,
+			},
+			Spec: appsv1.DeploymentSpec{
+				Template: v1.PodTemplateSpec{
+					Spec: v1.PodSpec{
+						Containers: []v1.Container{{
+							Name: nsName.Name,
+							EnvFrom: []v1.EnvFromSource{{
+								SecretRef: &v1.SecretEnvSource{
+									LocalObjectReference: v1.LocalObjectReference{Name: secretName.Name},
+								},
+							}},
+						}},
+					},
+				},
+			},
+		},
+	})
+
+	by(t, "Rotating the password")
+
+	password := string(secret.Data["PASSWORD"])
+	signingKey := string(secret.Data["SIGNING_KEY"])
+	require.NoError(t, k8sClient.Get(ctx, nsName, &capsule))
+	capsule.Annotations = map[string]string{
+		controller.AnnotationRotateGeneratedSecrets: "PASSWORD",
+	}
+	require.NoError(t, k8sClient.Update(ctx, &capsule))
+
+	require.Eventually(t, func() bool {
+		if err := k8sClient.Get(ctx, secretName, &secret); err != nil {
+			return false
+		}
+		return string(secret.Data["PASSWORD"]) != password
+	}, waitFor, tick)
+	assert.Equal(t, signingKey, string(secret.Data["SIGNING_KEY"]))
+
+	require.Eventually(t, func() bool {
+		if err := k8sClient.Get(ctx, nsName, &capsule); err != nil {
+			return false
+		}
+		_, ok := capsule.Annotations[controller.AnnotationRotateGeneratedSecrets]
+		return !ok
+	}, waitFor, tick)
+}
+
+func (s *K8sTestSuite) TestControllerTemplate() {
+	k8sClient := s.Client
+	t := s.Suite.T()
+	ctx := context.Background()
+	templateName := "template-" + uuid.NewString()[:8]
+	nsName := types.NamespacedName{
+		Name:      uuid.NewString(),
+		Namespace: "default",
+	}
+
+	by(t, "Creating a capsule with a missing template")
+
+	capsule := v1alpha2.Capsule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      nsName.Name,
+			Namespace: nsName.Namespace,
+		},
+		Spec: v1alpha2.CapsuleSpec{
+			Image:    "nginx:1.25.1",
+			Template: &v1alpha2.TemplateReference{Name: templateName},
+		},
+	}
+
+	require.NoError(t, k8sClient.Create(ctx, &capsule))
+	require.Eventually(t, func() bool {
+		if err := k8sClient.Get(ctx, nsName, &capsule); err != nil || capsule.Status == nil {
+			return false
+		}
+		for _, res := range capsule.Status.UsedResources {
+			if res.Ref.Kind == v1alpha2.TemplateKindCapsuleTemplate && res.Ref.Name == templateName &&
+				res.State == "missing" {
+				return true
+			}
+		}
+		return false
+	}, waitFor, tick)
+
+	by(t, "Creating the template")
+
+	template := v1alpha2.CapsuleTemplate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      templateName,
+			Namespace: nsName.Namespace,
+		},
+		Spec: v1alpha2.CapsuleTemplateSpec{
+			Args: []string{"serve"},
+			Interfaces: []v1alpha2.CapsuleInterface{{
+				Name: "http",
+				Port: 8080,
+			}},
+		},
+	}
+	require.NoError(t, k8sClient.Create(ctx, &template))
+
+	expectResources(ctx, t, k8sClient, []client.Object{
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      nsName.Name,
+				Namespace: nsName.Namespace,
+			},
+			Spec: appsv1.DeploymentSpec{
+				Template: v1.PodTemplateSpec{
+					Spec: v1.PodSpec{
+						Containers: []v1.Container{{
+							Name:  nsName.Name,
+							Image: "nginx:1.25.1",
+							Args:  []string{"serve"},
+						}},
+					},
+				},
+			},
+		},
+		&v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      nsName.Name,
+				Namespace: nsName.Namespace,
+			},
+			Spec: v1.ServiceSpec{
+				Ports: []v1.ServicePort{{
+					Name: "http",
+					Port: 8080,
+				}},
+			},
+		},
+	})
+
+	by(t, "Updating the template")
+
+	require.NoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(&template), &template))
+	template.Spec.Interfaces[0].Port = 9090
+	require.NoError(t, k8sClient.Update(ctx, &template))
+
+	expectResources(ctx, t, k8sClient, []client.Object{
+		&v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      nsName.Name,
+				Namespace: nsName.Namespace,
+			},
+			Spec: v1.ServiceSpec{
+				Ports: []v1.ServicePort{{
+					Name: "http",
+					Port: 9090,
+				}},
+			},
+		},
+	})
+}
+
+func (s *K8sTestSuite) TestControllerTTL() {
+	k8sClient := s.Client
+	t := s.Suite.T()
+	ctx := context.Background()
+	nsName := types.NamespacedName{
+		Name:      uuid.NewString(),
+		Namespace: "default",
+	}
+
+	by(t, "Creating an expired capsule with an extended lease")
+
+	lease := time.Now().Add(30 * time.Minute).UTC().Truncate(time.Second)
+	capsule := v1alpha2.Capsule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      nsName.Name,
+			Namespace: nsName.Namespace,
+			Annotations: map[string]string{
+				v1alpha2.AnnotationLeaseExtendedUntil: lease.Format(time.RFC3339),
+			},
+		},
+		Spec: v1alpha2.CapsuleSpec{
+			Image: "nginx:1.25.1",
+			TTL: &v1alpha2.CapsuleTTL{
+				ExpiresAt: &metav1.Time{Time: time.Now().Add(-time.Minute)},
+			},
+		},
+	}
+
+	require.NoError(t, k8sClient.Create(ctx, &capsule))
+	require.Eventually(t, func() bool {
+		if err := k8sClient.Get(ctx, nsName, &capsule); err != nil || capsule.Status == nil {
+			return false
+		}
+		e := capsule.Status.Expiration
+		return e != nil && e.State == "expiring" && e.ExpiresAt.Time.Equal(lease)
+	}, waitFor, tick)
+
+	by(t, "Ending the lease")
+
+	delete(capsule.Annotations, v1alpha2.AnnotationLeaseExtendedUntil)
+	require.NoError(t, k8sClient.Update(ctx, &capsule))
+	// The capsule is deleted in the foreground, which is completed by the
+	// garbage collector, so it is not removed by the test environment.
+	require.Eventually(t, func() bool {
+		err := k8sClient.Get(ctx, nsName, &capsule)
+		return kerrors.IsNotFound(err) || err == nil && !capsule.GetDeletionTimestamp().IsZero()
+	}, waitFor, tick)
+}
+
 func by(t *testing.T, msg string) {
 	t.Log("STEP: ", msg)
 }
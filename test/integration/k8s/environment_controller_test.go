@@ -0,0 +1,161 @@
+package k8s_test
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/rigdev/rig/pkg/api/v1alpha2"
+	"github.com/rigdev/rig/pkg/controller"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func (s *K8sTestSuite) TestControllerEnvironment() {
+	k8sClient := s.Client
+	t := s.Suite.T()
+	ctx := context.Background()
+	name := uuid.NewString()
+
+	by(t, "Creating an environment")
+
+	env := v1alpha2.Environment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Spec: v1alpha2.EnvironmentSpec{
+			NamespaceLabels: map[string]string{
+				"team": "payments",
+			},
+			Quota: v1.ResourceList{
+				v1.ResourceRequestsCPU: resource.MustParse("4"),
+			},
+			LimitRange: []v1.LimitRangeItem{{
+				Type: v1.LimitTypeContainer,
+				DefaultRequest: v1.ResourceList{
+					v1.ResourceCPU: resource.MustParse("100m"),
+				},
+			}},
+			SharedConfig: []v1alpha2.SharedConfig{{
+				Name: "shared",
+				Data: map[string]string{
+					"LOG_LEVEL": "info",
+				},
+			}},
+		},
+	}
+
+	require.NoError(t, k8sClient.Create(ctx, &env))
+	expectResources(ctx, t, k8sClient, []client.Object{
+		&v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+				Labels: map[string]string{
+					"team":                      "payments",
+					controller.LabelEnvironment: name,
+				},
+			},
+		},
+		&v1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: name,
+			},
+			Spec: v1.ResourceQuotaSpec{
+				Hard: v1.ResourceList{
+					v1.ResourceRequestsCPU: resource.MustParse("4"),
+				},
+			},
+		},
+		&v1.LimitRange{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: name,
+			},
+			Spec: v1.LimitRangeSpec{
+				Limits: []v1.LimitRangeItem{{
+					Type: v1.LimitTypeContainer,
+					DefaultRequest: v1.ResourceList{
+						v1.ResourceCPU: resource.MustParse("100m"),
+					},
+				}},
+			},
+		},
+		&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "shared",
+				Namespace: name,
+				Labels: map[string]string{
+					controller.LabelSharedConfig: "true",
+					controller.LabelEnvironment:  name,
+				},
+			},
+			Data: map[string]string{
+				"LOG_LEVEL": "info",
+			},
+		},
+	})
+
+	require.Eventually(t, func() bool {
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(&env), &env); err != nil {
+			return false
+		}
+		return env.Status != nil && env.Status.State == "ready" && env.Status.Namespace == name
+	}, waitFor, tick)
+
+	by(t, "Copying an image pull secret")
+
+	source := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+		},
+		Type: v1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{
+			v1.DockerConfigJsonKey: []byte(`{"auths":{}}`),
+		},
+	}
+	require.NoError(t, k8sClient.Create(ctx, source))
+
+	require.NoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(&env), &env))
+	env.Spec.ImagePullSecrets = []v1alpha2.ImagePullSecret{{
+		Name: "registry",
+		From: v1alpha2.SecretReference{Namespace: "default", Name: name},
+	}}
+	require.NoError(t, k8sClient.Update(ctx, &env))
+
+	copied := func(data string) func() bool {
+		return func() bool {
+			var secret v1.Secret
+			key := types.NamespacedName{Namespace: name, Name: "registry"}
+			if err := k8sClient.Get(ctx, key, &secret); err != nil {
+				return false
+			}
+			return string(secret.Data[v1.DockerConfigJsonKey]) == data
+		}
+	}
+	require.Eventually(t, copied(`{"auths":{}}`), waitFor, tick)
+
+	by(t, "Rotating the image pull secret")
+
+	source.Data[v1.DockerConfigJsonKey] = []byte(`{"auths":{"registry.example.com":{}}}`)
+	require.NoError(t, k8sClient.Update(ctx, source))
+	require.Eventually(t, copied(`{"auths":{"registry.example.com":{}}}`), waitFor, tick)
+
+	by(t, "Retaining the namespace")
+
+	require.NoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(&env), &env))
+	env.Spec.NamespaceDeletionPolicy = v1alpha2.NamespaceDeletionPolicyRetain
+	require.NoError(t, k8sClient.Update(ctx, &env))
+
+	require.Eventually(t, func() bool {
+		var ns v1.Namespace
+		if err := k8sClient.Get(ctx, types.NamespacedName{Name: name}, &ns); err != nil {
+			return false
+		}
+		return len(ns.OwnerReferences) == 0 && ns.Labels[controller.LabelEnvironment] == name
+	}, waitFor, tick)
+}
@@ -12,8 +12,10 @@ import (
 	configv1alpha1 "github.com/rigdev/rig/pkg/api/config/v1alpha1"
 	"github.com/rigdev/rig/pkg/controller"
 	"github.com/rigdev/rig/pkg/manager"
+	"github.com/rigdev/rig/pkg/service/config"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
@@ -73,18 +75,46 @@ func (s *K8sTestSuite) SetupSuite() {
 	s.Client = k8sClient
 
 	capsuleReconciler := &controller.CapsuleReconciler{
-		Client: manager.GetClient(),
-		Scheme: scheme,
-		Config: &configv1alpha1.OperatorConfig{
+		Client:   manager.GetClient(),
+		Scheme:   scheme,
+		Recorder: manager.GetEventRecorderFor("rig-operator"),
+		Config: config.NewServiceFromConfig(&configv1alpha1.OperatorConfig{
 			Certmanager: &configv1alpha1.CertManagerConfig{
 				ClusterIssuer:              "test",
 				CreateCertificateResources: true,
 			},
-		},
+		}),
 	}
 
 	require.NoError(t, capsuleReconciler.SetupWithManager(manager))
 
+	cleanupReconciler := &controller.CleanupReconciler{
+		Client:   manager.GetClient(),
+		Recorder: manager.GetEventRecorderFor("rig-operator"),
+		Config: config.NewServiceFromConfig(&configv1alpha1.OperatorConfig{
+			Cleanup: configv1alpha1.CleanupConfig{
+				WarningPeriod: &metav1.Duration{Duration: time.Hour},
+			},
+		}),
+	}
+
+	require.NoError(t, cleanupReconciler.SetupWithManager(manager))
+
+	environmentReconciler := &controller.EnvironmentReconciler{
+		Client: manager.GetClient(),
+		Scheme: scheme,
+	}
+
+	require.NoError(t, environmentReconciler.SetupWithManager(manager))
+
+	trafficSplitReconciler := &controller.TrafficSplitReconciler{
+		Client: manager.GetClient(),
+		Scheme: scheme,
+		Config: config.NewServiceFromConfig(&configv1alpha1.OperatorConfig{}),
+	}
+
+	require.NoError(t, trafficSplitReconciler.SetupWithManager(manager))
+
 	ctx, cancel := context.WithCancel(context.Background())
 	go func() {
 		require.NoError(t, manager.Start(ctx))
@@ -0,0 +1,117 @@
+package k8s_test
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/rigdev/rig/pkg/api/v1alpha2"
+	"github.com/rigdev/rig/pkg/controller"
+	"github.com/stretchr/testify/require"
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func (s *K8sTestSuite) TestControllerTrafficSplit() {
+	k8sClient := s.Client
+	t := s.Suite.T()
+	ctx := context.Background()
+	name := uuid.NewString()
+
+	by(t, "Creating the capsules of the traffic split")
+
+	for _, c := range []string{name + "-v1", name + "-v2"} {
+		require.NoError(t, k8sClient.Create(ctx, &v1alpha2.Capsule{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      c,
+				Namespace: "default",
+			},
+			Spec: v1alpha2.CapsuleSpec{
+				Image:      "nginx:1.25.1",
+				Interfaces: []v1alpha2.CapsuleInterface{{Name: "http", Port: 80}},
+			},
+		}))
+	}
+
+	by(t, "Creating a traffic split")
+
+	split := v1alpha2.TrafficSplit{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+		},
+		Spec: v1alpha2.TrafficSplitSpec{
+			Host: "api.example.com",
+			Backends: []v1alpha2.TrafficSplitBackend{
+				{Capsule: name + "-v1", Interface: "http", Weight: 80},
+				{Capsule: name + "-v2", Interface: "http", Weight: 20},
+			},
+		},
+	}
+
+	require.NoError(t, k8sClient.Create(ctx, &split))
+	expectResources(ctx, t, k8sClient, []client.Object{
+		&netv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels: map[string]string{
+					controller.LabelTrafficSplit: name,
+				},
+			},
+			Spec: netv1.IngressSpec{
+				Rules: []netv1.IngressRule{{
+					Host: "api.example.com",
+					IngressRuleValue: netv1.IngressRuleValue{
+						HTTP: &netv1.HTTPIngressRuleValue{
+							Paths: []netv1.HTTPIngressPath{{
+								Backend: netv1.IngressBackend{
+									Service: &netv1.IngressServiceBackend{
+										Name: name + "-v1",
+										Port: netv1.ServiceBackendPort{Name: "http"},
+									},
+								},
+							}},
+						},
+					},
+				}},
+			},
+		},
+		&netv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("%s-canary", name),
+				Namespace: "default",
+				Annotations: map[string]string{
+					"nginx.ingress.kubernetes.io/canary":        "true",
+					"nginx.ingress.kubernetes.io/canary-weight": "20",
+				},
+			},
+		},
+	})
+
+	by(t, "Shifting traffic to the second capsule")
+
+	require.NoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(&split), &split))
+	split.Spec.Backends[0].Weight = 10
+	split.Spec.Backends[1].Weight = 90
+	require.NoError(t, k8sClient.Update(ctx, &split))
+
+	require.Eventually(t, func() bool {
+		var ing netv1.Ingress
+		key := types.NamespacedName{Namespace: "default", Name: fmt.Sprintf("%s-canary", name)}
+		if err := k8sClient.Get(ctx, key, &ing); err != nil {
+			return false
+		}
+		return ing.Annotations["nginx.ingress.kubernetes.io/canary-weight"] == "90"
+	}, waitFor, tick)
+
+	require.Eventually(t, func() bool {
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(&split), &split); err != nil {
+			return false
+		}
+		return split.Status != nil && split.Status.State == "ready" &&
+			split.Status.ObservedGeneration == split.Generation
+	}, waitFor, tick)
+}